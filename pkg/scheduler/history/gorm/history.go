@@ -0,0 +1,88 @@
+package gorm
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/mandarine-io/baselib/pkg/scheduler"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"time"
+)
+
+// JobRun is the database model of the job run.
+type JobRun struct {
+	ID         uint64    `gorm:"primaryKey"`
+	JobID      uuid.UUID `gorm:"type:uuid"`
+	JobName    string    `gorm:"index:idx_scheduler_job_runs_name_started,priority:1"`
+	Status     string
+	StartedAt  time.Time `gorm:"index:idx_scheduler_job_runs_name_started,priority:2,sort:desc"`
+	FinishedAt time.Time
+	Duration   time.Duration
+	Error      string
+	NextRun    time.Time
+}
+
+func (JobRun) TableName() string {
+	return "scheduler_job_runs"
+}
+
+type history struct {
+	db *gorm.DB
+}
+
+// NewHistory creates the job history stored in the scheduler_job_runs table.
+// The table can be created with AutoMigrate.
+func NewHistory(db *gorm.DB) scheduler.History {
+	return &history{db: db}
+}
+
+// AutoMigrate creates or updates the table of job runs.
+func AutoMigrate(db *gorm.DB) error {
+	return db.AutoMigrate(&JobRun{})
+}
+
+func (h *history) Save(ctx context.Context, run scheduler.Run) error {
+	log.Debug().Msgf("save run of job %s", run.JobName)
+
+	model := JobRun{
+		JobID:      run.JobID,
+		JobName:    run.JobName,
+		Status:     string(run.Status),
+		StartedAt:  run.StartedAt,
+		FinishedAt: run.FinishedAt,
+		Duration:   run.Duration,
+		Error:      run.Error,
+		NextRun:    run.NextRun,
+	}
+	return h.db.WithContext(ctx).Create(&model).Error
+}
+
+func (h *history) List(ctx context.Context, jobName string, limit int) ([]scheduler.Run, error) {
+	log.Debug().Msgf("list runs of job %s", jobName)
+
+	var models []JobRun
+	tx := h.db.WithContext(ctx).
+		Where("job_name = ?", jobName).
+		Order("started_at DESC")
+	if limit > 0 {
+		tx = tx.Limit(limit)
+	}
+	if err := tx.Find(&models).Error; err != nil {
+		return nil, err
+	}
+
+	runs := make([]scheduler.Run, 0, len(models))
+	for _, m := range models {
+		runs = append(runs, scheduler.Run{
+			JobID:      m.JobID,
+			JobName:    m.JobName,
+			Status:     scheduler.RunStatus(m.Status),
+			StartedAt:  m.StartedAt,
+			FinishedAt: m.FinishedAt,
+			Duration:   m.Duration,
+			Error:      m.Error,
+			NextRun:    m.NextRun,
+		})
+	}
+	return runs, nil
+}
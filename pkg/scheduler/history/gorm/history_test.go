@@ -0,0 +1,88 @@
+package gorm
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/mandarine-io/baselib/pkg/scheduler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	pgdriver "gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func newHistory(t *testing.T) (scheduler.History, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = sqlDB.Close()
+	})
+
+	db, err := gorm.Open(pgdriver.New(pgdriver.Config{Conn: sqlDB}), &gorm.Config{SkipDefaultTransaction: true})
+	require.NoError(t, err)
+	return NewHistory(db), mock
+}
+
+func TestHistory_Save(t *testing.T) {
+	h, mock := newHistory(t)
+
+	startedAt := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	run := scheduler.Run{
+		JobID:      uuid.New(),
+		JobName:    "job",
+		Status:     scheduler.RunStatusFailed,
+		StartedAt:  startedAt,
+		FinishedAt: startedAt.Add(time.Second),
+		Duration:   time.Second,
+		Error:      "failed",
+		NextRun:    startedAt.Add(time.Hour),
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "scheduler_job_runs"`)).
+		WithArgs(run.JobID, "job", "failed", run.StartedAt, run.FinishedAt, run.Duration, "failed", run.NextRun).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	require.NoError(t, h.Save(context.Background(), run))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHistory_ListByJobName(t *testing.T) {
+	h, mock := newHistory(t)
+
+	// The runs of the previous processes have other job ids
+	startedAt := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"id", "job_id", "job_name", "status", "started_at", "finished_at", "duration", "error", "next_run"}).
+		AddRow(2, uuid.New(), "job", "succeeded", startedAt.Add(time.Hour), startedAt.Add(time.Hour), 0, "", startedAt.Add(2*time.Hour)).
+		AddRow(1, uuid.New(), "job", "failed", startedAt, startedAt.Add(time.Second), time.Second, "failed", startedAt.Add(time.Hour))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "scheduler_job_runs" WHERE job_name = $1 ORDER BY started_at DESC LIMIT $2`)).
+		WithArgs("job", 10).
+		WillReturnRows(rows)
+
+	runs, err := h.List(context.Background(), "job", 10)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	require.Len(t, runs, 2)
+	assert.Equal(t, scheduler.RunStatusSucceeded, runs[0].Status)
+	assert.Equal(t, startedAt.Add(time.Hour), runs[0].StartedAt)
+	assert.Equal(t, scheduler.RunStatusFailed, runs[1].Status)
+	assert.Equal(t, "failed", runs[1].Error)
+	assert.Equal(t, time.Second, runs[1].Duration)
+}
+
+func TestHistory_ListWithoutLimit(t *testing.T) {
+	h, mock := newHistory(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "scheduler_job_runs" WHERE job_name = $1 ORDER BY started_at DESC`)).
+		WithArgs("job").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	runs, err := h.List(context.Background(), "job", 0)
+	require.NoError(t, err)
+	assert.Empty(t, runs)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,96 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"github.com/go-co-op/gocron/v2"
+	"github.com/mandarine-io/baselib/pkg/scheduler"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"hash/fnv"
+	"time"
+)
+
+type locker struct {
+	db      *gorm.DB
+	minHold time.Duration
+}
+
+type lock struct {
+	conn     *sql.Conn
+	key      string
+	lockId   int64
+	lockedAt time.Time
+	minHold  time.Duration
+}
+
+// NewLocker creates the locker based on PostgreSQL session-level advisory locks.
+// Every held lock uses a dedicated connection of the pool. The lock is held at least
+// minHold, so that another instance whose clock lags behind doesn't run a short job again.
+func NewLocker(db *gorm.DB, minHold time.Duration) gocron.Locker {
+	return &locker{db: db, minHold: minHold}
+}
+
+func (l *locker) Lock(ctx context.Context, key string) (gocron.Lock, error) {
+	log.Debug().Msgf("lock job %s", key)
+
+	sqlDB, err := l.db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	// Advisory locks belong to the session, so the lock and unlock must use the same connection
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	lockId := hashKey(key)
+	var acquired bool
+	err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", lockId).Scan(&acquired)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if !acquired {
+		_ = conn.Close()
+		return nil, scheduler.ErrLockNotAcquired
+	}
+
+	return &lock{conn: conn, key: key, lockId: lockId, lockedAt: time.Now(), minHold: l.minHold}, nil
+}
+
+func (l *lock) Unlock(_ context.Context) error {
+	remaining := l.minHold - time.Since(l.lockedAt)
+	if remaining > 0 {
+		time.AfterFunc(remaining, l.unlock)
+		return nil
+	}
+
+	l.unlock()
+	return nil
+}
+
+func (l *lock) unlock() {
+	log.Debug().Msgf("unlock job %s", l.key)
+	defer func() {
+		_ = l.conn.Close()
+	}()
+
+	_, err := l.conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", l.lockId)
+	if err != nil {
+		log.Error().Stack().Err(err).Msgf("failed to unlock job %s", l.key)
+		// The session may still hold the lock, so the connection is discarded instead of
+		// returning to the pool. Closing the session releases its advisory locks.
+		_ = l.conn.Raw(func(any) error {
+			return driver.ErrBadConn
+		})
+	}
+}
+
+func hashKey(key string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return int64(h.Sum64())
+}
@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/mandarine-io/baselib/pkg/scheduler"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	pgdriver "gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+const (
+	lockQuery   = "SELECT pg_try_advisory_lock($1)"
+	unlockQuery = "SELECT pg_advisory_unlock($1)"
+)
+
+func newDB(t *testing.T) (*gorm.DB, *sql.DB, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = sqlDB.Close()
+	})
+
+	db, err := gorm.Open(pgdriver.New(pgdriver.Config{Conn: sqlDB}), &gorm.Config{})
+	require.NoError(t, err)
+	return db, sqlDB, mock
+}
+
+func TestLocker_LockAndUnlock(t *testing.T) {
+	db, sqlDB, mock := newDB(t)
+	lockId := hashKey("job")
+
+	mock.ExpectQuery(lockQuery).WithArgs(lockId).WillReturnRows(sqlmock.NewRows([]string{"acquired"}).AddRow(true))
+	mock.ExpectExec(unlockQuery).WithArgs(lockId).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	lock, err := NewLocker(db, 0).Lock(context.Background(), "job")
+	require.NoError(t, err)
+	assert.Equal(t, 1, sqlDB.Stats().InUse)
+
+	require.NoError(t, lock.Unlock(context.Background()))
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// The connection is returned to the pool
+	assert.Equal(t, 0, sqlDB.Stats().InUse)
+	assert.Equal(t, 1, sqlDB.Stats().Idle)
+}
+
+func TestLocker_LockNotAcquired(t *testing.T) {
+	db, sqlDB, mock := newDB(t)
+
+	mock.ExpectQuery(lockQuery).WithArgs(hashKey("job")).WillReturnRows(sqlmock.NewRows([]string{"acquired"}).AddRow(false))
+
+	_, err := NewLocker(db, 0).Lock(context.Background(), "job")
+	assert.ErrorIs(t, err, scheduler.ErrLockNotAcquired)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, 0, sqlDB.Stats().InUse)
+}
+
+func TestLocker_LockError(t *testing.T) {
+	db, sqlDB, mock := newDB(t)
+	errQuery := errors.New("query failed")
+
+	mock.ExpectQuery(lockQuery).WithArgs(hashKey("job")).WillReturnError(errQuery)
+
+	_, err := NewLocker(db, 0).Lock(context.Background(), "job")
+	assert.ErrorIs(t, err, errQuery)
+	assert.Equal(t, 0, sqlDB.Stats().InUse)
+}
+
+func TestLocker_UnlockKeepsMinHold(t *testing.T) {
+	db, _, mock := newDB(t)
+	lockId := hashKey("job")
+
+	mock.ExpectQuery(lockQuery).WithArgs(lockId).WillReturnRows(sqlmock.NewRows([]string{"acquired"}).AddRow(true))
+	mock.ExpectExec(unlockQuery).WithArgs(lockId).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	lock, err := NewLocker(db, 50*time.Millisecond).Lock(context.Background(), "job")
+	require.NoError(t, err)
+
+	require.NoError(t, lock.Unlock(context.Background()))
+	assert.Error(t, mock.ExpectationsWereMet())
+
+	require.Eventually(t, func() bool {
+		return mock.ExpectationsWereMet() == nil
+	}, 5*time.Second, 5*time.Millisecond)
+}
+
+func TestLocker_FailedUnlockDiscardsConnection(t *testing.T) {
+	db, sqlDB, mock := newDB(t)
+	lockId := hashKey("job")
+
+	mock.ExpectQuery(lockQuery).WithArgs(lockId).WillReturnRows(sqlmock.NewRows([]string{"acquired"}).AddRow(true))
+	mock.ExpectExec(unlockQuery).WithArgs(lockId).WillReturnError(errors.New("unlock failed"))
+
+	lock, err := NewLocker(db, 0).Lock(context.Background(), "job")
+	require.NoError(t, err)
+	require.NoError(t, lock.Unlock(context.Background()))
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// The session may still hold the lock, so it's closed instead of returning to the pool
+	assert.Equal(t, 0, sqlDB.Stats().OpenConnections)
+}
@@ -0,0 +1,95 @@
+package redis
+
+import (
+	"context"
+	"github.com/go-co-op/gocron/v2"
+	"github.com/google/uuid"
+	"github.com/mandarine-io/baselib/pkg/scheduler"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+const (
+	keyPrefix      = "scheduler:lock:"
+	defaultTTL     = 10 * time.Minute
+	defaultMinHold = time.Second
+)
+
+// unlockScript releases the lock if it's still owned by the token. If the lock was held
+// less than the minimum hold time, it's kept until the hold time elapses instead.
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if tonumber(ARGV[2]) > 0 then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return redis.call("DEL", KEYS[1])
+`)
+
+type Config struct {
+	// TTL is the expiration of the lock. It must be greater than the job duration,
+	// otherwise the lock expires and the job may be run by another instance. Defaults to 10 minutes.
+	TTL time.Duration
+	// MinHold is the minimum time the lock is held. It prevents another instance whose
+	// clock lags behind from running a short job again after the lock is released.
+	// It should be less than the interval of the jobs. Defaults to 1 second, if negative,
+	// the lock is released immediately.
+	MinHold time.Duration
+}
+
+type locker struct {
+	rdb redis.UniversalClient
+	cfg Config
+}
+
+type lock struct {
+	rdb      redis.UniversalClient
+	key      string
+	token    string
+	lockedAt time.Time
+	minHold  time.Duration
+}
+
+func NewLocker(rdb redis.UniversalClient, cfg Config) gocron.Locker {
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultTTL
+	}
+	if cfg.MinHold == 0 {
+		cfg.MinHold = defaultMinHold
+	}
+	return &locker{rdb: rdb, cfg: cfg}
+}
+
+func (l *locker) Lock(ctx context.Context, key string) (gocron.Lock, error) {
+	log.Debug().Msgf("lock job %s", key)
+
+	token := uuid.NewString()
+	ok, err := l.rdb.SetNX(ctx, keyPrefix+key, token, l.cfg.TTL).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, scheduler.ErrLockNotAcquired
+	}
+
+	return &lock{
+		rdb:      l.rdb,
+		key:      keyPrefix + key,
+		token:    token,
+		lockedAt: time.Now(),
+		minHold:  l.cfg.MinHold,
+	}, nil
+}
+
+func (l *lock) Unlock(ctx context.Context) error {
+	log.Debug().Msgf("unlock job %s", l.key)
+
+	remaining := l.minHold - time.Since(l.lockedAt)
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return unlockScript.Run(ctx, l.rdb, []string{l.key}, l.token, remaining.Milliseconds()).Err()
+}
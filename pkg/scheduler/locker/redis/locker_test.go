@@ -0,0 +1,94 @@
+package redis
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/mandarine-io/baselib/pkg/scheduler"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func newRedis(t *testing.T) (redis.UniversalClient, *miniredis.Miniredis) {
+	srv := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	t.Cleanup(func() {
+		_ = rdb.Close()
+	})
+	return rdb, srv
+}
+
+func TestLocker_DefaultTTL(t *testing.T) {
+	rdb, srv := newRedis(t)
+	l := NewLocker(rdb, Config{})
+
+	_, err := l.Lock(context.Background(), "job")
+	require.NoError(t, err)
+	assert.Equal(t, defaultTTL, srv.TTL(keyPrefix+"job"))
+
+	// The lock of the crashed instance expires
+	srv.FastForward(defaultTTL)
+	_, err = l.Lock(context.Background(), "job")
+	assert.NoError(t, err)
+}
+
+func TestLocker_LockIsExclusive(t *testing.T) {
+	rdb, srv := newRedis(t)
+	l := NewLocker(rdb, Config{TTL: time.Minute, MinHold: -1})
+
+	lock, err := l.Lock(context.Background(), "job")
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, srv.TTL(keyPrefix+"job"))
+
+	_, err = l.Lock(context.Background(), "job")
+	assert.ErrorIs(t, err, scheduler.ErrLockNotAcquired)
+	_, err = l.Lock(context.Background(), "other")
+	assert.NoError(t, err)
+
+	require.NoError(t, lock.Unlock(context.Background()))
+	assert.False(t, srv.Exists(keyPrefix+"job"))
+
+	_, err = l.Lock(context.Background(), "job")
+	assert.NoError(t, err)
+}
+
+func TestLocker_UnlockKeepsMinHold(t *testing.T) {
+	rdb, srv := newRedis(t)
+	l := NewLocker(rdb, Config{TTL: time.Minute, MinHold: 10 * time.Second})
+
+	lock, err := l.Lock(context.Background(), "job")
+	require.NoError(t, err)
+	require.NoError(t, lock.Unlock(context.Background()))
+
+	ttl := srv.TTL(keyPrefix + "job")
+	assert.Greater(t, ttl, 9*time.Second)
+	assert.LessOrEqual(t, ttl, 10*time.Second)
+
+	_, err = l.Lock(context.Background(), "job")
+	assert.ErrorIs(t, err, scheduler.ErrLockNotAcquired)
+
+	srv.FastForward(10 * time.Second)
+	_, err = l.Lock(context.Background(), "job")
+	assert.NoError(t, err)
+}
+
+func TestLocker_UnlockDoesNotReleaseLockOfAnotherOwner(t *testing.T) {
+	rdb, srv := newRedis(t)
+	l := NewLocker(rdb, Config{TTL: time.Minute, MinHold: -1})
+
+	expired, err := l.Lock(context.Background(), "job")
+	require.NoError(t, err)
+	srv.FastForward(time.Minute)
+
+	_, err = l.Lock(context.Background(), "job")
+	require.NoError(t, err)
+	token, err := srv.Get(keyPrefix + "job")
+	require.NoError(t, err)
+
+	require.NoError(t, expired.Unlock(context.Background()))
+	got, err := srv.Get(keyPrefix + "job")
+	require.NoError(t, err)
+	assert.Equal(t, token, got)
+}
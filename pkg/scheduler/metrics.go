@@ -0,0 +1,64 @@
+package scheduler
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics is the set of Prometheus collectors of the scheduler.
+// It is a Listener: register it in the Prometheus registry and pass to WithListener.
+type Metrics struct {
+	runs          *prometheus.CounterVec
+	duration      *prometheus.HistogramVec
+	running       *prometheus.GaugeVec
+	lastSuccessTs *prometheus.GaugeVec
+}
+
+func NewMetrics(namespace string) *Metrics {
+	const subsystem = "scheduler"
+	return &Metrics{
+		runs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: subsystem,
+			Name: "job_runs_total", Help: "Total number of job runs by status.",
+		}, []string{"job", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: subsystem,
+			Name: "job_run_duration_seconds", Help: "Duration of job runs.",
+			Buckets: prometheus.ExponentialBuckets(0.01, 4, 10),
+		}, []string{"job"}),
+		running: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: subsystem,
+			Name: "job_running", Help: "Number of running instances of the job.",
+		}, []string{"job"}),
+		lastSuccessTs: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: subsystem,
+			Name: "job_last_success_timestamp_seconds", Help: "Unix time of the last successful job run.",
+		}, []string{"job"}),
+	}
+}
+
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.runs.Describe(ch)
+	m.duration.Describe(ch)
+	m.running.Describe(ch)
+	m.lastSuccessTs.Describe(ch)
+}
+
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.runs.Collect(ch)
+	m.duration.Collect(ch)
+	m.running.Collect(ch)
+	m.lastSuccessTs.Collect(ch)
+}
+
+func (m *Metrics) OnJobStart(run Run) {
+	m.running.WithLabelValues(run.JobName).Inc()
+}
+
+func (m *Metrics) OnJobFinish(run Run) {
+	m.running.WithLabelValues(run.JobName).Dec()
+	m.runs.WithLabelValues(run.JobName, string(run.Status)).Inc()
+	m.duration.WithLabelValues(run.JobName).Observe(run.Duration.Seconds())
+	if run.Status == RunStatusSucceeded {
+		m.lastSuccessTs.WithLabelValues(run.JobName).Set(float64(run.FinishedAt.Unix()))
+	}
+}
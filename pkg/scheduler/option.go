@@ -0,0 +1,105 @@
+package scheduler
+
+import (
+	"context"
+	"github.com/go-co-op/gocron/v2"
+	"github.com/jonboulle/clockwork"
+	"time"
+)
+
+const defaultConcurrencyLimit = 10
+
+type options struct {
+	locker      gocron.Locker
+	elector     gocron.Elector
+	listeners   []Listener
+	history     History
+	limit       uint
+	limitMode   gocron.LimitMode
+	location    *time.Location
+	clock       clockwork.Clock
+	logger      gocron.Logger
+	middlewares []Middleware
+	stopTimeout time.Duration
+}
+
+// Middleware wraps the action of every job of the scheduler. Middlewares are applied
+// inside the timeout, the retry policy and panic recovery, so they're called for every attempt.
+type Middleware func(job Job, next func(context.Context) error) func(context.Context) error
+
+type Option func(*options)
+
+// WithDistributedLocker sets the locker used by singleton jobs (see Job.Singleton).
+func WithDistributedLocker(locker gocron.Locker) Option {
+	return func(o *options) {
+		o.locker = locker
+	}
+}
+
+// WithDistributedElector sets the elector: jobs are run only by the instance elected as leader.
+func WithDistributedElector(elector gocron.Elector) Option {
+	return func(o *options) {
+		o.elector = elector
+	}
+}
+
+// WithListener adds the listener of job execution events.
+func WithListener(listener Listener) Option {
+	return func(o *options) {
+		o.listeners = append(o.listeners, listener)
+	}
+}
+
+// WithHistory sets the store of job runs.
+func WithHistory(history History) Option {
+	return func(o *options) {
+		o.history = history
+	}
+}
+
+// WithConcurrencyLimit limits the number of jobs running at the same time. In LimitModeWait
+// the runs exceeding the limit wait in the queue, in LimitModeReschedule they're skipped.
+// If limit is zero, the number of jobs is not limited. Defaults to 10 with LimitModeWait.
+func WithConcurrencyLimit(limit uint, mode gocron.LimitMode) Option {
+	return func(o *options) {
+		o.limit = limit
+		o.limitMode = mode
+	}
+}
+
+// WithLocation sets the time zone of the schedules. Defaults to the local time zone.
+func WithLocation(location *time.Location) Option {
+	return func(o *options) {
+		o.location = location
+	}
+}
+
+// WithClock sets the clock of the scheduler, e.g. clockwork.NewFakeClock() in tests.
+func WithClock(clock clockwork.Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// WithLogger sets the logger of the scheduler internals. Defaults to the global zerolog logger.
+func WithLogger(logger gocron.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithMiddleware adds the middlewares applied to every job. The first middleware is the outermost.
+func WithMiddleware(middlewares ...Middleware) Option {
+	return func(o *options) {
+		o.middlewares = append(o.middlewares, middlewares...)
+	}
+}
+
+// WithStopTimeout sets the maximum time the scheduler waits for the running jobs on shutdown.
+// It should be longer than the deadline of Shutdown, then it only bounds the background wait
+// for the jobs ignoring the cancellation. Defaults to 24 hours.
+func WithStopTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.stopTimeout = timeout
+	}
+}
@@ -0,0 +1,122 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"github.com/jonboulle/clockwork"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"runtime/debug"
+	"time"
+)
+
+const (
+	defaultRetryInitialBackoff = time.Second
+	defaultRetryMultiplier     = 2
+)
+
+// RetryPolicy defines how a failed job run is retried within the same run.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts including the first one.
+	// If less than 2, the job is not retried.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. Defaults to 1 second.
+	InitialBackoff time.Duration
+	// MaxBackoff limits the delay between retries. If zero, the delay is not limited.
+	MaxBackoff time.Duration
+	// Multiplier is the factor of the delay growth. Defaults to 2.
+	Multiplier float64
+}
+
+// PanicError is returned by the run of the job whose action panicked.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("job panicked: %v", e.Value)
+}
+
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.InitialBackoff
+	if delay <= 0 {
+		delay = defaultRetryInitialBackoff
+	}
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = defaultRetryMultiplier
+	}
+
+	for i := 1; i < attempt; i++ {
+		delay = time.Duration(float64(delay) * multiplier)
+		if p.MaxBackoff > 0 && delay >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return delay
+}
+
+// execute runs the job action applying the timeout, the retry policy and panic recovery.
+func execute(ctx context.Context, job Job, clock clockwork.Clock) error {
+	attempts := job.Retry.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		err = runAttempt(ctx, job)
+		if err == nil || attempt == attempts {
+			return err
+		}
+
+		delay := job.Retry.backoff(attempt)
+		log.Warn().Err(err).Msgf("job %s failed, retry %d/%d in %s", job.Name, attempt, attempts-1, delay)
+
+		timer := clock.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.Chan():
+		}
+	}
+	return err
+}
+
+// runAttempt runs the action once. If the timeout is exceeded, the attempt fails without waiting
+// for the action, so an action ignoring its context doesn't hold the concurrency slot.
+func runAttempt(ctx context.Context, job Job) error {
+	if job.Timeout <= 0 {
+		return callAction(ctx, job.Action)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, job.Timeout)
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- callAction(ctx, job.Action)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return errors.Wrapf(ctx.Err(), "job %s timed out after %s", job.Name, job.Timeout)
+	}
+}
+
+func callAction(ctx context.Context, action func(context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+
+	return action(ctx)
+}
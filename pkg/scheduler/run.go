@@ -0,0 +1,57 @@
+package scheduler
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"time"
+)
+
+type RunStatus string
+
+const (
+	RunStatusRunning   RunStatus = "running"
+	RunStatusSucceeded RunStatus = "succeeded"
+	RunStatusFailed    RunStatus = "failed"
+)
+
+// Run is the record of a single job execution.
+type Run struct {
+	JobID      uuid.UUID
+	JobName    string
+	Status     RunStatus
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Duration   time.Duration
+	Error      string
+	NextRun    time.Time
+}
+
+// JobInfo is the current state of the scheduled job.
+type JobInfo struct {
+	ID             uuid.UUID
+	Name           string
+	CronExpression string
+	Schedule       string
+	Singleton      bool
+	Running        bool
+	Paused         bool
+	NextRun        time.Time
+	LastRun        *Run
+	RunCount       uint64
+	FailureCount   uint64
+}
+
+// Listener receives job execution events. Methods are called synchronously
+// in the job goroutine, so they should not block.
+type Listener interface {
+	OnJobStart(run Run)
+	OnJobFinish(run Run)
+}
+
+// History persists job runs. The job id is generated on every start of the process,
+// so the runs are keyed by the job name.
+type History interface {
+	Save(ctx context.Context, run Run) error
+	// List returns the last runs of the job with the name, newest first.
+	List(ctx context.Context, jobName string, limit int) ([]Run, error)
+}
@@ -0,0 +1,112 @@
+package scheduler
+
+import (
+	"fmt"
+	"github.com/go-co-op/gocron/v2"
+	"strings"
+	"time"
+)
+
+// Schedule defines when the job runs. The zero value means that Job.CronExpression is used.
+type Schedule struct {
+	definition  gocron.JobDefinition
+	description string
+}
+
+// TimeOfDay is the wall clock time in the scheduler location.
+type TimeOfDay struct {
+	Hour, Minute, Second uint
+}
+
+func (t TimeOfDay) String() string {
+	return fmt.Sprintf("%02d:%02d:%02d", t.Hour, t.Minute, t.Second)
+}
+
+// Every runs the job with the fixed interval starting from the moment the job is added.
+func Every(interval time.Duration) Schedule {
+	return Schedule{
+		definition:  gocron.DurationJob(interval),
+		description: "every " + interval.String(),
+	}
+}
+
+// At runs the job once at the given time. If the time is in the past, the job runs immediately.
+func At(t time.Time) Schedule {
+	return Schedule{
+		definition:  gocron.OneTimeJob(gocron.OneTimeJobStartDateTime(t)),
+		description: "at " + t.Format(time.RFC3339),
+	}
+}
+
+// Daily runs the job every day at the given times.
+func Daily(at TimeOfDay, more ...TimeOfDay) Schedule {
+	times := append([]TimeOfDay{at}, more...)
+	return Schedule{
+		definition:  gocron.DailyJob(1, atTimes(times)),
+		description: "daily at " + joinTimes(times),
+	}
+}
+
+// Weekly runs the job on the given days of the week at the given times.
+func Weekly(days []time.Weekday, at TimeOfDay, more ...TimeOfDay) Schedule {
+	times := append([]TimeOfDay{at}, more...)
+
+	names := make([]string, 0, len(days))
+	var weekdays gocron.Weekdays
+	if len(days) > 0 {
+		weekdays = gocron.NewWeekdays(days[0], days[1:]...)
+		for _, day := range days {
+			names = append(names, day.String())
+		}
+	}
+
+	return Schedule{
+		definition:  gocron.WeeklyJob(1, weekdays, atTimes(times)),
+		description: fmt.Sprintf("weekly on %s at %s", strings.Join(names, ","), joinTimes(times)),
+	}
+}
+
+// Cron runs the job by the cron expression. If withSeconds is true, the expression has
+// the leading seconds field. If loc is not nil, the expression is evaluated in that location
+// instead of the scheduler one.
+func Cron(expression string, withSeconds bool, loc *time.Location) Schedule {
+	if loc != nil {
+		expression = "CRON_TZ=" + loc.String() + " " + expression
+	}
+	return Schedule{
+		definition:  gocron.CronJob(expression, withSeconds),
+		description: expression,
+	}
+}
+
+func (s Schedule) String() string {
+	return s.description
+}
+
+func (s Schedule) isZero() bool {
+	return s.definition == nil
+}
+
+// schedule returns the schedule of the job falling back to the cron expression.
+func (j Job) schedule() Schedule {
+	if !j.Schedule.isZero() {
+		return j.Schedule
+	}
+	return Cron(j.CronExpression, false, nil)
+}
+
+func atTimes(times []TimeOfDay) gocron.AtTimes {
+	at := make([]gocron.AtTime, 0, len(times))
+	for _, t := range times {
+		at = append(at, gocron.NewAtTime(t.Hour, t.Minute, t.Second))
+	}
+	return gocron.NewAtTimes(at[0], at[1:]...)
+}
+
+func joinTimes(times []TimeOfDay) string {
+	s := make([]string, 0, len(times))
+	for _, t := range times {
+		s = append(s, t.String())
+	}
+	return strings.Join(s, ",")
+}
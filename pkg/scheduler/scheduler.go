@@ -4,30 +4,119 @@ import (
 	"context"
 	"github.com/go-co-op/gocron/v2"
 	"github.com/google/uuid"
+	"github.com/jonboulle/clockwork"
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	historySaveTimeout = 10 * time.Second
+	defaultStopTimeout = 24 * time.Hour
+)
+
+var (
+	ErrLockNotAcquired = errors.New("lock is not acquired")
+	ErrNoLocker        = errors.New("distributed locker is not configured")
+	ErrNoHistory       = errors.New("job history is not configured")
+	ErrJobNotFound     = errors.New("job not found")
+	ErrJobPaused       = errors.New("job is paused")
 )
 
 type Job struct {
-	Ctx            context.Context
-	Name           string
+	Ctx  context.Context
+	Name string
+	// CronExpression is the schedule of the job if Schedule is not set.
 	CronExpression string
-	Action         func(context.Context) error
+	// Schedule is the schedule of the job: Every, At, Daily, Weekly or Cron.
+	Schedule Schedule
+	Action   func(context.Context) error
+	// Singleton makes the job run on a single instance of the cluster at a time.
+	// It requires the distributed locker, the job name is used as the lock key.
+	Singleton bool
+	// SkipIfRunning skips the run if the previous run of the job on this instance is still going.
+	SkipIfRunning bool
+	// Timeout limits the duration of a single attempt. If zero, the attempt is not limited.
+	Timeout time.Duration
+	// Retry is the retry policy of failed attempts. By default, failed runs are not retried.
+	Retry RetryPolicy
+}
+
+type jobState struct {
+	job     Job
+	gocron  gocron.Job
+	running int
+	paused  bool
+	lastRun *Run
+	runs    uint64
+	fails   uint64
 }
 
 type Scheduler struct {
 	scheduler gocron.Scheduler
+	opts      *options
+
+	mu   sync.RWMutex
+	jobs map[uuid.UUID]*jobState
+
+	// ctx is canceled when the shutdown deadline is exceeded to interrupt running jobs
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
-func MustSetupJobScheduler() *Scheduler {
-	scheduler, err := gocron.NewScheduler(
-		gocron.WithLogger(schedulerLogger{}),
-		gocron.WithLimitConcurrentJobs(10, gocron.LimitModeWait),
-	)
+// NewScheduler creates the scheduler. The scheduler must be started with Start.
+func NewScheduler(opts ...Option) (*Scheduler, error) {
+	o := &options{
+		limit:       defaultConcurrencyLimit,
+		limitMode:   gocron.LimitModeWait,
+		clock:       clockwork.NewRealClock(),
+		logger:      schedulerLogger{},
+		stopTimeout: defaultStopTimeout,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	schedulerOpts := []gocron.SchedulerOption{
+		gocron.WithLogger(o.logger),
+		gocron.WithClock(o.clock),
+		// The deadline of the shutdown is controlled by the context of Shutdown,
+		// the stop timeout only bounds the wait for the jobs ignoring the cancellation
+		gocron.WithStopTimeout(o.stopTimeout),
+	}
+	if o.limit > 0 {
+		schedulerOpts = append(schedulerOpts, gocron.WithLimitConcurrentJobs(o.limit, o.limitMode))
+	}
+	if o.location != nil {
+		schedulerOpts = append(schedulerOpts, gocron.WithLocation(o.location))
+	}
+	if o.elector != nil {
+		schedulerOpts = append(schedulerOpts, gocron.WithDistributedElector(o.elector))
+	}
+
+	scheduler, err := gocron.NewScheduler(schedulerOpts...)
 	if err != nil {
-		log.Fatal().Stack().Err(err).Msg("failed to setup job scheduler")
+		return nil, err
 	}
 
-	return &Scheduler{scheduler}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		scheduler: scheduler,
+		opts:      o,
+		jobs:      make(map[uuid.UUID]*jobState),
+		ctx:       ctx,
+		cancel:    cancel,
+	}, nil
+}
+
+func MustSetupJobScheduler(opts ...Option) *Scheduler {
+	scheduler, err := NewScheduler(opts...)
+	if err != nil {
+		log.Fatal().Stack().Err(err).Msg("failed to setup job scheduler")
+	}
+	return scheduler
 }
 
 func (s *Scheduler) Start() {
@@ -35,17 +124,355 @@ func (s *Scheduler) Start() {
 }
 
 func (s *Scheduler) AddJob(job Job) (uuid.UUID, error) {
+	id := uuid.New()
+	jobOpts, err := s.jobOptions(id, job)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	state := &jobState{job: job}
+	s.mu.Lock()
+	s.jobs[id] = state
+	s.mu.Unlock()
+
 	j, err := s.scheduler.NewJob(
-		gocron.CronJob(job.CronExpression, false),
-		gocron.NewTask(job.Action, job.Ctx),
-		gocron.WithName(job.Name),
+		job.schedule().definition,
+		gocron.NewTask(s.track(id, job)),
+		jobOpts...,
 	)
 	if j == nil {
+		s.mu.Lock()
+		delete(s.jobs, id)
+		s.mu.Unlock()
 		return uuid.Nil, err
 	}
+
+	s.mu.Lock()
+	state.gocron = j
+	s.mu.Unlock()
+
 	return j.ID(), err
 }
 
-func (s *Scheduler) Shutdown() error {
-	return s.scheduler.Shutdown()
+// UpdateJob replaces the schedule, the action and the options of the job keeping its id and statistics.
+func (s *Scheduler) UpdateJob(id uuid.UUID, job Job) error {
+	log.Debug().Msgf("update job %s", id)
+
+	state, err := s.state(id)
+	if err != nil {
+		return err
+	}
+
+	jobOpts, err := s.jobOptions(id, job)
+	if err != nil {
+		return err
+	}
+
+	j, err := s.scheduler.Update(
+		id,
+		job.schedule().definition,
+		gocron.NewTask(s.track(id, job)),
+		jobOpts...,
+	)
+	if err != nil {
+		return mapJobError(err)
+	}
+
+	s.mu.Lock()
+	state.job = job
+	state.gocron = j
+	s.mu.Unlock()
+
+	return nil
+}
+
+// RemoveJob removes the job from the scheduler. The running job is not interrupted.
+func (s *Scheduler) RemoveJob(id uuid.UUID) error {
+	log.Debug().Msgf("remove job %s", id)
+
+	if err := s.scheduler.RemoveJob(id); err != nil {
+		return mapJobError(err)
+	}
+
+	s.mu.Lock()
+	delete(s.jobs, id)
+	s.mu.Unlock()
+
+	return nil
+}
+
+// RunNow runs the job immediately regardless of its schedule.
+func (s *Scheduler) RunNow(id uuid.UUID) error {
+	log.Debug().Msgf("run job %s now", id)
+
+	state, err := s.state(id)
+	if err != nil {
+		return err
+	}
+
+	s.mu.RLock()
+	paused, j := state.paused, state.gocron
+	s.mu.RUnlock()
+
+	if paused {
+		return ErrJobPaused
+	}
+	return mapJobError(j.RunNow())
+}
+
+// PauseJob skips the scheduled runs of the job until ResumeJob is called.
+func (s *Scheduler) PauseJob(id uuid.UUID) error {
+	return s.setPaused(id, true)
+}
+
+// ResumeJob resumes the scheduled runs of the paused job.
+func (s *Scheduler) ResumeJob(id uuid.UUID) error {
+	return s.setPaused(id, false)
+}
+
+// Jobs returns the state of all scheduled jobs ordered by name.
+func (s *Scheduler) Jobs() []JobInfo {
+	s.mu.RLock()
+	infos := make([]JobInfo, 0, len(s.jobs))
+	for id, state := range s.jobs {
+		infos = append(infos, state.info(id))
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
+
+// Job returns the state of the scheduled job.
+func (s *Scheduler) Job(id uuid.UUID) (JobInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state, ok := s.jobs[id]
+	if !ok {
+		return JobInfo{}, ErrJobNotFound
+	}
+	return state.info(id), nil
+}
+
+// Runs returns the last runs of the job from the history, newest first. The runs are found
+// by the job name, so they include the runs of the previous processes.
+func (s *Scheduler) Runs(ctx context.Context, id uuid.UUID, limit int) ([]Run, error) {
+	if s.opts.history == nil {
+		return nil, ErrNoHistory
+	}
+
+	s.mu.RLock()
+	state, ok := s.jobs[id]
+	var name string
+	if ok {
+		name = state.job.Name
+	}
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+
+	return s.opts.history.List(ctx, name, limit)
+}
+
+// Shutdown stops scheduling and waits until the running jobs are finished. If ctx is done
+// earlier, the contexts of the running jobs are canceled and ctx error is returned.
+//
+// The scheduler keeps waiting for the jobs in the background after ctx error is returned,
+// until they return or the stop timeout (see WithStopTimeout) passes.
+func (s *Scheduler) Shutdown(ctx context.Context) error {
+	log.Debug().Msg("shutdown job scheduler")
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.scheduler.Shutdown()
+	}()
+
+	select {
+	case err := <-errCh:
+		s.cancel()
+		return err
+	case <-ctx.Done():
+		log.Warn().Msg("job scheduler shutdown deadline exceeded, cancel running jobs")
+		s.cancel()
+		return ctx.Err()
+	}
+}
+
+func (s *Scheduler) jobOptions(id uuid.UUID, job Job) ([]gocron.JobOption, error) {
+	jobOpts := []gocron.JobOption{
+		gocron.WithIdentifier(id),
+		gocron.WithName(job.Name),
+	}
+	if job.Singleton {
+		if s.opts.locker == nil {
+			return nil, ErrNoLocker
+		}
+		jobOpts = append(jobOpts, gocron.WithDistributedJobLocker(s.opts.locker))
+	}
+	if job.SkipIfRunning {
+		jobOpts = append(jobOpts, gocron.WithSingletonMode(gocron.LimitModeReschedule))
+	}
+	return jobOpts, nil
+}
+
+func (s *Scheduler) state(id uuid.UUID) (*jobState, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state, ok := s.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	return state, nil
+}
+
+func (s *Scheduler) setPaused(id uuid.UUID, paused bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.jobs[id]
+	if !ok {
+		return ErrJobNotFound
+	}
+	log.Debug().Msgf("set job %s paused %t", id, paused)
+	state.paused = paused
+	return nil
+}
+
+func (s *Scheduler) paused(id uuid.UUID) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state, ok := s.jobs[id]
+	return ok && state.paused
+}
+
+// track wraps the job action to record its runs.
+func (s *Scheduler) track(id uuid.UUID, job Job) func() error {
+	job.Action = s.wrap(job)
+
+	return func() error {
+		if s.paused(id) {
+			log.Debug().Msgf("job %s is paused, skip run", job.Name)
+			return nil
+		}
+
+		ctx := job.Ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		stop := context.AfterFunc(s.ctx, cancel)
+		defer stop()
+
+		run := Run{
+			JobID:     id,
+			JobName:   job.Name,
+			Status:    RunStatusRunning,
+			StartedAt: s.opts.clock.Now(),
+		}
+		s.onStart(run)
+
+		err := execute(ctx, job, s.opts.clock)
+
+		run.FinishedAt = s.opts.clock.Now()
+		run.Duration = run.FinishedAt.Sub(run.StartedAt)
+		run.Status = RunStatusSucceeded
+		if err != nil {
+			run.Status = RunStatusFailed
+			run.Error = err.Error()
+			log.Error().Stack().Err(err).Msgf("job %s failed", job.Name)
+		}
+		s.onFinish(run)
+
+		return err
+	}
+}
+
+// wrap applies the middlewares to the job action.
+func (s *Scheduler) wrap(job Job) func(context.Context) error {
+	action := job.Action
+	for i := len(s.opts.middlewares) - 1; i >= 0; i-- {
+		action = s.opts.middlewares[i](job, action)
+	}
+	return action
+}
+
+func (s *Scheduler) onStart(run Run) {
+	s.mu.Lock()
+	if state, ok := s.jobs[run.JobID]; ok {
+		state.running++
+	}
+	s.mu.Unlock()
+
+	for _, l := range s.opts.listeners {
+		l.OnJobStart(run)
+	}
+}
+
+func (s *Scheduler) onFinish(run Run) {
+	// The gocron job is replaced by UpdateJob, so it's copied under the lock
+	var job gocron.Job
+	s.mu.Lock()
+	if state, ok := s.jobs[run.JobID]; ok {
+		state.running--
+		state.runs++
+		if run.Status == RunStatusFailed {
+			state.fails++
+		}
+		lastRun := run
+		state.lastRun = &lastRun
+		job = state.gocron
+	}
+	s.mu.Unlock()
+
+	if job != nil {
+		run.NextRun, _ = job.NextRun()
+	}
+
+	for _, l := range s.opts.listeners {
+		l.OnJobFinish(run)
+	}
+
+	if s.opts.history != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), historySaveTimeout)
+		defer cancel()
+		if err := s.opts.history.Save(ctx, run); err != nil {
+			log.Error().Stack().Err(err).Msgf("failed to save run of job %s", run.JobName)
+		}
+	}
+}
+
+func (st *jobState) info(id uuid.UUID) JobInfo {
+	info := JobInfo{
+		ID:             id,
+		Name:           st.job.Name,
+		CronExpression: st.job.CronExpression,
+		Schedule:       st.job.schedule().String(),
+		Singleton:      st.job.Singleton,
+		Running:        st.running > 0,
+		Paused:         st.paused,
+		RunCount:       st.runs,
+		FailureCount:   st.fails,
+	}
+	if st.lastRun != nil {
+		lastRun := *st.lastRun
+		info.LastRun = &lastRun
+	}
+	if st.gocron != nil {
+		info.NextRun, _ = st.gocron.NextRun()
+	}
+	return info
+}
+
+func mapJobError(err error) error {
+	if errors.Is(err, gocron.ErrJobNotFound) {
+		return ErrJobNotFound
+	}
+	return err
 }
@@ -0,0 +1,147 @@
+package scheduler
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestScheduler_ShutdownCancelsRunningJobsAfterDeadline(t *testing.T) {
+	s, err := NewScheduler()
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	id, err := s.AddJob(Job{
+		Name:     "long",
+		Schedule: Every(time.Hour),
+		Action: func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			close(canceled)
+			return ctx.Err()
+		},
+	})
+	require.NoError(t, err)
+
+	s.Start()
+	require.NoError(t, s.RunNow(id))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, s.Shutdown(ctx), context.DeadlineExceeded)
+
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "the context of the running job is not canceled")
+	}
+}
+
+func TestScheduler_ShutdownReturnsAtDeadlineIfJobIgnoresCancellation(t *testing.T) {
+	// The default stop timeout is 24 hours, Shutdown must not wait for it
+	s, err := NewScheduler()
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+
+	id, err := s.AddJob(Job{
+		Name:     "stuck",
+		Schedule: Every(time.Hour),
+		Action: func(context.Context) error {
+			close(started)
+			<-release
+			return nil
+		},
+	})
+	require.NoError(t, err)
+
+	s.Start()
+	require.NoError(t, s.RunNow(id))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	shutdownStarted := time.Now()
+	assert.ErrorIs(t, s.Shutdown(ctx), context.DeadlineExceeded)
+	assert.Less(t, time.Since(shutdownStarted), time.Second)
+}
+
+func TestScheduler_JobsStopAfterShutdown(t *testing.T) {
+	clock := clockwork.NewFakeClock()
+	s, err := NewScheduler(WithClock(clock))
+	require.NoError(t, err)
+
+	runs := make(chan struct{}, 10)
+	_, err = s.AddJob(Job{
+		Name:     "ticker",
+		Schedule: Every(time.Minute),
+		Action: func(context.Context) error {
+			runs <- struct{}{}
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	s.Start()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for i := 0; i < 2; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(time.Minute)
+		select {
+		case <-runs:
+		case <-ctx.Done():
+			require.FailNow(t, "the job is not run")
+		}
+	}
+
+	require.NoError(t, s.Shutdown(ctx))
+
+	clock.Advance(time.Hour)
+	select {
+	case <-runs:
+		assert.Fail(t, "the job is run after shutdown")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+type fakeHistory struct {
+	jobName string
+}
+
+func (h *fakeHistory) Save(context.Context, Run) error {
+	return nil
+}
+
+func (h *fakeHistory) List(_ context.Context, jobName string, _ int) ([]Run, error) {
+	h.jobName = jobName
+	return []Run{{JobName: jobName}}, nil
+}
+
+func TestScheduler_RunsAreListedByJobName(t *testing.T) {
+	history := &fakeHistory{}
+	s, err := NewScheduler(WithHistory(history))
+	require.NoError(t, err)
+
+	id, err := s.AddJob(Job{Name: "report", Schedule: Every(time.Hour), Action: func(context.Context) error {
+		return nil
+	}})
+	require.NoError(t, err)
+
+	runs, err := s.Runs(context.Background(), id, 10)
+	require.NoError(t, err)
+	assert.Len(t, runs, 1)
+	assert.Equal(t, "report", history.jobName)
+
+	_, err = s.Runs(context.Background(), uuid.New(), 10)
+	assert.ErrorIs(t, err, ErrJobNotFound)
+}
@@ -0,0 +1,62 @@
+package sse
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mandarine-io/baselib/pkg/websocket"
+	"github.com/rs/zerolog/log"
+	"io"
+	"net/http"
+)
+
+// ClientIdFunc resolves the client id of the request, e.g. from the authenticated user.
+type ClientIdFunc func(c *gin.Context) (string, error)
+
+// StreamHandler returns the gin handler which opens the event stream of the client.
+func (p *Pool) StreamHandler(clientIdFunc ClientIdFunc) gin.HandlerFunc {
+	log.Debug().Msg("setup sse stream handler")
+	return func(c *gin.Context) {
+		clientId, err := clientIdFunc(c)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusUnauthorized, err)
+			return
+		}
+
+		if err := p.Register(clientId, c.Request, c.Writer); err != nil {
+			log.Debug().Err(err).Msgf("sse stream of client %s is finished with error", clientId)
+		}
+	}
+}
+
+// MessageHandler returns the gin handler which receives a message posted by the client
+// and passes it to the registered handlers. The session id is taken from the sessionId query parameter.
+func (p *Pool) MessageHandler(clientIdFunc ClientIdFunc) gin.HandlerFunc {
+	log.Debug().Msg("setup sse message handler")
+	return func(c *gin.Context) {
+		clientId, err := clientIdFunc(c)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusUnauthorized, err)
+			return
+		}
+
+		var body io.Reader = c.Request.Body
+		if p.opts.MaxMessageSize > 0 {
+			body = http.MaxBytesReader(c.Writer, c.Request.Body, p.opts.MaxMessageSize)
+		}
+		msg, err := io.ReadAll(body)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusRequestEntityTooLarge, err)
+			return
+		}
+
+		sessionId := c.Query("sessionId")
+		if sessionId != "" {
+			if _, err := p.session(clientId, sessionId); err != nil {
+				_ = c.AbortWithError(http.StatusNotFound, err)
+				return
+			}
+		}
+
+		p.dispatch(websocket.NewClientMessage(clientId, sessionId, msg))
+		c.Status(http.StatusAccepted)
+	}
+}
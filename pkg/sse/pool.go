@@ -0,0 +1,433 @@
+package sse
+
+import (
+	"context"
+	syserrors "errors"
+	"github.com/google/uuid"
+	"github.com/mandarine-io/baselib/pkg/websocket"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultHeartbeatPeriod  = 30 * time.Second
+	defaultSendQueueSize    = 256
+	defaultReplayBufferSize = 1024
+
+	sessionEvent = "session"
+)
+
+var (
+	ErrStreamingUnsupported = syserrors.New("streaming unsupported")
+	ErrInvalidEventName     = syserrors.New("event name must not contain line breaks")
+)
+
+type Options struct {
+	// Size is the maximum number of registered sessions.
+	Size int
+	// SendQueueSize is the capacity of the per-session write queue.
+	SendQueueSize int
+	// SlowConsumerPolicy is applied when the write queue of a session is full.
+	SlowConsumerPolicy websocket.SlowConsumerPolicy
+	// HeartbeatPeriod is the period of comment lines sent to keep the connection alive through proxies.
+	HeartbeatPeriod time.Duration
+	// ReplayBufferSize is the number of last events kept for Last-Event-ID resume.
+	// If negative, resume is disabled.
+	ReplayBufferSize int
+	// RetryInterval is sent to clients as the reconnection time. If zero, the browser default is used.
+	RetryInterval time.Duration
+	// MaxMessageSize is the maximum size of an incoming message posted by a client. If zero, there is no limit.
+	MaxMessageSize int64
+}
+
+func (o Options) withDefaults() Options {
+	if o.SendQueueSize <= 0 {
+		o.SendQueueSize = defaultSendQueueSize
+	}
+	if o.HeartbeatPeriod <= 0 {
+		o.HeartbeatPeriod = defaultHeartbeatPeriod
+	}
+	if o.ReplayBufferSize == 0 {
+		o.ReplayBufferSize = defaultReplayBufferSize
+	}
+	if o.ReplayBufferSize < 0 {
+		o.ReplayBufferSize = 0
+	}
+	return o
+}
+
+// Pool is the Server-Sent Events counterpart of websocket.Pool. It has the same
+// Register/Send/Broadcast/handler semantics and reuses websocket message types and errors,
+// so the same handlers and the same sending code can serve both transports.
+// Messages from clients are received with plain HTTP requests (see Pool.MessageHandler).
+type Pool struct {
+	mu       sync.RWMutex
+	clients  map[string]map[string]*session
+	count    atomic.Int64
+	handlers []websocket.Handler
+	opts     Options
+	replay   *replayBuffer
+
+	closed atomic.Bool
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func NewPool(size int) *Pool {
+	return NewPoolWithOptions(Options{Size: size})
+}
+
+func NewPoolWithOptions(opts Options) *Pool {
+	opts = opts.withDefaults()
+	return &Pool{
+		clients:  make(map[string]map[string]*session),
+		handlers: make([]websocket.Handler, 0),
+		opts:     opts,
+		replay:   newReplayBuffer(opts.ReplayBufferSize),
+		done:     make(chan struct{}),
+	}
+}
+
+// Register opens the event stream of a new client session and blocks until the client
+// disconnects or the pool is closed. The first event of the stream is "session" with the session id.
+// If the request has Last-Event-ID header (or lastEventId query parameter), the missed events
+// still kept in the replay buffer are sent first.
+func (p *Pool) Register(clientId string, r *http.Request, w http.ResponseWriter) error {
+	log.Debug().Msgf("register sse client %s", clientId)
+
+	if p.closed.Load() {
+		http.Error(w, websocket.ErrPoolClosed.Error(), http.StatusServiceUnavailable)
+		return websocket.ErrPoolClosed
+	}
+	if p.count.Load() >= int64(p.opts.Size) {
+		http.Error(w, websocket.ErrPoolIsFull.Error(), http.StatusServiceUnavailable)
+		return websocket.ErrPoolIsFull
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, ErrStreamingUnsupported.Error(), http.StatusInternalServerError)
+		return ErrStreamingUnsupported
+	}
+
+	s := newSession(clientId, uuid.NewString(), w, flusher, p.opts.SendQueueSize)
+
+	// The check and the wait group are updated under the lock, so Close and Shutdown
+	// either see the session or it's rejected here
+	p.mu.Lock()
+	if p.closed.Load() {
+		p.mu.Unlock()
+		http.Error(w, websocket.ErrPoolClosed.Error(), http.StatusServiceUnavailable)
+		return websocket.ErrPoolClosed
+	}
+	sessions, ok := p.clients[clientId]
+	if !ok {
+		sessions = make(map[string]*session)
+		p.clients[clientId] = sessions
+	}
+	sessions[s.sessionId] = s
+	p.count.Add(1)
+	p.wg.Add(1)
+	p.mu.Unlock()
+
+	defer func() {
+		p.unregisterSession(s)
+		p.wg.Done()
+		log.Debug().Msgf("sse client %s session %s is disconnected", clientId, s.sessionId)
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if err := s.writeHello(p.opts.RetryInterval); err != nil {
+		return err
+	}
+
+	if lastId, ok := lastEventId(r); ok {
+		events, replayedId := p.replay.since(clientId, lastId)
+		for _, e := range events {
+			if err := s.writeEvent(e); err != nil {
+				return err
+			}
+		}
+		s.replayedId = replayedId
+	}
+
+	return s.writeLoop(r.Context(), p)
+}
+
+// Unregister closes all sessions of the client.
+func (p *Pool) Unregister(clientId string) error {
+	log.Debug().Msgf("unregister sse client %s", clientId)
+
+	p.mu.Lock()
+	sessions, ok := p.clients[clientId]
+	if ok {
+		delete(p.clients, clientId)
+		p.count.Add(-int64(len(sessions)))
+	}
+	p.mu.Unlock()
+
+	if !ok {
+		return websocket.ErrClientNotFound
+	}
+
+	for _, s := range sessions {
+		s.close()
+	}
+	return nil
+}
+
+// UnregisterSession closes the single session of the client.
+func (p *Pool) UnregisterSession(clientId string, sessionId string) error {
+	s, err := p.session(clientId, sessionId)
+	if err != nil {
+		return err
+	}
+	p.unregisterSession(s)
+	return nil
+}
+
+// Count returns the number of registered sessions.
+func (p *Pool) Count() int {
+	return int(p.count.Load())
+}
+
+// ClientCount returns the number of clients with at least one session.
+func (p *Pool) ClientCount() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.clients)
+}
+
+func (p *Pool) RegisterHandler(h websocket.Handler) {
+	p.handlers = append(p.handlers, h)
+}
+
+// Send sends the message to every session of the client. It never blocks.
+func (p *Pool) Send(clientId string, msg []byte) error {
+	return p.SendEvent(clientId, "", msg)
+}
+
+// SendEvent sends the named event to every session of the client. It never blocks.
+func (p *Pool) SendEvent(clientId string, name string, msg []byte) error {
+	log.Debug().Msg("send sse client message")
+
+	if p.closed.Load() {
+		return websocket.ErrPoolClosed
+	}
+	if !validEventName(name) {
+		return ErrInvalidEventName
+	}
+
+	e := p.replay.add(clientId, name, msg)
+
+	sessions := p.clientSessions(clientId)
+	if len(sessions) == 0 {
+		return websocket.ErrClientNotFound
+	}
+
+	var errs []error
+	for _, s := range sessions {
+		if err := p.enqueue(s, e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return syserrors.Join(errs...)
+}
+
+// Broadcast sends the message to every session.
+func (p *Pool) Broadcast(msg []byte) error {
+	return p.BroadcastEvent("", msg)
+}
+
+// BroadcastEvent sends the named event to every session.
+func (p *Pool) BroadcastEvent(name string, msg []byte) error {
+	log.Debug().Msg("send sse broadcast message")
+
+	if p.closed.Load() {
+		return websocket.ErrPoolClosed
+	}
+	if !validEventName(name) {
+		return ErrInvalidEventName
+	}
+
+	e := p.replay.add("", name, msg)
+	for _, s := range p.allSessions() {
+		_ = p.enqueue(s, e)
+	}
+
+	return nil
+}
+
+// Close closes all sessions immediately.
+func (p *Pool) Close() error {
+	if !p.markClosed() {
+		return websocket.ErrPoolClosed
+	}
+	close(p.done)
+
+	p.closeAll()
+	p.wg.Wait()
+
+	return nil
+}
+
+// Shutdown stops accepting registrations and messages, flushes the write queues
+// and waits until all streams are finished. If ctx is done earlier, the remaining
+// sessions are closed immediately and ctx error is returned.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	if !p.markClosed() {
+		return websocket.ErrPoolClosed
+	}
+	close(p.done)
+
+	log.Debug().Msg("shutdown sse pool")
+
+	doneCh := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(doneCh)
+	}()
+
+	select {
+	case <-doneCh:
+		return nil
+	case <-ctx.Done():
+		log.Warn().Msg("sse pool shutdown deadline exceeded, close remaining sessions")
+		p.closeAll()
+		return ctx.Err()
+	}
+}
+
+// markClosed marks the pool closed under the lock, so no session is registered after it.
+// It returns false if the pool is already closed.
+func (p *Pool) markClosed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closed.CompareAndSwap(false, true)
+}
+
+func (p *Pool) dispatch(msg websocket.ClientMessage) {
+	for _, h := range p.handlers {
+		h(msg)
+	}
+}
+
+func (p *Pool) enqueue(s *session, e event) error {
+	err := s.enqueue(e)
+	if err == nil {
+		return nil
+	}
+
+	if syserrors.Is(err, websocket.ErrSlowConsumer) {
+		log.Warn().Msgf("sse client %s session %s is a slow consumer", s.clientId, s.sessionId)
+		if p.opts.SlowConsumerPolicy == websocket.SlowConsumerDisconnect {
+			p.unregisterSession(s)
+		}
+	}
+
+	return err
+}
+
+func (p *Pool) session(clientId string, sessionId string) (*session, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	sessions, ok := p.clients[clientId]
+	if !ok {
+		return nil, websocket.ErrClientNotFound
+	}
+	s, ok := sessions[sessionId]
+	if !ok {
+		return nil, websocket.ErrSessionNotFound
+	}
+	return s, nil
+}
+
+func (p *Pool) clientSessions(clientId string) []*session {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	sessions := make([]*session, 0, len(p.clients[clientId]))
+	for _, s := range p.clients[clientId] {
+		sessions = append(sessions, s)
+	}
+	return sessions
+}
+
+func (p *Pool) allSessions() []*session {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	sessions := make([]*session, 0, p.count.Load())
+	for _, clientSessions := range p.clients {
+		for _, s := range clientSessions {
+			sessions = append(sessions, s)
+		}
+	}
+	return sessions
+}
+
+func (p *Pool) closeAll() {
+	p.mu.Lock()
+	sessions := make([]*session, 0, p.count.Load())
+	for _, clientSessions := range p.clients {
+		for _, s := range clientSessions {
+			sessions = append(sessions, s)
+		}
+	}
+	p.clients = make(map[string]map[string]*session)
+	p.count.Store(0)
+	p.mu.Unlock()
+
+	for _, s := range sessions {
+		s.close()
+	}
+}
+
+func (p *Pool) unregisterSession(s *session) {
+	p.mu.Lock()
+	if sessions, ok := p.clients[s.clientId]; ok && sessions[s.sessionId] == s {
+		log.Debug().Msgf("unregister sse client %s session %s", s.clientId, s.sessionId)
+		delete(sessions, s.sessionId)
+		if len(sessions) == 0 {
+			delete(p.clients, s.clientId)
+		}
+		p.count.Add(-1)
+	}
+	p.mu.Unlock()
+
+	s.close()
+}
+
+// validEventName rejects the names that would end the "event" field and inject other fields.
+func validEventName(name string) bool {
+	return !strings.ContainsAny(name, "\r\n")
+}
+
+func lastEventId(r *http.Request) (uint64, bool) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("lastEventId")
+	}
+	if value == "" {
+		return 0, false
+	}
+
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		log.Debug().Err(err).Msgf("invalid last event id %s", value)
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,215 @@
+package sse
+
+import (
+	"bufio"
+	"context"
+	"github.com/mandarine-io/baselib/pkg/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testEvent struct {
+	id   string
+	name string
+	data []string
+}
+
+type stream struct {
+	resp   *http.Response
+	reader *bufio.Reader
+}
+
+func newServer(t *testing.T, p *Pool) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = p.Register(r.URL.Query().Get("client"), r, w)
+	}))
+	t.Cleanup(func() {
+		_ = p.Close()
+		srv.Close()
+	})
+	return srv
+}
+
+// connect opens the stream and reads the session event, so the session is registered when it returns.
+func connect(t *testing.T, srv *httptest.Server, clientId string, lastEventId string) *stream {
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"?client="+clientId, nil)
+	require.NoError(t, err)
+	if lastEventId != "" {
+		req.Header.Set("Last-Event-ID", lastEventId)
+	}
+
+	resp, err := srv.Client().Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = resp.Body.Close()
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	s := &stream{resp: resp, reader: bufio.NewReader(resp.Body)}
+	hello := s.next(t)
+	require.Equal(t, sessionEvent, hello.name)
+	return s
+}
+
+// next reads the next event skipping comments.
+func (s *stream) next(t *testing.T) testEvent {
+	t.Helper()
+
+	var e testEvent
+	for {
+		line, err := s.reader.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+
+		switch {
+		case line == "":
+			if e.id != "" || e.name != "" || e.data != nil {
+				return e
+			}
+		case strings.HasPrefix(line, ":"):
+		case strings.HasPrefix(line, "id: "):
+			e.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			e.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			e.data = append(e.data, strings.TrimPrefix(line, "data: "))
+		}
+	}
+}
+
+func TestPool_SendEvent(t *testing.T) {
+	p := NewPool(10)
+	srv := newServer(t, p)
+	s := connect(t, srv, "client", "")
+
+	require.NoError(t, p.SendEvent("client", "greeting", []byte("hello\r\nworld\rid: 100")))
+	e := s.next(t)
+	assert.Equal(t, "1", e.id)
+	assert.Equal(t, "greeting", e.name)
+	assert.Equal(t, []string{"hello", "world", "id: 100"}, e.data)
+
+	require.NoError(t, p.Broadcast([]byte("all")))
+	e = s.next(t)
+	assert.Equal(t, "2", e.id)
+	assert.Equal(t, []string{"all"}, e.data)
+
+	assert.ErrorIs(t, p.SendEvent("missing", "", []byte("x")), websocket.ErrClientNotFound)
+}
+
+func TestPool_RejectsEventNamesWithLineBreaks(t *testing.T) {
+	p := NewPool(10)
+	srv := newServer(t, p)
+	s := connect(t, srv, "client", "")
+
+	for _, name := range []string{"a\nid: 100", "a\rb", "a\r\n"} {
+		assert.ErrorIs(t, p.SendEvent("client", name, []byte("x")), ErrInvalidEventName)
+		assert.ErrorIs(t, p.BroadcastEvent(name, []byte("x")), ErrInvalidEventName)
+	}
+
+	// Rejected events don't take ids
+	require.NoError(t, p.SendEvent("client", "ok", []byte("x")))
+	e := s.next(t)
+	assert.Equal(t, "1", e.id)
+	assert.Equal(t, "ok", e.name)
+}
+
+func TestPool_ReplaysMissedEvents(t *testing.T) {
+	p := NewPool(10)
+	srv := newServer(t, p)
+
+	// The events sent while the client is disconnected are buffered
+	_ = p.Send("client", []byte("1"))
+	_ = p.Send("other", []byte("2"))
+	_ = p.Broadcast([]byte("3"))
+	_ = p.Send("client", []byte("4"))
+
+	s := connect(t, srv, "client", "1")
+	for _, want := range []string{"3", "4"} {
+		e := s.next(t)
+		assert.Equal(t, want, e.id)
+		assert.Equal(t, []string{want}, e.data)
+	}
+
+	// Live events continue after the replayed ones
+	require.NoError(t, p.Send("client", []byte("5")))
+	e := s.next(t)
+	assert.Equal(t, "5", e.id)
+}
+
+func TestPool_ReplayBufferOverflow(t *testing.T) {
+	p := NewPoolWithOptions(Options{Size: 10, ReplayBufferSize: 2})
+	srv := newServer(t, p)
+
+	for _, data := range []string{"1", "2", "3", "4"} {
+		_ = p.Send("client", []byte(data))
+	}
+
+	// The evicted events are lost, the client gets the ones still buffered
+	s := connect(t, srv, "client", "0")
+	assert.Equal(t, "3", s.next(t).id)
+	assert.Equal(t, "4", s.next(t).id)
+
+	require.NoError(t, p.Send("client", []byte("5")))
+	assert.Equal(t, "5", s.next(t).id)
+}
+
+func TestPool_ShutdownDrainsQueues(t *testing.T) {
+	p := NewPoolWithOptions(Options{Size: 10, SendQueueSize: 100})
+	srv := newServer(t, p)
+	s := connect(t, srv, "client", "")
+
+	for i := 0; i < 50; i++ {
+		require.NoError(t, p.Send("client", []byte("x")))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, p.Shutdown(ctx))
+
+	for i := 1; i <= 50; i++ {
+		e := s.next(t)
+		assert.Equal(t, strconv.Itoa(i), e.id)
+	}
+	_, err := io.ReadAll(s.reader)
+	require.NoError(t, err)
+
+	assert.Equal(t, 0, p.Count())
+	assert.ErrorIs(t, p.Send("client", []byte("x")), websocket.ErrPoolClosed)
+	assert.ErrorIs(t, p.Shutdown(ctx), websocket.ErrPoolClosed)
+
+	resp, err := srv.Client().Get(srv.URL + "?client=client")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
+
+func TestPool_SlowConsumer(t *testing.T) {
+	p := NewPoolWithOptions(Options{Size: 10, SendQueueSize: 1, SlowConsumerPolicy: websocket.SlowConsumerDisconnect})
+	s := newSession("client", "session", httptest.NewRecorder(), httptest.NewRecorder(), 1)
+	p.clients["client"] = map[string]*session{s.sessionId: s}
+	p.count.Add(1)
+
+	require.NoError(t, p.Send("client", []byte("1")))
+	assert.ErrorIs(t, p.Send("client", []byte("2")), websocket.ErrSlowConsumer)
+	assert.Equal(t, 0, p.Count())
+	assert.ErrorIs(t, p.Send("client", []byte("3")), websocket.ErrClientNotFound)
+}
+
+func TestPool_IsFull(t *testing.T) {
+	p := NewPool(1)
+	srv := newServer(t, p)
+	connect(t, srv, "client", "")
+
+	resp, err := srv.Client().Get(srv.URL + "?client=other")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
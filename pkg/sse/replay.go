@@ -0,0 +1,63 @@
+package sse
+
+import (
+	"sync"
+)
+
+type event struct {
+	id       uint64
+	clientId string
+	name     string
+	data     []byte
+}
+
+// replayBuffer is a bounded ring buffer of the last sent events used for Last-Event-ID resume.
+type replayBuffer struct {
+	mu     sync.Mutex
+	events []event
+	start  int
+	size   int
+	lastId uint64
+}
+
+func newReplayBuffer(capacity int) *replayBuffer {
+	return &replayBuffer{events: make([]event, capacity)}
+}
+
+// add assigns the next id to the event and stores it, evicting the oldest event if the buffer is full.
+func (b *replayBuffer) add(clientId, name string, data []byte) event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastId++
+	e := event{id: b.lastId, clientId: clientId, name: name, data: data}
+	if len(b.events) == 0 {
+		return e
+	}
+
+	if b.size < len(b.events) {
+		b.events[(b.start+b.size)%len(b.events)] = e
+		b.size++
+	} else {
+		b.events[b.start] = e
+		b.start = (b.start + 1) % len(b.events)
+	}
+
+	return e
+}
+
+// since returns the buffered events of the client (including broadcast events) with id greater than lastId
+// and the last assigned id. Every event with id up to the last assigned one is either returned or already seen.
+func (b *replayBuffer) since(clientId string, lastId uint64) ([]event, uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	events := make([]event, 0)
+	for i := 0; i < b.size; i++ {
+		e := b.events[(b.start+i)%len(b.events)]
+		if e.id > lastId && (e.clientId == "" || e.clientId == clientId) {
+			events = append(events, e)
+		}
+	}
+	return events, b.lastId
+}
@@ -0,0 +1,49 @@
+package sse
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestReplayBuffer_EvictsOldestEvents(t *testing.T) {
+	b := newReplayBuffer(3)
+	for i := 0; i < 5; i++ {
+		b.add("client", "", []byte{byte('a' + i)})
+	}
+
+	events, lastId := b.since("client", 0)
+	assert.EqualValues(t, 5, lastId)
+	assert.Equal(t, []uint64{3, 4, 5}, eventIds(events))
+
+	events, _ = b.since("client", 4)
+	assert.Equal(t, []uint64{5}, eventIds(events))
+}
+
+func TestReplayBuffer_FiltersByClient(t *testing.T) {
+	b := newReplayBuffer(10)
+	b.add("client", "", nil)
+	b.add("other", "", nil)
+	b.add("", "broadcast", nil)
+
+	events, lastId := b.since("client", 0)
+	assert.EqualValues(t, 3, lastId)
+	assert.Equal(t, []uint64{1, 3}, eventIds(events))
+}
+
+func TestReplayBuffer_Disabled(t *testing.T) {
+	b := newReplayBuffer(0)
+	e := b.add("client", "", nil)
+	assert.EqualValues(t, 1, e.id)
+
+	events, lastId := b.since("client", 0)
+	assert.Empty(t, events)
+	assert.EqualValues(t, 1, lastId)
+}
+
+func eventIds(events []event) []uint64 {
+	ids := make([]uint64, 0, len(events))
+	for _, e := range events {
+		ids = append(ids, e.id)
+	}
+	return ids
+}
@@ -0,0 +1,136 @@
+package sse
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/mandarine-io/baselib/pkg/websocket"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type session struct {
+	clientId  string
+	sessionId string
+	w         http.ResponseWriter
+	flusher   http.Flusher
+	sendCh    chan event
+	done      chan struct{}
+	// replayedId is the replay cursor of the Last-Event-ID resume, the queued events
+	// with id up to it were replayed or already seen by the client.
+	replayedId uint64
+
+	closeOnce sync.Once
+}
+
+func newSession(clientId, sessionId string, w http.ResponseWriter, flusher http.Flusher, queueSize int) *session {
+	return &session{
+		clientId:  clientId,
+		sessionId: sessionId,
+		w:         w,
+		flusher:   flusher,
+		sendCh:    make(chan event, queueSize),
+		done:      make(chan struct{}),
+	}
+}
+
+func (s *session) enqueue(e event) error {
+	select {
+	case <-s.done:
+		return websocket.ErrClientNotFound
+	default:
+	}
+
+	select {
+	case s.sendCh <- e:
+		return nil
+	case <-s.done:
+		return websocket.ErrClientNotFound
+	default:
+		return websocket.ErrSlowConsumer
+	}
+}
+
+func (s *session) close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+func (s *session) writeLoop(ctx context.Context, p *Pool) error {
+	ticker := time.NewTicker(p.opts.HeartbeatPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.done:
+			return nil
+		case <-p.done:
+			// Flush the queue on shutdown
+			for len(s.sendCh) > 0 {
+				if err := s.writeQueued(<-s.sendCh); err != nil {
+					return err
+				}
+			}
+			return nil
+		case e := <-s.sendCh:
+			if err := s.writeQueued(e); err != nil {
+				return err
+			}
+		case <-ticker.C:
+			if err := s.write([]byte(": ping\n\n")); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *session) writeHello(retry time.Duration) error {
+	var buf bytes.Buffer
+	if retry > 0 {
+		_, _ = fmt.Fprintf(&buf, "retry: %d\n", retry.Milliseconds())
+	}
+	_, _ = fmt.Fprintf(&buf, "event: %s\ndata: %s\n\n", sessionEvent, s.sessionId)
+	return s.write(buf.Bytes())
+}
+
+// writeQueued writes the queued event, skipping events already sent during replay.
+// The ids of queued events aren't ordered, since concurrent senders enqueue them independently.
+func (s *session) writeQueued(e event) error {
+	if e.id <= s.replayedId {
+		return nil
+	}
+	return s.writeEvent(e)
+}
+
+func (s *session) writeEvent(e event) error {
+	var buf bytes.Buffer
+	_, _ = fmt.Fprintf(&buf, "id: %d\n", e.id)
+	if e.name != "" {
+		_, _ = fmt.Fprintf(&buf, "event: %s\n", e.name)
+	}
+	// Every line break (CRLF, CR or LF) ends the field, so each line is sent as a separate data field
+	data := bytes.ReplaceAll(e.data, []byte("\r\n"), []byte("\n"))
+	data = bytes.ReplaceAll(data, []byte("\r"), []byte("\n"))
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		buf.WriteString("data: ")
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+
+	return s.write(buf.Bytes())
+}
+
+func (s *session) write(b []byte) error {
+	if _, err := s.w.Write(b); err != nil {
+		log.Error().Stack().Err(err).Msgf("failed to send event to sse client %s session %s", s.clientId, s.sessionId)
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
@@ -0,0 +1,207 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultBatchConcurrency is the number of items of the batch processed concurrently by default.
+const DefaultBatchConcurrency = 8
+
+var ErrBatchItemSkipped = errors.New("batch item is skipped after the failure of another item")
+
+type (
+	BatchOption func(*batchOptions)
+
+	batchOptions struct {
+		concurrency int
+		failFast    bool
+	}
+
+	// BatchItemError is the failure of the batch item.
+	BatchItemError struct {
+		// Index is the index of the item in the input.
+		Index int
+		// ID is the object id of the item.
+		ID  string
+		Err error
+	}
+
+	// BatchError reports the failed items of the batch ordered by index.
+	BatchError struct {
+		Items []BatchItemError
+		// Total is the number of items in the batch.
+		Total int
+	}
+)
+
+// WithConcurrency sets the number of items processed concurrently. If n is not positive,
+// DefaultBatchConcurrency is used.
+func WithConcurrency(n int) BatchOption {
+	return func(o *batchOptions) {
+		o.concurrency = n
+	}
+}
+
+// WithFailFast stops starting items after the first failure, the rest fail with ErrBatchItemSkipped.
+// The context of the creates in progress is canceled. The gets in progress are completed,
+// since the readers of GetMany may be bound to the context and outlive the batch.
+func WithFailFast() BatchOption {
+	return func(o *batchOptions) {
+		o.failFast = true
+	}
+}
+
+func (e *BatchError) Error() string {
+	if len(e.Items) == 0 {
+		return "batch failed"
+	}
+	first := e.Items[0]
+	return fmt.Sprintf("%d of %d batch items failed, first #%d %q: %v", len(e.Items), e.Total, first.Index, first.ID, first.Err)
+}
+
+// Unwrap returns the errors of the items, so errors.Is matches any of them.
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, len(e.Items))
+	for i, item := range e.Items {
+		errs[i] = item.Err
+	}
+	return errs
+}
+
+// BatchCreate implements Client.CreateMany with createOne. The results are ordered as files.
+func BatchCreate(
+	ctx context.Context,
+	files []*FileData,
+	createOne func(context.Context, *FileData) *CreateDto,
+	opts ...BatchOption,
+) ([]*CreateDto, error) {
+	ids := make([]string, len(files))
+	for i, file := range files {
+		if file != nil {
+			ids[i] = file.ID
+		}
+	}
+
+	dtos := make([]*CreateDto, len(files))
+	errs := runBatch(ctx, len(files), opts, func(ctx context.Context, i int) error {
+		dtos[i] = createOne(ctx, files[i])
+		return dtos[i].Error
+	})
+	for i, err := range errs {
+		if dtos[i] == nil {
+			dtos[i] = &CreateDto{Error: err}
+		}
+	}
+
+	return dtos, newBatchError(ids, errs)
+}
+
+// BatchGet implements Client.GetMany with getOne. Duplicate ids are fetched once.
+func BatchGet(
+	ctx context.Context,
+	objectIDs []string,
+	getOne func(context.Context, string) *GetDto,
+	opts ...BatchOption,
+) (map[string]*GetDto, error) {
+	ids := make([]string, 0, len(objectIDs))
+	seen := make(map[string]struct{}, len(objectIDs))
+	for _, objectID := range objectIDs {
+		if _, ok := seen[objectID]; !ok {
+			seen[objectID] = struct{}{}
+			ids = append(ids, objectID)
+		}
+	}
+
+	// Read with the parent context, the readers are consumed after the batch returns
+	dtos := make([]*GetDto, len(ids))
+	errs := runBatch(ctx, len(ids), opts, func(_ context.Context, i int) error {
+		dtos[i] = getOne(ctx, ids[i])
+		return dtos[i].Error
+	})
+
+	dtoMap := make(map[string]*GetDto, len(ids))
+	for i, objectID := range ids {
+		if dtos[i] == nil {
+			dtos[i] = &GetDto{Error: errs[i]}
+		}
+		dtoMap[objectID] = dtos[i]
+	}
+
+	return dtoMap, newBatchError(ids, errs)
+}
+
+// runBatch calls fn for n items with the bounded concurrency and returns the errors by index.
+// Items are not started after ctx is done or the failure in the fail fast mode, the failure
+// also cancels the context of the items in progress.
+// The context of the items is canceled when runBatch returns, so the results of fn must not
+// depend on it, e.g. the readers bound to the context must be opened with the parent one.
+func runBatch(ctx context.Context, n int, opts []BatchOption, fn func(ctx context.Context, i int) error) []error {
+	options := batchOptions{concurrency: DefaultBatchConcurrency}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.concurrency <= 0 {
+		options.concurrency = DefaultBatchConcurrency
+	}
+
+	errs := make([]error, n)
+	indexCh := make(chan int)
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+
+	itemCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	fail := func() {
+		failed.Store(true)
+		if options.failFast {
+			cancel()
+		}
+	}
+
+	for range min(options.concurrency, n) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexCh {
+				if err := ctx.Err(); err != nil {
+					errs[i] = err
+					continue
+				}
+				if options.failFast && failed.Load() {
+					errs[i] = ErrBatchItemSkipped
+					continue
+				}
+
+				if err := fn(itemCtx, i); err != nil {
+					errs[i] = err
+					fail()
+				}
+			}
+		}()
+	}
+
+	for i := range n {
+		indexCh <- i
+	}
+	close(indexCh)
+	wg.Wait()
+
+	return errs
+}
+
+func newBatchError(ids []string, errs []error) error {
+	var items []BatchItemError
+	for i, err := range errs {
+		if err != nil {
+			items = append(items, BatchItemError{Index: i, ID: ids[i], Err: err})
+		}
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	return &BatchError{Items: items, Total: len(ids)}
+}
@@ -0,0 +1,54 @@
+package s3
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestBatchCreate_FailFastCancelsItemsInProgress(t *testing.T) {
+	errFailed := errors.New("failed")
+	started := make(chan struct{})
+
+	files := []*FileData{{ID: "slow"}, {ID: "failed"}, {ID: "skipped"}}
+	dtos, err := BatchCreate(context.Background(), files, func(ctx context.Context, file *FileData) *CreateDto {
+		switch file.ID {
+		case "slow":
+			close(started)
+			select {
+			case <-ctx.Done():
+				return &CreateDto{Error: ctx.Err()}
+			case <-time.After(5 * time.Second):
+				return &CreateDto{ObjectID: file.ID}
+			}
+		case "failed":
+			<-started
+			return &CreateDto{Error: errFailed}
+		default:
+			return &CreateDto{ObjectID: file.ID}
+		}
+	}, WithConcurrency(2), WithFailFast())
+
+	require.Error(t, err)
+	assert.ErrorIs(t, dtos[0].Error, context.Canceled)
+	assert.ErrorIs(t, dtos[1].Error, errFailed)
+	assert.ErrorIs(t, dtos[2].Error, ErrBatchItemSkipped)
+}
+
+func TestBatchGet_ReadsWithParentContext(t *testing.T) {
+	ctxs := make(map[string]context.Context)
+	dtoMap, err := BatchGet(context.Background(), []string{"a", "b", "a"}, func(ctx context.Context, objectID string) *GetDto {
+		ctxs[objectID] = ctx
+		return &GetDto{Data: &FileData{ID: objectID}}
+	}, WithConcurrency(1))
+
+	require.NoError(t, err)
+	assert.Len(t, dtoMap, 2)
+	// The readers bound to the context stay usable after the batch returns
+	for _, ctx := range ctxs {
+		assert.NoError(t, ctx.Err())
+	}
+}
@@ -3,20 +3,36 @@ package s3
 import (
 	"context"
 	dto2 "github.com/mandarine-io/baselib/pkg/transport/http/model"
+	"github.com/pkg/errors"
 	"io"
+	"net/http"
+	"time"
 )
 
 const (
 	OriginalFilenameMetadata = "x-amz-meta-original-filename"
+
+	// MinPartSize is the minimum size of every part of the multipart upload except the last one.
+	MinPartSize = 5 * 1024 * 1024
+	// MaxPartNumber is the maximum number of parts of the multipart upload.
+	MaxPartNumber = 10000
 )
 
 var (
-	ErrObjectNotFound = dto2.NewI18nError("object not found", "errors.object_not_found")
+	ErrObjectNotFound  = dto2.NewI18nError("object not found", "errors.object_not_found")
+	ErrNotSupported    = errors.New("operation is not supported by the storage")
+	ErrInvalidObjectID = errors.New("invalid object id")
+	ErrInvalidRange    = dto2.NewI18nError("requested range is not satisfiable", "errors.invalid_range")
+	ErrUploadNotFound  = errors.New("multipart upload not found")
+	ErrInvalidPart     = errors.New("part is not uploaded or its etag doesn't match")
+	ErrPartTooSmall    = errors.New("part is smaller than the minimum part size")
 )
 
 type (
 	FileData struct {
-		ID           string
+		ID string
+		// Size is the size of the data. On upload, -1 means the unknown length: the reader
+		// is uploaded until EOF in parts.
 		Size         int64
 		ContentType  string
 		Reader       io.ReadCloser
@@ -33,12 +49,134 @@ type (
 		Error error
 	}
 
+	ObjectInfo struct {
+		ID           string
+		Size         int64
+		ContentType  string
+		ETag         string
+		LastModified time.Time
+		// UserMetadata is filled by Stat only, keys are lowercase with "x-amz-meta-" prefix.
+		UserMetadata map[string]string
+	}
+
+	// ListPage is the page of objects ordered by id. NextCursor is empty on the last page.
+	ListPage struct {
+		Objects    []ObjectInfo
+		NextCursor string
+	}
+
+	// Part is the uploaded part of the multipart upload.
+	Part struct {
+		Number int
+		ETag   string
+		Size   int64
+	}
+
+	// PresignedRequest is the request the client sends directly to the storage.
+	// Headers must be sent as is, since they are signed.
+	PresignedRequest struct {
+		URL       string
+		Method    string
+		Headers   http.Header
+		ExpiresAt time.Time
+	}
+
+	// PostPolicy restricts the browser form upload.
+	PostPolicy struct {
+		// ContentType is the exact content type of the upload. If it ends with "/"
+		// (e.g. "image/"), it's the prefix of the content type.
+		ContentType  string
+		MinSize      int64
+		MaxSize      int64
+		UserMetadata map[string]string
+	}
+
+	// PresignedPost is the multipart form upload: FormData fields must precede
+	// the "file" field in the form posted to URL.
+	PresignedPost struct {
+		URL       string
+		FormData  map[string]string
+		ExpiresAt time.Time
+	}
+
 	Client interface {
+		// Bucket returns the client of another bucket sharing the connection.
+		Bucket(name string) Client
+		// Prefix returns the client storing objects under the key prefix, object ids are relative to it.
+		Prefix(prefix string) Client
+
 		CreateOne(ctx context.Context, file *FileData) *CreateDto
-		CreateMany(ctx context.Context, files []*FileData) map[string]*CreateDto
+		// CreateMany creates the objects concurrently. The results are ordered as files.
+		// The error is *BatchError reporting the failed items, nil if all the objects are created.
+		CreateMany(ctx context.Context, files []*FileData, opts ...BatchOption) ([]*CreateDto, error)
 		GetOne(ctx context.Context, objectID string) *GetDto
-		GetMany(ctx context.Context, objectIDs []string) map[string]*GetDto
+		// GetMany gets the objects concurrently. The results are keyed by object id, the readers
+		// of the successful results must be closed even if the error is returned.
+		// The error is *BatchError reporting the failed items, nil if all the objects are got.
+		GetMany(ctx context.Context, objectIDs []string, opts ...BatchOption) (map[string]*GetDto, error)
 		DeleteOne(ctx context.Context, objectID string) error
 		DeleteMany(ctx context.Context, objectIDs []string) map[string]error
+
+		// GetRange returns length bytes of the object starting at offset. If length is negative,
+		// the object is read to the end. The range exceeding the object is truncated, the offset
+		// beyond the object returns ErrInvalidRange. FileData.Size is the size of the returned range.
+		GetRange(ctx context.Context, objectID string, offset int64, length int64) (*FileData, error)
+
+		// InitiateUpload starts the multipart upload and returns its id. The upload is resumable:
+		// the uploaded parts are kept until the upload is completed or aborted.
+		InitiateUpload(ctx context.Context, objectID string, contentType string, metadata map[string]string) (string, error)
+		// UploadPart uploads the part with the number from 1 to MaxPartNumber. The part with the same
+		// number replaces the previous one. All parts except the last must be at least MinPartSize.
+		UploadPart(ctx context.Context, objectID string, uploadID string, number int, r io.Reader, size int64) (*Part, error)
+		// ListParts returns the uploaded parts ordered by number, e.g. to resume the upload.
+		ListParts(ctx context.Context, objectID string, uploadID string) ([]Part, error)
+		// CompleteUpload assembles the object from the parts ordered by number.
+		CompleteUpload(ctx context.Context, objectID string, uploadID string, parts []Part) error
+		AbortUpload(ctx context.Context, objectID string, uploadID string) error
+
+		// List returns the page of objects with the id prefix starting after the cursor.
+		// The empty cursor starts from the first object.
+		List(ctx context.Context, prefix string, cursor string) (*ListPage, error)
+		// Stat returns the object info or ErrObjectNotFound.
+		Stat(ctx context.Context, objectID string) (*ObjectInfo, error)
+		Exists(ctx context.Context, objectID string) (bool, error)
+		Copy(ctx context.Context, srcObjectID string, dstObjectID string) error
+		Move(ctx context.Context, srcObjectID string, dstObjectID string) error
+		// UpdateMetadata replaces the user metadata of the object.
+		UpdateMetadata(ctx context.Context, objectID string, metadata map[string]string) error
+
+		// PresignGet returns the request downloading the object.
+		PresignGet(ctx context.Context, objectID string, ttl time.Duration) (*PresignedRequest, error)
+		// PresignPut returns the request uploading the object. The PUT signature can't carry
+		// the content-length-range condition, so the limit is enforced only as the exact size:
+		// if maxSize is positive it's signed as Content-Length and the uploads of any other size,
+		// smaller ones too, are rejected. If maxSize is not positive, the size isn't limited.
+		// Use PresignPost to allow any size up to the limit.
+		PresignPut(ctx context.Context, objectID string, ttl time.Duration, contentType string, maxSize int64) (*PresignedRequest, error)
+		// PresignPost returns the browser form upload of the object restricted by the policy.
+		PresignPost(ctx context.Context, objectID string, ttl time.Duration, policy PostPolicy) (*PresignedPost, error)
 	}
 )
+
+// Walk calls fn for every object with the id prefix, fetching the pages as needed.
+// It stops on the first error returned by fn.
+func Walk(ctx context.Context, client Client, prefix string, fn func(ObjectInfo) error) error {
+	cursor := ""
+	for {
+		page, err := client.List(ctx, prefix, cursor)
+		if err != nil {
+			return err
+		}
+
+		for _, obj := range page.Objects {
+			if err := fn(obj); err != nil {
+				return err
+			}
+		}
+
+		if page.NextCursor == "" {
+			return nil
+		}
+		cursor = page.NextCursor
+	}
+}
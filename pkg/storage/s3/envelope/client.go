@@ -0,0 +1,297 @@
+package envelope
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"github.com/mandarine-io/baselib/pkg/storage/s3"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"io"
+	"time"
+)
+
+const (
+	// KeyMetadata is the metadata key of the wrapped data key of the object.
+	KeyMetadata = "x-amz-meta-envelope-key"
+	// KeyIDMetadata is the metadata key of the id of the key encryption key.
+	KeyIDMetadata = "x-amz-meta-envelope-key-id"
+	// AlgorithmMetadata is the metadata key of the encryption algorithm of the object.
+	AlgorithmMetadata = "x-amz-meta-envelope-alg"
+
+	algorithm = "AES256-GCM-SEG64K"
+)
+
+var (
+	ErrDecryption           = errors.New("failed to decrypt object")
+	ErrUnknownKey           = errors.New("unknown key encryption key")
+	ErrUnsupportedAlgorithm = errors.New("unsupported encryption algorithm")
+)
+
+type client struct {
+	s3.Client
+	keys KeyProvider
+}
+
+// NewClient wraps the client to encrypt objects on the client side. Every object is encrypted
+// with AES-256-GCM by the random data key, the data key is wrapped by the key provider and
+// stored in the object metadata. GetOne, GetMany, GetRange and Stat decrypt objects transparently,
+// the objects stored without encryption are returned as is.
+//
+// List returns the sizes of encrypted objects. Multipart uploads and presigned requests
+// return s3.ErrNotSupported, since the storage can't encrypt them.
+func NewClient(s3Client s3.Client, keys KeyProvider) s3.Client {
+	return &client{Client: s3Client, keys: keys}
+}
+
+func (c *client) Bucket(name string) s3.Client {
+	return &client{Client: c.Client.Bucket(name), keys: c.keys}
+}
+
+func (c *client) Prefix(prefix string) s3.Client {
+	return &client{Client: c.Client.Prefix(prefix), keys: c.keys}
+}
+
+func (c *client) CreateOne(ctx context.Context, file *s3.FileData) *s3.CreateDto {
+	log.Debug().Msg("encrypt and create one object")
+	if file == nil {
+		return &s3.CreateDto{Error: errors.New("file is nil")}
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return &s3.CreateDto{Error: err}
+	}
+	wrapped, keyID, err := c.keys.WrapKey(ctx, dataKey)
+	if err != nil {
+		return &s3.CreateDto{Error: err}
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return &s3.CreateDto{Error: err}
+	}
+
+	metadata := make(map[string]string, len(file.UserMetadata)+3)
+	for k, v := range file.UserMetadata {
+		metadata[k] = v
+	}
+	metadata[KeyMetadata] = base64.StdEncoding.EncodeToString(wrapped)
+	metadata[KeyIDMetadata] = keyID
+	metadata[AlgorithmMetadata] = algorithm
+
+	size := file.Size
+	var r io.Reader = file.Reader
+	if size >= 0 {
+		r = io.LimitReader(r, size)
+		size = ciphertextSize(size)
+	}
+
+	return c.Client.CreateOne(ctx, &s3.FileData{
+		ID:           file.ID,
+		Size:         size,
+		ContentType:  file.ContentType,
+		Reader:       readCloser{Reader: newEncryptReader(r, aead), Closer: file.Reader},
+		UserMetadata: metadata,
+	})
+}
+
+func (c *client) CreateMany(ctx context.Context, files []*s3.FileData, opts ...s3.BatchOption) ([]*s3.CreateDto, error) {
+	log.Debug().Msg("encrypt and create many object")
+	return s3.BatchCreate(ctx, files, c.CreateOne, opts...)
+}
+
+func (c *client) GetOne(ctx context.Context, objectID string) *s3.GetDto {
+	log.Debug().Msg("get and decrypt one object")
+
+	dto := c.Client.GetOne(ctx, objectID)
+	if dto.Error != nil || dto.Data.UserMetadata[KeyMetadata] == "" {
+		return dto
+	}
+
+	data, err := c.decrypt(ctx, dto.Data)
+	if err != nil {
+		_ = dto.Data.Reader.Close()
+		return &s3.GetDto{Error: err}
+	}
+	return &s3.GetDto{Data: data}
+}
+
+func (c *client) GetMany(ctx context.Context, objectIDs []string, opts ...s3.BatchOption) (map[string]*s3.GetDto, error) {
+	log.Debug().Msg("get and decrypt many object")
+	return s3.BatchGet(ctx, objectIDs, c.GetOne, opts...)
+}
+
+func (c *client) GetRange(ctx context.Context, objectID string, offset int64, length int64) (*s3.FileData, error) {
+	log.Debug().Msgf("get and decrypt object range %d+%d", offset, length)
+
+	info, err := c.Client.Stat(ctx, objectID)
+	if err != nil {
+		return nil, err
+	}
+	if info.UserMetadata[KeyMetadata] == "" {
+		return c.Client.GetRange(ctx, objectID, offset, length)
+	}
+
+	size, err := plaintextSize(info.Size)
+	if err != nil {
+		return nil, err
+	}
+	if offset < 0 || length == 0 || (offset >= size && size > 0) || (size == 0 && offset > 0) {
+		return nil, s3.ErrInvalidRange
+	}
+	end := size
+	if length > 0 && offset+length < size {
+		end = offset + length
+	}
+
+	aead, err := c.aead(ctx, info.UserMetadata)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the sealed segments covering the range
+	first := offset / segmentSize
+	last := max(first, (end-1)/segmentSize)
+	data, err := c.Client.GetRange(ctx, objectID, first*sealedSize, (last-first+1)*sealedSize)
+	if err != nil {
+		return nil, err
+	}
+
+	r := newDecryptReader(data.Reader, aead, first, segments(size)-1)
+	if _, err := io.CopyN(io.Discard, r, offset-first*segmentSize); err != nil {
+		_ = r.Close()
+		return nil, err
+	}
+
+	return &s3.FileData{
+		ID:           data.ID,
+		Size:         end - offset,
+		ContentType:  data.ContentType,
+		Reader:       readCloser{Reader: io.LimitReader(r, end-offset), Closer: r},
+		UserMetadata: plainMetadata(data.UserMetadata),
+	}, nil
+}
+
+func (c *client) Stat(ctx context.Context, objectID string) (*s3.ObjectInfo, error) {
+	log.Debug().Msg("stat encrypted object")
+
+	info, err := c.Client.Stat(ctx, objectID)
+	if err != nil || info.UserMetadata[KeyMetadata] == "" {
+		return info, err
+	}
+
+	size, err := plaintextSize(info.Size)
+	if err != nil {
+		return nil, err
+	}
+	info.Size = size
+	info.UserMetadata = plainMetadata(info.UserMetadata)
+	return info, nil
+}
+
+// UpdateMetadata replaces the user metadata of the object keeping its encryption metadata.
+func (c *client) UpdateMetadata(ctx context.Context, objectID string, metadata map[string]string) error {
+	log.Debug().Msg("update encrypted object metadata")
+
+	info, err := c.Client.Stat(ctx, objectID)
+	if err != nil {
+		return err
+	}
+
+	merged := make(map[string]string, len(metadata)+3)
+	for k, v := range metadata {
+		merged[k] = v
+	}
+	for _, k := range []string{KeyMetadata, KeyIDMetadata, AlgorithmMetadata} {
+		if v, ok := info.UserMetadata[k]; ok {
+			merged[k] = v
+		}
+	}
+	return c.Client.UpdateMetadata(ctx, objectID, merged)
+}
+
+func (c *client) InitiateUpload(context.Context, string, string, map[string]string) (string, error) {
+	return "", s3.ErrNotSupported
+}
+
+func (c *client) UploadPart(context.Context, string, string, int, io.Reader, int64) (*s3.Part, error) {
+	return nil, s3.ErrNotSupported
+}
+
+func (c *client) ListParts(context.Context, string, string) ([]s3.Part, error) {
+	return nil, s3.ErrNotSupported
+}
+
+func (c *client) CompleteUpload(context.Context, string, string, []s3.Part) error {
+	return s3.ErrNotSupported
+}
+
+func (c *client) AbortUpload(context.Context, string, string) error {
+	return s3.ErrNotSupported
+}
+
+func (c *client) PresignGet(context.Context, string, time.Duration) (*s3.PresignedRequest, error) {
+	return nil, s3.ErrNotSupported
+}
+
+func (c *client) PresignPut(context.Context, string, time.Duration, string, int64) (*s3.PresignedRequest, error) {
+	return nil, s3.ErrNotSupported
+}
+
+func (c *client) PresignPost(context.Context, string, time.Duration, s3.PostPolicy) (*s3.PresignedPost, error) {
+	return nil, s3.ErrNotSupported
+}
+
+func (c *client) decrypt(ctx context.Context, data *s3.FileData) (*s3.FileData, error) {
+	aead, err := c.aead(ctx, data.UserMetadata)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := plaintextSize(data.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3.FileData{
+		ID:           data.ID,
+		Size:         size,
+		ContentType:  data.ContentType,
+		Reader:       newDecryptReader(data.Reader, aead, 0, segments(size)-1),
+		UserMetadata: plainMetadata(data.UserMetadata),
+	}, nil
+}
+
+func (c *client) aead(ctx context.Context, metadata map[string]string) (cipher.AEAD, error) {
+	if metadata[AlgorithmMetadata] != algorithm {
+		return nil, ErrUnsupportedAlgorithm
+	}
+
+	wrapped, err := base64.StdEncoding.DecodeString(metadata[KeyMetadata])
+	if err != nil {
+		return nil, ErrDecryption
+	}
+	dataKey, err := c.keys.UnwrapKey(ctx, wrapped, metadata[KeyIDMetadata])
+	if err != nil {
+		return nil, err
+	}
+	return newAEAD(dataKey)
+}
+
+// plainMetadata returns the user metadata without the encryption metadata.
+func plainMetadata(metadata map[string]string) map[string]string {
+	if metadata == nil {
+		return nil
+	}
+
+	plain := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		switch k {
+		case KeyMetadata, KeyIDMetadata, AlgorithmMetadata:
+		default:
+			plain[k] = v
+		}
+	}
+	return plain
+}
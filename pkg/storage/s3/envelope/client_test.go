@@ -0,0 +1,254 @@
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"github.com/mandarine-io/baselib/pkg/storage/s3"
+	"github.com/mandarine-io/baselib/pkg/storage/s3/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"strings"
+	"testing"
+)
+
+func newTestClient(t *testing.T) (s3.Client, s3.Client) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	keys, err := NewKeyring(map[string][]byte{"k1": key}, "k1")
+	require.NoError(t, err)
+
+	inner := memory.NewClient("test")
+	return NewClient(inner, keys), inner
+}
+
+func randomData(t *testing.T, size int) []byte {
+	data := make([]byte, size)
+	_, err := rand.Read(data)
+	require.NoError(t, err)
+	return data
+}
+
+func create(t *testing.T, c s3.Client, id string, data []byte, metadata map[string]string) {
+	dto := c.CreateOne(context.Background(), &s3.FileData{
+		ID:           id,
+		Size:         int64(len(data)),
+		ContentType:  "application/octet-stream",
+		Reader:       io.NopCloser(bytes.NewReader(data)),
+		UserMetadata: metadata,
+	})
+	require.NoError(t, dto.Error)
+}
+
+func readAll(c s3.Client, id string) ([]byte, error) {
+	dto := c.GetOne(context.Background(), id)
+	if dto.Error != nil {
+		return nil, dto.Error
+	}
+	defer dto.Data.Reader.Close()
+	return io.ReadAll(dto.Data.Reader)
+}
+
+func TestClient_RoundTrip(t *testing.T) {
+	sizes := map[string]int{
+		"empty":             0,
+		"one byte":          1,
+		"one segment":       segmentSize,
+		"one segment and 1": segmentSize + 1,
+		"several segments":  3*segmentSize + 100,
+	}
+	for name, size := range sizes {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			c, inner := newTestClient(t)
+			data := randomData(t, size)
+			create(t, c, "file", data, map[string]string{"x-amz-meta-owner": "alice"})
+
+			// The stored object is encrypted
+			raw, err := readAll(inner, "file")
+			require.NoError(t, err)
+			assert.Len(t, raw, int(ciphertextSize(int64(size))))
+			if size > 0 {
+				assert.False(t, bytes.Contains(raw, data))
+			}
+
+			got := c.GetOne(ctx, "file")
+			require.NoError(t, got.Error)
+			assert.Equal(t, int64(size), got.Data.Size)
+			assert.Equal(t, map[string]string{"x-amz-meta-owner": "alice"}, got.Data.UserMetadata)
+			plain, err := io.ReadAll(got.Data.Reader)
+			require.NoError(t, err)
+			require.NoError(t, got.Data.Reader.Close())
+			assert.Equal(t, data, plain)
+
+			info, err := c.Stat(ctx, "file")
+			require.NoError(t, err)
+			assert.Equal(t, int64(size), info.Size)
+			assert.NotContains(t, info.UserMetadata, KeyMetadata)
+		})
+	}
+}
+
+func TestClient_UnknownSize(t *testing.T) {
+	c, _ := newTestClient(t)
+	data := randomData(t, segmentSize+1)
+
+	dto := c.CreateOne(context.Background(), &s3.FileData{
+		ID:     "file",
+		Size:   -1,
+		Reader: io.NopCloser(bytes.NewReader(data)),
+	})
+	require.NoError(t, dto.Error)
+
+	plain, err := readAll(c, "file")
+	require.NoError(t, err)
+	assert.Equal(t, data, plain)
+}
+
+func TestClient_GetRange(t *testing.T) {
+	c, _ := newTestClient(t)
+	data := randomData(t, 3*segmentSize+100)
+	create(t, c, "file", data, nil)
+
+	tests := []struct {
+		name   string
+		offset int64
+		length int64
+		want   []byte
+	}{
+		{name: "within segment", offset: 10, length: 100, want: data[10:110]},
+		{name: "across segments", offset: segmentSize - 10, length: 20, want: data[segmentSize-10 : segmentSize+10]},
+		{name: "across several segments", offset: 100, length: 2 * segmentSize, want: data[100 : 2*segmentSize+100]},
+		{name: "segment boundary", offset: segmentSize, length: segmentSize, want: data[segmentSize : 2*segmentSize]},
+		{name: "to the end", offset: 2*segmentSize + 5, length: -1, want: data[2*segmentSize+5:]},
+		{name: "beyond the end", offset: 3 * segmentSize, length: 1000, want: data[3*segmentSize:]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.GetRange(context.Background(), "file", tt.offset, tt.length)
+			require.NoError(t, err)
+			defer got.Reader.Close()
+
+			assert.Equal(t, int64(len(tt.want)), got.Size)
+			plain, err := io.ReadAll(got.Reader)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, plain)
+		})
+	}
+
+	_, err := c.GetRange(context.Background(), "file", int64(len(data)), 1)
+	assert.ErrorIs(t, err, s3.ErrInvalidRange)
+}
+
+func TestClient_RejectsTamperedSegments(t *testing.T) {
+	data := randomData(t, 3*segmentSize+100)
+
+	tests := []struct {
+		name   string
+		tamper func(raw []byte) []byte
+	}{
+		{
+			name: "truncated by segment",
+			tamper: func(raw []byte) []byte {
+				return raw[:2*sealedSize]
+			},
+		},
+		{
+			name: "truncated in segment",
+			tamper: func(raw []byte) []byte {
+				return raw[:len(raw)-10]
+			},
+		},
+		{
+			name: "reordered",
+			tamper: func(raw []byte) []byte {
+				out := append([]byte{}, raw[sealedSize:2*sealedSize]...)
+				out = append(out, raw[:sealedSize]...)
+				return append(out, raw[2*sealedSize:]...)
+			},
+		},
+		{
+			name: "flipped bit",
+			tamper: func(raw []byte) []byte {
+				out := append([]byte{}, raw...)
+				out[sealedSize+1] ^= 1
+				return out
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, inner := newTestClient(t)
+			create(t, c, "file", data, nil)
+
+			raw, err := readAll(inner, "file")
+			require.NoError(t, err)
+			info, err := inner.Stat(context.Background(), "file")
+			require.NoError(t, err)
+			create(t, inner, "file", tt.tamper(raw), info.UserMetadata)
+
+			_, err = readAll(c, "file")
+			assert.ErrorIs(t, err, ErrDecryption)
+		})
+	}
+}
+
+func TestClient_PlainObject(t *testing.T) {
+	c, inner := newTestClient(t)
+	create(t, inner, "plain", []byte("plain"), nil)
+
+	plain, err := readAll(c, "plain")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("plain"), plain)
+
+	got, err := c.GetRange(context.Background(), "plain", 1, 2)
+	require.NoError(t, err)
+	defer got.Reader.Close()
+	part, err := io.ReadAll(got.Reader)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("la"), part)
+}
+
+func TestClient_UnknownKey(t *testing.T) {
+	c, inner := newTestClient(t)
+	create(t, c, "file", []byte("secret"), nil)
+
+	other, err := NewKeyring(map[string][]byte{"k2": bytes.Repeat([]byte{1}, 32)}, "k2")
+	require.NoError(t, err)
+
+	_, err = readAll(NewClient(inner, other), "file")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestClient_UpdateMetadataKeepsKey(t *testing.T) {
+	c, _ := newTestClient(t)
+	create(t, c, "file", []byte("secret"), nil)
+
+	require.NoError(t, c.UpdateMetadata(context.Background(), "file", map[string]string{"x-amz-meta-owner": "bob"}))
+
+	plain, err := readAll(c, "file")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("secret"), plain)
+}
+
+func TestClient_UnsupportedOperations(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestClient(t)
+
+	_, err := c.InitiateUpload(ctx, "file", "", nil)
+	assert.ErrorIs(t, err, s3.ErrNotSupported)
+	_, err = c.UploadPart(ctx, "file", "upload", 1, strings.NewReader("part"), 4)
+	assert.ErrorIs(t, err, s3.ErrNotSupported)
+	_, err = c.ListParts(ctx, "file", "upload")
+	assert.ErrorIs(t, err, s3.ErrNotSupported)
+	assert.ErrorIs(t, c.CompleteUpload(ctx, "file", "upload", nil), s3.ErrNotSupported)
+	assert.ErrorIs(t, c.AbortUpload(ctx, "file", "upload"), s3.ErrNotSupported)
+	_, err = c.PresignGet(ctx, "file", 0)
+	assert.ErrorIs(t, err, s3.ErrNotSupported)
+	_, err = c.PresignPut(ctx, "file", 0, "", 0)
+	assert.ErrorIs(t, err, s3.ErrNotSupported)
+	_, err = c.PresignPost(ctx, "file", 0, s3.PostPolicy{})
+	assert.ErrorIs(t, err, s3.ErrNotSupported)
+}
@@ -0,0 +1,79 @@
+package envelope
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"github.com/pkg/errors"
+)
+
+// KeyProvider wraps the data keys of objects with key encryption keys, e.g. with KMS.
+type KeyProvider interface {
+	// WrapKey encrypts the data key and returns it with the id of the key encryption key.
+	WrapKey(ctx context.Context, dataKey []byte) (wrapped []byte, keyID string, err error)
+	// UnwrapKey decrypts the data key wrapped with the key encryption key of the id.
+	UnwrapKey(ctx context.Context, wrapped []byte, keyID string) ([]byte, error)
+}
+
+type keyring struct {
+	keys      map[string]cipher.AEAD
+	currentID string
+}
+
+// NewKeyring returns the key provider wrapping data keys with the local 32 bytes AES-GCM keys.
+// New data keys are wrapped with the key of currentID, the other keys are kept to unwrap
+// data keys of existing objects after the key rotation.
+func NewKeyring(keys map[string][]byte, currentID string) (KeyProvider, error) {
+	if _, ok := keys[currentID]; !ok {
+		return nil, errors.Wrapf(ErrUnknownKey, "current key %s", currentID)
+	}
+
+	k := &keyring{keys: make(map[string]cipher.AEAD, len(keys)), currentID: currentID}
+	for id, key := range keys {
+		if len(key) != 32 {
+			return nil, errors.Errorf("key %s must be 32 bytes long", id)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[id] = aead
+	}
+	return k, nil
+}
+
+func (k *keyring) WrapKey(_ context.Context, dataKey []byte) ([]byte, string, error) {
+	aead := k.keys[k.currentID]
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, "", err
+	}
+	return aead.Seal(nonce, nonce, dataKey, []byte(k.currentID)), k.currentID, nil
+}
+
+func (k *keyring) UnwrapKey(_ context.Context, wrapped []byte, keyID string) ([]byte, error) {
+	aead, ok := k.keys[keyID]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, ErrDecryption
+	}
+
+	nonce, sealed := wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():]
+	dataKey, err := aead.Open(nil, nonce, sealed, []byte(keyID))
+	if err != nil {
+		return nil, ErrDecryption
+	}
+	return dataKey, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
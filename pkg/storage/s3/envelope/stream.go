@@ -0,0 +1,171 @@
+package envelope
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"encoding/binary"
+	"github.com/pkg/errors"
+	"io"
+)
+
+const (
+	// segmentSize is the size of the plaintext segment sealed separately, so the object
+	// is encrypted and decrypted as the stream and ranges are read without the whole object.
+	segmentSize = 64 * 1024
+	tagSize     = 16
+	sealedSize  = segmentSize + tagSize
+)
+
+// The object is the sequence of AES-GCM sealed segments. The nonce of the segment is its
+// index and the flag of the last segment, so the segments can't be reordered or truncated.
+// The data key is unique per object, so the nonces are never reused with the same key.
+func segmentNonce(index int64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[3:11], uint64(index))
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// ciphertextSize returns the size of the encrypted object of the plaintext size.
+func ciphertextSize(size int64) int64 {
+	return size + segments(size)*tagSize
+}
+
+// plaintextSize returns the size of the plaintext of the encrypted object size.
+func plaintextSize(size int64) (int64, error) {
+	if size < tagSize {
+		return 0, ErrDecryption
+	}
+	n := (size + sealedSize - 1) / sealedSize
+	return size - n*tagSize, nil
+}
+
+// segments returns the number of segments of the plaintext size, the empty plaintext is one empty segment.
+func segments(size int64) int64 {
+	return max(1, (size+segmentSize-1)/segmentSize)
+}
+
+type encryptReader struct {
+	src   *bufio.Reader
+	aead  cipher.AEAD
+	index int64
+	plain []byte
+	out   []byte
+	done  bool
+	err   error
+}
+
+func newEncryptReader(src io.Reader, aead cipher.AEAD) *encryptReader {
+	return &encryptReader{
+		src:   bufio.NewReaderSize(src, segmentSize+1),
+		aead:  aead,
+		plain: make([]byte, segmentSize),
+	}
+}
+
+func (r *encryptReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.done {
+			return 0, io.EOF
+		}
+		r.err = r.seal()
+	}
+
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+func (r *encryptReader) seal() error {
+	n, err := io.ReadFull(r.src, r.plain)
+	last := false
+	switch {
+	case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		last = true
+	case err != nil:
+		return err
+	default:
+		// The full segment is the last one if nothing follows it
+		if _, err := r.src.Peek(1); errors.Is(err, io.EOF) {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	r.out = r.aead.Seal(r.out[:0], segmentNonce(r.index, last), r.plain[:n], nil)
+	r.index++
+	r.done = last
+	return nil
+}
+
+type decryptReader struct {
+	src       io.ReadCloser
+	aead      cipher.AEAD
+	index     int64
+	lastIndex int64
+	sealed    []byte
+	out       []byte
+	err       error
+}
+
+// newDecryptReader decrypts the segments from index to lastIndex read from src.
+func newDecryptReader(src io.ReadCloser, aead cipher.AEAD, index int64, lastIndex int64) *decryptReader {
+	return &decryptReader{
+		src:       src,
+		aead:      aead,
+		index:     index,
+		lastIndex: lastIndex,
+		sealed:    make([]byte, sealedSize),
+	}
+}
+
+func (r *decryptReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.index > r.lastIndex {
+			return 0, io.EOF
+		}
+		r.err = r.open()
+	}
+
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+func (r *decryptReader) open() error {
+	n, err := io.ReadFull(r.src, r.sealed)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return err
+	}
+	// Only the last segment is shorter, the short one in the middle is the truncated object
+	last := r.index == r.lastIndex
+	if n < tagSize || (n < sealedSize && !last) {
+		return ErrDecryption
+	}
+
+	out, err := r.aead.Open(r.sealed[:0], segmentNonce(r.index, last), r.sealed[:n], nil)
+	if err != nil {
+		return ErrDecryption
+	}
+	r.out = out
+	r.index++
+	return nil
+}
+
+func (r *decryptReader) Close() error {
+	return r.src.Close()
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,236 @@
+package s3
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ObjectIDFunc resolves the object id of the request, e.g. from the path parameter.
+type ObjectIDFunc func(c *gin.Context) (string, error)
+
+type ServeOptions struct {
+	// Attachment makes browsers download the object instead of displaying it.
+	Attachment bool
+	// CacheControl is the value of Cache-Control header. If empty, the header is not set.
+	CacheControl string
+}
+
+// FileHandler returns the gin handler streaming the object to the client.
+func FileHandler(client Client, objectIDFunc ObjectIDFunc, opts ServeOptions) gin.HandlerFunc {
+	log.Debug().Msg("setup s3 file handler")
+	return func(c *gin.Context) {
+		objectID, err := objectIDFunc(c)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		ServeObject(c, client, objectID, opts)
+	}
+}
+
+// ServeObject streams the object to the client. It supports HEAD requests, conditional requests
+// by ETag and modification time, and single byte ranges. Content-Disposition contains
+// the original filename from OriginalFilenameMetadata if it's set.
+// Errors are set with c.AbortWithError, ErrObjectNotFound is mapped to 404.
+func ServeObject(c *gin.Context, client Client, objectID string, opts ServeOptions) {
+	log.Debug().Msgf("serve object %s", objectID)
+
+	info, err := client.Stat(c, objectID)
+	if err != nil {
+		abortWithObjectError(c, err)
+		return
+	}
+
+	etag := quoteETag(info.ETag)
+	header := c.Writer.Header()
+	if etag != "" {
+		header.Set("ETag", etag)
+	}
+	if !info.LastModified.IsZero() {
+		header.Set("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
+	}
+	if opts.CacheControl != "" {
+		header.Set("Cache-Control", opts.CacheControl)
+	}
+	header.Set("Accept-Ranges", "bytes")
+
+	if notModified(c.Request, etag, info.LastModified) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	header.Set("Content-Type", info.ContentType)
+	if disposition := contentDisposition(info, opts.Attachment); disposition != "" {
+		header.Set("Content-Disposition", disposition)
+	}
+
+	offset, length, ranged, err := requestRange(c.Request, info, etag)
+	if err != nil {
+		header.Set("Content-Range", "bytes */"+strconv.FormatInt(info.Size, 10))
+		_ = c.AbortWithError(http.StatusRequestedRangeNotSatisfiable, ErrInvalidRange)
+		return
+	}
+
+	status := http.StatusOK
+	if ranged {
+		status = http.StatusPartialContent
+		header.Set("Content-Range", "bytes "+strconv.FormatInt(offset, 10)+"-"+
+			strconv.FormatInt(offset+length-1, 10)+"/"+strconv.FormatInt(info.Size, 10))
+	}
+	header.Set("Content-Length", strconv.FormatInt(length, 10))
+
+	if c.Request.Method == http.MethodHead {
+		c.Status(status)
+		return
+	}
+
+	var data *FileData
+	if ranged {
+		data, err = client.GetRange(c, objectID, offset, length)
+	} else {
+		dto := client.GetOne(c, objectID)
+		data, err = dto.Data, dto.Error
+	}
+	if err != nil {
+		header.Del("Content-Range")
+		header.Del("Content-Length")
+		abortWithObjectError(c, err)
+		return
+	}
+	defer data.Reader.Close()
+
+	c.Status(status)
+	if _, err := io.CopyN(c.Writer, data.Reader, length); err != nil {
+		log.Debug().Err(err).Msgf("failed to stream object %s", objectID)
+	}
+}
+
+func abortWithObjectError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrObjectNotFound), errors.Is(err, ErrInvalidObjectID):
+		_ = c.AbortWithError(http.StatusNotFound, ErrObjectNotFound)
+	case errors.Is(err, ErrInvalidRange):
+		_ = c.AbortWithError(http.StatusRequestedRangeNotSatisfiable, err)
+	default:
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+	}
+}
+
+// notModified checks If-None-Match and, if it's absent, If-Modified-Since.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etag != "" && matchETag(inm, etag)
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		t, err := http.ParseTime(ims)
+		return err == nil && !lastModified.Truncate(time.Second).After(t)
+	}
+	return false
+}
+
+// requestRange returns the requested range of the object. Multiple ranges and ranges
+// with mismatched If-Range are ignored, the whole object is returned then.
+func requestRange(r *http.Request, info *ObjectInfo, etag string) (offset int64, length int64, ranged bool, err error) {
+	value := r.Header.Get("Range")
+	if value == "" || !ifRangeMatches(r, etag, info.LastModified) {
+		return 0, info.Size, false, nil
+	}
+
+	spec, ok := strings.CutPrefix(value, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, info.Size, false, nil
+	}
+
+	startStr, endStr, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, 0, false, ErrInvalidRange
+	}
+
+	if startStr == "" {
+		// Suffix range: the last n bytes
+		n, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || n <= 0 || info.Size == 0 {
+			return 0, 0, false, ErrInvalidRange
+		}
+		n = min(n, info.Size)
+		return info.Size - n, n, true, nil
+	}
+
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 || start >= info.Size {
+		return 0, 0, false, ErrInvalidRange
+	}
+
+	end := info.Size - 1
+	if endStr != "" {
+		end, err = strconv.ParseInt(endStr, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, false, ErrInvalidRange
+		}
+		end = min(end, info.Size-1)
+	}
+
+	return start, end - start + 1, true, nil
+}
+
+func ifRangeMatches(r *http.Request, etag string, lastModified time.Time) bool {
+	value := r.Header.Get("If-Range")
+	if value == "" {
+		return true
+	}
+	if strings.HasPrefix(value, `"`) || strings.HasPrefix(value, `W/"`) {
+		// Strong comparison is required for ranges
+		return etag != "" && value == etag
+	}
+
+	t, err := http.ParseTime(value)
+	return err == nil && !lastModified.IsZero() && lastModified.Truncate(time.Second).Equal(t)
+}
+
+// matchETag checks the If-None-Match list with the weak comparison.
+func matchETag(list string, etag string) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+func quoteETag(etag string) string {
+	if etag == "" || strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, `W/"`) {
+		return etag
+	}
+	return `"` + etag + `"`
+}
+
+func contentDisposition(info *ObjectInfo, attachment bool) string {
+	dispositionType := "inline"
+	if attachment {
+		dispositionType = "attachment"
+	}
+
+	filename := info.UserMetadata[OriginalFilenameMetadata]
+	if filename == "" {
+		if !attachment {
+			return ""
+		}
+		filename = info.ID[strings.LastIndex(info.ID, "/")+1:]
+	}
+
+	return mime.FormatMediaType(dispositionType, map[string]string{"filename": filename})
+}
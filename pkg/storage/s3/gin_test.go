@@ -0,0 +1,196 @@
+package s3_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/mandarine-io/baselib/pkg/storage/s3"
+	"github.com/mandarine-io/baselib/pkg/storage/s3/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const content = "0123456789"
+
+func newFileServer(t *testing.T, opts s3.ServeOptions) (http.Handler, *s3.ObjectInfo) {
+	gin.SetMode(gin.TestMode)
+	client := memory.NewClient("test")
+	dto := client.CreateOne(context.Background(), &s3.FileData{
+		ID:           "docs/report.txt",
+		Size:         int64(len(content)),
+		ContentType:  "text/plain",
+		Reader:       io.NopCloser(bytes.NewReader([]byte(content))),
+		UserMetadata: map[string]string{s3.OriginalFilenameMetadata: "Report.txt"},
+	})
+	require.NoError(t, dto.Error)
+
+	info, err := client.Stat(context.Background(), "docs/report.txt")
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.GET("/files/*id", s3.FileHandler(client, objectID, opts))
+	r.HEAD("/files/*id", s3.FileHandler(client, objectID, opts))
+	return r, info
+}
+
+func objectID(c *gin.Context) (string, error) {
+	return c.Param("id")[1:], nil
+}
+
+func serve(h http.Handler, method string, target string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestServeObject_Get(t *testing.T) {
+	h, info := newFileServer(t, s3.ServeOptions{CacheControl: "private, max-age=60"})
+
+	w := serve(h, http.MethodGet, "/files/docs/report.txt", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, content, w.Body.String())
+	assert.Equal(t, "text/plain", w.Header().Get("Content-Type"))
+	assert.Equal(t, "10", w.Header().Get("Content-Length"))
+	assert.Equal(t, `"`+info.ETag+`"`, w.Header().Get("ETag"))
+	assert.Equal(t, "bytes", w.Header().Get("Accept-Ranges"))
+	assert.Equal(t, "private, max-age=60", w.Header().Get("Cache-Control"))
+	assert.Equal(t, `inline; filename=Report.txt`, w.Header().Get("Content-Disposition"))
+
+	w = serve(h, http.MethodGet, "/files/docs/missing.txt", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestServeObject_Head(t *testing.T) {
+	h, _ := newFileServer(t, s3.ServeOptions{Attachment: true})
+
+	w := serve(h, http.MethodHead, "/files/docs/report.txt", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, "10", w.Header().Get("Content-Length"))
+	assert.Equal(t, `attachment; filename=Report.txt`, w.Header().Get("Content-Disposition"))
+
+	w = serve(h, http.MethodHead, "/files/docs/report.txt", map[string]string{"Range": "bytes=2-5"})
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, "4", w.Header().Get("Content-Length"))
+	assert.Equal(t, "bytes 2-5/10", w.Header().Get("Content-Range"))
+}
+
+func TestServeObject_Range(t *testing.T) {
+	h, _ := newFileServer(t, s3.ServeOptions{})
+
+	tests := []struct {
+		name         string
+		rangeHeader  string
+		status       int
+		body         string
+		contentRange string
+	}{
+		{name: "closed", rangeHeader: "bytes=2-5", status: http.StatusPartialContent, body: "2345", contentRange: "bytes 2-5/10"},
+		{name: "open", rangeHeader: "bytes=7-", status: http.StatusPartialContent, body: "789", contentRange: "bytes 7-9/10"},
+		{name: "suffix", rangeHeader: "bytes=-3", status: http.StatusPartialContent, body: "789", contentRange: "bytes 7-9/10"},
+		{name: "suffix longer than object", rangeHeader: "bytes=-30", status: http.StatusPartialContent, body: content, contentRange: "bytes 0-9/10"},
+		{name: "end beyond object", rangeHeader: "bytes=8-100", status: http.StatusPartialContent, body: "89", contentRange: "bytes 8-9/10"},
+		{name: "multiple ranges ignored", rangeHeader: "bytes=0-1,4-5", status: http.StatusOK, body: content},
+		{name: "other unit ignored", rangeHeader: "items=0-1", status: http.StatusOK, body: content},
+		{name: "start beyond object", rangeHeader: "bytes=10-", status: http.StatusRequestedRangeNotSatisfiable, contentRange: "bytes */10"},
+		{name: "reversed", rangeHeader: "bytes=5-2", status: http.StatusRequestedRangeNotSatisfiable, contentRange: "bytes */10"},
+		{name: "malformed", rangeHeader: "bytes=abc", status: http.StatusRequestedRangeNotSatisfiable, contentRange: "bytes */10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(h, http.MethodGet, "/files/docs/report.txt", map[string]string{"Range": tt.rangeHeader})
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, tt.contentRange, w.Header().Get("Content-Range"))
+			if tt.status != http.StatusRequestedRangeNotSatisfiable {
+				assert.Equal(t, tt.body, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestServeObject_IfRange(t *testing.T) {
+	h, info := newFileServer(t, s3.ServeOptions{})
+	lastModified := info.LastModified.UTC().Format(http.TimeFormat)
+
+	tests := []struct {
+		name    string
+		ifRange string
+		status  int
+		body    string
+	}{
+		{name: "matching etag", ifRange: `"` + info.ETag + `"`, status: http.StatusPartialContent, body: "2345"},
+		{name: "stale etag", ifRange: `"stale"`, status: http.StatusOK, body: content},
+		{name: "weak etag", ifRange: `W/"` + info.ETag + `"`, status: http.StatusOK, body: content},
+		{name: "matching date", ifRange: lastModified, status: http.StatusPartialContent, body: "2345"},
+		{name: "stale date", ifRange: info.LastModified.Add(-time.Hour).UTC().Format(http.TimeFormat), status: http.StatusOK, body: content},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(h, http.MethodGet, "/files/docs/report.txt", map[string]string{
+				"Range":    "bytes=2-5",
+				"If-Range": tt.ifRange,
+			})
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, tt.body, w.Body.String())
+		})
+	}
+}
+
+func TestServeObject_NotModified(t *testing.T) {
+	h, info := newFileServer(t, s3.ServeOptions{})
+	etag := `"` + info.ETag + `"`
+
+	tests := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		status  int
+	}{
+		{name: "matching etag", method: http.MethodGet, headers: map[string]string{"If-None-Match": etag}, status: http.StatusNotModified},
+		{name: "etag in list", method: http.MethodGet, headers: map[string]string{"If-None-Match": `"other", W/` + etag}, status: http.StatusNotModified},
+		{name: "any etag", method: http.MethodHead, headers: map[string]string{"If-None-Match": "*"}, status: http.StatusNotModified},
+		{name: "other etag", method: http.MethodGet, headers: map[string]string{"If-None-Match": `"other"`}, status: http.StatusOK},
+		{
+			name:    "not modified since",
+			method:  http.MethodGet,
+			headers: map[string]string{"If-Modified-Since": info.LastModified.Add(time.Second).UTC().Format(http.TimeFormat)},
+			status:  http.StatusNotModified,
+		},
+		{
+			name:    "modified since",
+			method:  http.MethodGet,
+			headers: map[string]string{"If-Modified-Since": info.LastModified.Add(-time.Hour).UTC().Format(http.TimeFormat)},
+			status:  http.StatusOK,
+		},
+		{
+			name:   "etag takes precedence over date",
+			method: http.MethodGet,
+			headers: map[string]string{
+				"If-None-Match":     `"other"`,
+				"If-Modified-Since": info.LastModified.Add(time.Second).UTC().Format(http.TimeFormat),
+			},
+			status: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(h, tt.method, "/files/docs/report.txt", tt.headers)
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, etag, w.Header().Get("ETag"))
+			if tt.status == http.StatusNotModified {
+				assert.Empty(t, w.Body.String())
+				assert.Empty(t, w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package imaging
+
+import (
+	"bytes"
+	"context"
+	"github.com/mandarine-io/baselib/pkg/storage/s3"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"io"
+	"strings"
+)
+
+type client struct {
+	s3.Client
+	processor *Processor
+}
+
+// NewClient wraps the client to process images on upload: the original is transformed by
+// Config.Original and the Config.Eager presets are generated. Failures of derivatives
+// don't fail the upload, since they're generated on demand anyway. Derivatives are deleted
+// with the object by DeleteOne, DeleteMany and Move.
+//
+// Objects with the content type other than "image/*", derivatives under Config.DerivedPrefix,
+// images larger than Config.MaxSourceSize and images of the formats that can't be decoded
+// (e.g. svg, avif, heic) are stored as is.
+func NewClient(s3Client s3.Client, processor *Processor) s3.Client {
+	return &client{Client: s3Client, processor: processor}
+}
+
+func (c *client) Bucket(name string) s3.Client {
+	return &client{Client: c.Client.Bucket(name), processor: c.processor}
+}
+
+func (c *client) Prefix(prefix string) s3.Client {
+	return &client{Client: c.Client.Prefix(prefix), processor: c.processor}
+}
+
+func (c *client) CreateOne(ctx context.Context, file *s3.FileData) *s3.CreateDto {
+	log.Debug().Msg("process image and create one object")
+	if file == nil || !strings.HasPrefix(strings.ToLower(file.ContentType), "image/") || c.processor.isDerived(file.ID) {
+		return c.Client.CreateOne(ctx, file)
+	}
+
+	maxSize := c.processor.cfg.MaxSourceSize
+	if file.Size > maxSize {
+		return c.Client.CreateOne(ctx, file)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file.Reader, maxSize+1))
+	if err != nil {
+		return &s3.CreateDto{Error: err}
+	}
+	if int64(len(data)) > maxSize {
+		// Too large to process, store the read data followed by the rest of the reader
+		return c.Client.CreateOne(ctx, &s3.FileData{
+			ID:           file.ID,
+			Size:         file.Size,
+			ContentType:  file.ContentType,
+			Reader:       readCloser{Reader: io.MultiReader(bytes.NewReader(data), file.Reader), Closer: file.Reader},
+			UserMetadata: file.UserMetadata,
+		})
+	}
+
+	contentType := file.ContentType
+	unsupported := false
+	if original := c.processor.cfg.Original; original != nil {
+		out, format, err := Process(data, *original, c.processor.cfg.MaxPixels)
+		switch {
+		case errors.Is(err, ErrUnsupportedImage):
+			log.Debug().Err(err).Msgf("image %s is not supported, store original", file.ID)
+			unsupported = true
+		case err != nil:
+			return &s3.CreateDto{Error: err}
+		default:
+			data, contentType = out, format.ContentType()
+		}
+	}
+
+	dto := c.Client.CreateOne(ctx, &s3.FileData{
+		ID:           file.ID,
+		Size:         int64(len(data)),
+		ContentType:  contentType,
+		Reader:       io.NopCloser(bytes.NewReader(data)),
+		UserMetadata: file.UserMetadata,
+	})
+	if dto.Error != nil || unsupported || len(c.processor.cfg.Eager) == 0 {
+		return dto
+	}
+
+	c.deriveEager(ctx, dto.ObjectID, contentType, data)
+	return dto
+}
+
+func (c *client) CreateMany(ctx context.Context, files []*s3.FileData, opts ...s3.BatchOption) ([]*s3.CreateDto, error) {
+	log.Debug().Msg("process images and create many object")
+	return s3.BatchCreate(ctx, files, c.CreateOne, opts...)
+}
+
+func (c *client) DeleteOne(ctx context.Context, objectID string) error {
+	log.Debug().Msg("delete one object with derived images")
+
+	if err := c.Client.DeleteOne(ctx, objectID); err != nil {
+		return err
+	}
+	c.deleteDerived(ctx, objectID)
+	return nil
+}
+
+func (c *client) DeleteMany(ctx context.Context, objectIDs []string) map[string]error {
+	log.Debug().Msg("delete many object with derived images")
+
+	errs := c.Client.DeleteMany(ctx, objectIDs)
+	for _, objectID := range objectIDs {
+		if errs[objectID] == nil {
+			c.deleteDerived(ctx, objectID)
+		}
+	}
+	return errs
+}
+
+func (c *client) Move(ctx context.Context, srcObjectID string, dstObjectID string) error {
+	log.Debug().Msg("move object with derived images")
+
+	if err := c.Client.Move(ctx, srcObjectID, dstObjectID); err != nil {
+		return err
+	}
+	c.deleteDerived(ctx, srcObjectID)
+	return nil
+}
+
+func (c *client) deriveEager(ctx context.Context, objectID string, contentType string, data []byte) {
+	info, err := c.Client.Stat(ctx, objectID)
+	if err != nil {
+		log.Warn().Err(err).Msgf("failed to stat object %s", objectID)
+		return
+	}
+
+	for _, name := range c.processor.cfg.Eager {
+		t := c.processor.cfg.Presets[name]
+		t.Format = c.processor.format(t, contentType)
+		if err := c.processor.store(ctx, c.Client, objectID, info.ETag, data, t); err != nil {
+			log.Warn().Err(err).Msgf("failed to derive image %s of object %s", name, objectID)
+		}
+	}
+}
+
+func (c *client) deleteDerived(ctx context.Context, objectID string) {
+	err := c.processor.DeleteDerived(ctx, c.Client, objectID)
+	if err != nil && !errors.Is(err, s3.ErrNotSupported) {
+		log.Warn().Err(err).Msgf("failed to delete derived images of object %s", objectID)
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
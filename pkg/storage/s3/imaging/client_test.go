@@ -0,0 +1,41 @@
+package imaging
+
+import (
+	"bytes"
+	"context"
+	"github.com/mandarine-io/baselib/pkg/storage/s3"
+	"github.com/mandarine-io/baselib/pkg/storage/s3/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+)
+
+func TestClient_CreateOneStoresUnsupportedOriginal(t *testing.T) {
+	ctx := context.Background()
+	processor, err := NewProcessor(Config{
+		Presets:  map[string]Transform{"thumbnail": {Width: 64, Height: 64}},
+		Eager:    []string{"thumbnail"},
+		Original: &Transform{},
+	})
+	require.NoError(t, err)
+	c := NewClient(memory.NewClient("test"), processor)
+
+	svg := []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="1" height="1"/>`)
+	dto := c.CreateOne(ctx, &s3.FileData{
+		ID:          "logo.svg",
+		Size:        int64(len(svg)),
+		ContentType: "image/svg+xml",
+		Reader:      io.NopCloser(bytes.NewReader(svg)),
+	})
+	require.NoError(t, dto.Error)
+
+	got := c.GetOne(ctx, "logo.svg")
+	require.NoError(t, got.Error)
+	defer got.Data.Reader.Close()
+
+	data, err := io.ReadAll(got.Data.Reader)
+	require.NoError(t, err)
+	assert.Equal(t, svg, data)
+	assert.Equal(t, "image/svg+xml", got.Data.ContentType)
+}
@@ -0,0 +1,51 @@
+package imaging
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mandarine-io/baselib/pkg/storage/s3"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"net/http"
+)
+
+// PresetQueryParam is the query parameter with the preset name of FileHandler.
+const PresetQueryParam = "preset"
+
+// FileHandler returns the gin handler streaming the object like s3.FileHandler. If the request
+// has the PresetQueryParam, the derivative by the preset is served, it's generated on demand.
+// Only the named presets are allowed, so clients can't request arbitrary transforms.
+func (p *Processor) FileHandler(client s3.Client, objectIDFunc s3.ObjectIDFunc, opts s3.ServeOptions) gin.HandlerFunc {
+	log.Debug().Msg("setup image file handler")
+	return func(c *gin.Context) {
+		objectID, err := objectIDFunc(c)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		if preset := c.Query(PresetQueryParam); preset != "" {
+			objectID, err = p.DerivePreset(c, client, objectID, preset)
+			if err != nil {
+				_ = c.AbortWithError(deriveErrorStatus(err), err)
+				return
+			}
+		}
+
+		s3.ServeObject(c, client, objectID, opts)
+	}
+}
+
+func deriveErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrUnknownPreset):
+		return http.StatusBadRequest
+	case errors.Is(err, s3.ErrObjectNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrUnsupportedImage):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, ErrImageTooLarge):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,228 @@
+package imaging
+
+import (
+	"bytes"
+	"context"
+	"github.com/mandarine-io/baselib/pkg/storage/s3"
+	"github.com/mandarine-io/baselib/pkg/transport/http/model"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"io"
+	"strings"
+)
+
+const (
+	// SourceETagMetadata is the metadata key of the source object ETag the derivative is generated from.
+	SourceETagMetadata = "x-amz-meta-source-etag"
+	// SourceIDMetadata is the metadata key of the source object id of the derivative.
+	SourceIDMetadata = "x-amz-meta-source-id"
+
+	defaultDerivedPrefix = "_derived/"
+	defaultMaxSourceSize = 32 * 1024 * 1024
+)
+
+var ErrUnknownPreset = model.NewI18nError("unknown image preset", "errors.unknown_image_preset")
+
+type Config struct {
+	// Presets are the named transforms, e.g. "thumbnail".
+	Presets map[string]Transform
+	// Eager is the list of presets generated on upload by the client of NewClient.
+	// Other presets are generated on demand.
+	Eager []string
+	// Original is the transform applied to the uploaded image before it's stored by the client
+	// of NewClient, e.g. the empty Transform strips the metadata. If nil, the original is stored as is.
+	Original *Transform
+	// DerivedPrefix is the key prefix of derived objects. If empty, "_derived/" is used.
+	DerivedPrefix string
+	// MaxSourceSize is the maximum size of the source image in bytes. If zero, 32 MiB is used.
+	MaxSourceSize int64
+	// MaxPixels is the maximum number of pixels of the source image. If zero, 50 megapixels is used.
+	MaxPixels int
+}
+
+// Processor generates derivatives of images stored in s3.Client. The derivative is stored
+// as the object "<DerivedPrefix><objectID>/<transform><ext>" with the ETag of the source
+// in SourceETagMetadata, so it's regenerated after the source is replaced.
+type Processor struct {
+	cfg Config
+}
+
+func NewProcessor(cfg Config) (*Processor, error) {
+	log.Debug().Msg("setup image processor")
+
+	for name, t := range cfg.Presets {
+		if err := t.validate(); err != nil {
+			return nil, errors.Wrapf(err, "preset %s", name)
+		}
+	}
+	for _, name := range cfg.Eager {
+		if _, ok := cfg.Presets[name]; !ok {
+			return nil, errors.Wrapf(ErrUnknownPreset, "eager preset %s", name)
+		}
+	}
+	if cfg.Original != nil {
+		if err := cfg.Original.validate(); err != nil {
+			return nil, errors.Wrap(err, "original transform")
+		}
+	}
+
+	if cfg.DerivedPrefix == "" {
+		cfg.DerivedPrefix = defaultDerivedPrefix
+	}
+	if !strings.HasSuffix(cfg.DerivedPrefix, "/") {
+		cfg.DerivedPrefix += "/"
+	}
+	if cfg.MaxSourceSize <= 0 {
+		cfg.MaxSourceSize = defaultMaxSourceSize
+	}
+	if cfg.MaxPixels <= 0 {
+		cfg.MaxPixels = defaultMaxPixels
+	}
+
+	return &Processor{cfg: cfg}, nil
+}
+
+func MustNewProcessor(cfg Config) *Processor {
+	p, err := NewProcessor(cfg)
+	if err != nil {
+		log.Fatal().Stack().Err(err).Msg("failed to setup image processor")
+	}
+	return p
+}
+
+// DerivePreset returns the id of the derivative of the object by the named preset,
+// generating it if it's missing or stale.
+func (p *Processor) DerivePreset(ctx context.Context, client s3.Client, objectID string, preset string) (string, error) {
+	t, ok := p.cfg.Presets[preset]
+	if !ok {
+		return "", ErrUnknownPreset
+	}
+	return p.Derive(ctx, client, objectID, t)
+}
+
+// Derive returns the id of the derivative of the object by the transform,
+// generating it if it's missing or stale.
+func (p *Processor) Derive(ctx context.Context, client s3.Client, objectID string, t Transform) (string, error) {
+	log.Debug().Msgf("derive image of object %s", objectID)
+
+	if err := t.validate(); err != nil {
+		return "", err
+	}
+
+	src, err := client.Stat(ctx, objectID)
+	if err != nil {
+		return "", err
+	}
+
+	t.Format = p.format(t, src.ContentType)
+	derivedID := p.derivedID(objectID, t)
+	derived, err := client.Stat(ctx, derivedID)
+	switch {
+	case err == nil && derived.UserMetadata[SourceETagMetadata] == src.ETag:
+		return derivedID, nil
+	case err != nil && !errors.Is(err, s3.ErrObjectNotFound):
+		return "", err
+	}
+
+	data, err := p.read(ctx, client, objectID)
+	if err != nil {
+		return "", err
+	}
+	if err := p.store(ctx, client, objectID, src.ETag, data, t); err != nil {
+		return "", err
+	}
+	return derivedID, nil
+}
+
+// DeleteDerived deletes all the derivatives of the object.
+func (p *Processor) DeleteDerived(ctx context.Context, client s3.Client, objectID string) error {
+	log.Debug().Msgf("delete derived images of object %s", objectID)
+
+	var ids []string
+	err := s3.Walk(ctx, client, p.cfg.DerivedPrefix+objectID+"/", func(info s3.ObjectInfo) error {
+		ids = append(ids, info.ID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	for id, err := range client.DeleteMany(ctx, ids) {
+		if err != nil && !errors.Is(err, s3.ErrObjectNotFound) {
+			return errors.Wrapf(err, "delete %s", id)
+		}
+	}
+	return nil
+}
+
+// isDerived reports whether the object id is in the derived prefix.
+func (p *Processor) isDerived(objectID string) bool {
+	return strings.HasPrefix(objectID, p.cfg.DerivedPrefix)
+}
+
+// derivedID returns the id of the derivative, t.Format must be set.
+func (p *Processor) derivedID(objectID string, t Transform) string {
+	return p.cfg.DerivedPrefix + objectID + "/" + t.key()
+}
+
+// format returns the output format of the transform for the source content type.
+func (p *Processor) format(t Transform, contentType string) Format {
+	if t.Format != "" {
+		return t.Format
+	}
+	switch strings.ToLower(contentType) {
+	case "image/jpeg", "image/jpg":
+		return FormatJPEG
+	case "image/webp":
+		return FormatWebP
+	default:
+		return FormatPNG
+	}
+}
+
+func (p *Processor) read(ctx context.Context, client s3.Client, objectID string) ([]byte, error) {
+	dto := client.GetOne(ctx, objectID)
+	if dto.Error != nil {
+		return nil, dto.Error
+	}
+	defer func() {
+		_ = dto.Data.Reader.Close()
+	}()
+
+	if dto.Data.Size > p.cfg.MaxSourceSize {
+		return nil, ErrImageTooLarge
+	}
+	data, err := io.ReadAll(io.LimitReader(dto.Data.Reader, p.cfg.MaxSourceSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > p.cfg.MaxSourceSize {
+		return nil, ErrImageTooLarge
+	}
+	return data, nil
+}
+
+// store generates the derivative from the source data and stores it, t.Format must be set.
+// The client of NewClient stores the objects under DerivedPrefix as is, so the client
+// may be wrapped by it at any depth.
+func (p *Processor) store(ctx context.Context, s3Client s3.Client, objectID string, etag string, data []byte, t Transform) error {
+	out, _, err := Process(data, t, p.cfg.MaxPixels)
+	if err != nil {
+		return err
+	}
+
+	dto := s3Client.CreateOne(ctx, &s3.FileData{
+		ID:          p.derivedID(objectID, t),
+		Size:        int64(len(out)),
+		ContentType: t.Format.ContentType(),
+		Reader:      io.NopCloser(bytes.NewReader(out)),
+		UserMetadata: map[string]string{
+			SourceETagMetadata: etag,
+			SourceIDMetadata:   objectID,
+		},
+	})
+	return dto.Error
+}
@@ -0,0 +1,97 @@
+package imaging
+
+import (
+	"bytes"
+	"context"
+	"github.com/mandarine-io/baselib/pkg/storage/s3"
+	"github.com/mandarine-io/baselib/pkg/storage/s3/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+)
+
+// decorator is another wrapper around the client of NewClient.
+type decorator struct {
+	s3.Client
+}
+
+func TestProcessor_DeriveThroughWrappedClient(t *testing.T) {
+	ctx := context.Background()
+	processor, err := NewProcessor(Config{
+		Presets:  map[string]Transform{"thumbnail": {Width: 10, Format: FormatPNG}},
+		Original: &Transform{Format: FormatJPEG},
+	})
+	require.NoError(t, err)
+	c := decorator{Client: NewClient(memory.NewClient("test"), processor)}
+
+	data := encodePNG(t, halves(40, 20))
+	dto := c.CreateOne(ctx, &s3.FileData{
+		ID:          "photo",
+		Size:        int64(len(data)),
+		ContentType: "image/png",
+		Reader:      io.NopCloser(bytes.NewReader(data)),
+	})
+	require.NoError(t, dto.Error)
+
+	derivedID, err := processor.DerivePreset(ctx, c, "photo", "thumbnail")
+	require.NoError(t, err)
+	assert.Equal(t, "_derived/photo/10x0_contain.png", derivedID)
+
+	// The derivative isn't converted by the original transform of the client
+	info, err := c.Stat(ctx, derivedID)
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", info.ContentType)
+	assert.Equal(t, "photo", info.UserMetadata[SourceIDMetadata])
+
+	src, err := c.Stat(ctx, "photo")
+	require.NoError(t, err)
+	assert.Equal(t, src.ETag, info.UserMetadata[SourceETagMetadata])
+}
+
+func TestProcessor_DeriveRegeneratesStale(t *testing.T) {
+	ctx := context.Background()
+	processor, err := NewProcessor(Config{})
+	require.NoError(t, err)
+	c := memory.NewClient("test")
+
+	upload := func(w, h int) {
+		data := encodePNG(t, halves(w, h))
+		dto := c.CreateOne(ctx, &s3.FileData{
+			ID:          "photo",
+			Size:        int64(len(data)),
+			ContentType: "image/png",
+			Reader:      io.NopCloser(bytes.NewReader(data)),
+		})
+		require.NoError(t, dto.Error)
+	}
+	derive := func() *s3.ObjectInfo {
+		derivedID, err := processor.Derive(ctx, c, "photo", Transform{Width: 10})
+		require.NoError(t, err)
+		info, err := c.Stat(ctx, derivedID)
+		require.NoError(t, err)
+		return info
+	}
+
+	upload(40, 20)
+	first := derive()
+	assert.Equal(t, first.ETag, derive().ETag)
+
+	upload(20, 40)
+	second := derive()
+	assert.NotEqual(t, first.UserMetadata[SourceETagMetadata], second.UserMetadata[SourceETagMetadata])
+	assert.NotEqual(t, first.ETag, second.ETag)
+
+	require.NoError(t, processor.DeleteDerived(ctx, c, "photo"))
+	exists, err := c.Exists(ctx, second.ID)
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestProcessor_DeriveUnknownPreset(t *testing.T) {
+	processor, err := NewProcessor(Config{})
+	require.NoError(t, err)
+
+	_, err = processor.DerivePreset(context.Background(), memory.NewClient("test"), "photo", "thumbnail")
+	assert.ErrorIs(t, err, ErrUnknownPreset)
+}
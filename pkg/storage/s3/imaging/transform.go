@@ -0,0 +1,275 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/mandarine-io/baselib/pkg/transport/http/model"
+	"github.com/pkg/errors"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+)
+
+const (
+	FormatJPEG Format = "jpeg"
+	FormatPNG  Format = "png"
+	FormatWebP Format = "webp"
+
+	// FitContain scales the image to fit into the box keeping the aspect ratio.
+	FitContain Fit = "contain"
+	// FitCover scales the image to cover the box keeping the aspect ratio and crops the center.
+	FitCover Fit = "cover"
+	// FitFill stretches the image to the box.
+	FitFill Fit = "fill"
+
+	defaultQuality   = 85
+	defaultMaxPixels = 50_000_000
+)
+
+var (
+	ErrUnsupportedImage = model.NewI18nError("unsupported image format", "errors.unsupported_image")
+	ErrImageTooLarge    = model.NewI18nError("image dimensions are too large", "errors.image_too_large")
+	ErrInvalidTransform = errors.New("invalid image transform")
+)
+
+type (
+	// Format is the output image format.
+	Format string
+
+	// Fit is the way the image is resized to the box of Width x Height.
+	Fit string
+
+	// Transform describes the derivative of the image. The EXIF orientation is applied
+	// and all the metadata is stripped, since the image is always re-encoded.
+	Transform struct {
+		// Width and Height are the box of the resized image. If one of them is zero, it's computed
+		// from the aspect ratio. If both are zero, the image isn't resized.
+		Width  int
+		Height int
+		// Fit is the resize mode. If empty, FitContain is used.
+		Fit Fit
+		// Upscale allows to enlarge images smaller than the box.
+		Upscale bool
+		// Crop is the region of the (oriented) source image cropped before resizing.
+		// If empty, the whole image is used.
+		Crop image.Rectangle
+		// Format is the output format. If empty, the source format is kept
+		// (GIF is converted to PNG).
+		Format Format
+		// Quality is the JPEG quality 1-100. If zero, 85 is used. WebP is always lossless.
+		Quality int
+	}
+)
+
+// ContentType returns the MIME type of the format.
+func (f Format) ContentType() string {
+	return "image/" + string(f)
+}
+
+// Extension returns the file extension of the format.
+func (f Format) Extension() string {
+	if f == FormatJPEG {
+		return ".jpg"
+	}
+	return "." + string(f)
+}
+
+func (t Transform) validate() error {
+	if t.Width < 0 || t.Height < 0 || t.Quality < 0 || t.Quality > 100 {
+		return ErrInvalidTransform
+	}
+	switch t.Fit {
+	case "", FitContain, FitCover, FitFill:
+	default:
+		return ErrInvalidTransform
+	}
+	switch t.Format {
+	case "", FormatJPEG, FormatPNG, FormatWebP:
+	default:
+		return ErrInvalidTransform
+	}
+	if (t.Fit == FitCover || t.Fit == FitFill) && (t.Width == 0 || t.Height == 0) {
+		return ErrInvalidTransform
+	}
+	return nil
+}
+
+// key returns the unique representation of the transform used in the derived object id.
+func (t Transform) key() string {
+	fit := t.Fit
+	if fit == "" {
+		fit = FitContain
+	}
+
+	var sb strings.Builder
+	_, _ = fmt.Fprintf(&sb, "%dx%d_%s", t.Width, t.Height, fit)
+	if t.Upscale {
+		sb.WriteString("_up")
+	}
+	if !t.Crop.Empty() {
+		_, _ = fmt.Fprintf(&sb, "_c%d,%d,%d,%d", t.Crop.Min.X, t.Crop.Min.Y, t.Crop.Max.X, t.Crop.Max.Y)
+	}
+	if t.Format == FormatJPEG {
+		_, _ = fmt.Fprintf(&sb, "_q%d", t.quality())
+	}
+	sb.WriteString(t.Format.Extension())
+	return sb.String()
+}
+
+func (t Transform) quality() int {
+	if t.Quality == 0 {
+		return defaultQuality
+	}
+	return t.Quality
+}
+
+// Process decodes the JPEG, PNG, GIF or WebP image from data, transforms and encodes it.
+// maxPixels limits the dimensions of the source image to protect from decompression bombs,
+// zero means the default limit of 50 megapixels.
+func Process(data []byte, t Transform, maxPixels int) ([]byte, Format, error) {
+	if err := t.validate(); err != nil {
+		return nil, "", err
+	}
+	if maxPixels <= 0 {
+		maxPixels = defaultMaxPixels
+	}
+
+	cfg, srcFormat, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", errors.Wrap(ErrUnsupportedImage, err.Error())
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, "", ErrImageTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", errors.Wrap(ErrUnsupportedImage, err.Error())
+	}
+
+	if srcFormat == "jpeg" {
+		img = orient(img, exifOrientation(data))
+	}
+
+	if !t.Crop.Empty() {
+		crop := t.Crop.Add(img.Bounds().Min).Intersect(img.Bounds())
+		if crop.Empty() {
+			return nil, "", ErrInvalidTransform
+		}
+		img = subImage(img, crop)
+	}
+
+	format := t.Format
+	if format == "" {
+		format = sourceFormat(srcFormat)
+	}
+
+	img = resize(img, t, format == FormatJPEG)
+
+	var buf bytes.Buffer
+	if err := encode(&buf, img, format, t.quality()); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), format, nil
+}
+
+func sourceFormat(name string) Format {
+	switch name {
+	case "jpeg":
+		return FormatJPEG
+	case "webp":
+		return FormatWebP
+	default:
+		return FormatPNG
+	}
+}
+
+func encode(w io.Writer, img image.Image, format Format, quality int) error {
+	switch format {
+	case FormatJPEG:
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	case FormatPNG:
+		return png.Encode(w, img)
+	case FormatWebP:
+		return nativewebp.Encode(w, img, nil)
+	default:
+		return ErrInvalidTransform
+	}
+}
+
+// resize scales the image into the box of the transform. If opaque is set,
+// the transparent pixels are blended with white, since the output has no alpha channel.
+func resize(img image.Image, t Transform, opaque bool) image.Image {
+	src := img.Bounds()
+	sw, sh := src.Dx(), src.Dy()
+	dw, dh := targetSize(sw, sh, t)
+
+	// Crop the center of the source to the aspect ratio of the box
+	if t.Fit == FitCover {
+		cw, ch := sw, sh
+		if sw*dh > sh*dw {
+			cw = max(1, sh*dw/dh)
+		} else {
+			ch = max(1, sw*dh/dw)
+		}
+		x0 := src.Min.X + (sw-cw)/2
+		y0 := src.Min.Y + (sh-ch)/2
+		src = image.Rect(x0, y0, x0+cw, y0+ch)
+	}
+
+	if !t.Upscale && (dw > src.Dx() || dh > src.Dy()) {
+		dw, dh = src.Dx(), src.Dy()
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	op := draw.Src
+	if opaque {
+		draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		op = draw.Over
+	}
+	if dw == src.Dx() && dh == src.Dy() {
+		draw.Draw(dst, dst.Bounds(), img, src.Min, op)
+	} else {
+		draw.CatmullRom.Scale(dst, dst.Bounds(), img, src, op, nil)
+	}
+	return dst
+}
+
+func targetSize(sw, sh int, t Transform) (int, int) {
+	w, h := t.Width, t.Height
+	switch {
+	case w == 0 && h == 0:
+		return sw, sh
+	case t.Fit == FitCover || t.Fit == FitFill:
+		return w, h
+	case w == 0:
+		return max(1, sw*h/sh), h
+	case h == 0:
+		return w, max(1, sh*w/sw)
+	}
+
+	// Contain: scale by the smaller ratio
+	if sw*h > sh*w {
+		return w, max(1, sh*w/sw)
+	}
+	return max(1, sw*h/sh), h
+}
+
+func subImage(img image.Image, r image.Rectangle) image.Image {
+	if si, ok := img.(interface {
+		SubImage(r image.Rectangle) image.Image
+	}); ok {
+		return si.SubImage(r)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, r.Min, draw.Src)
+	return dst
+}
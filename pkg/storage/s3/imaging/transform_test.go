@@ -0,0 +1,205 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func TestProcess_Resize(t *testing.T) {
+	data := encodePNG(t, halves(200, 100))
+
+	tests := []struct {
+		name      string
+		transform Transform
+		width     int
+		height    int
+	}{
+		{name: "contain", transform: Transform{Width: 50, Height: 50}, width: 50, height: 25},
+		{name: "width only", transform: Transform{Width: 100}, width: 100, height: 50},
+		{name: "height only", transform: Transform{Height: 20}, width: 40, height: 20},
+		{name: "cover", transform: Transform{Width: 50, Height: 50, Fit: FitCover}, width: 50, height: 50},
+		{name: "fill", transform: Transform{Width: 30, Height: 60, Fit: FitFill}, width: 30, height: 60},
+		{name: "no upscale", transform: Transform{Width: 400, Height: 400}, width: 200, height: 100},
+		{name: "upscale", transform: Transform{Width: 400, Height: 400, Upscale: true}, width: 400, height: 200},
+		{name: "crop", transform: Transform{Crop: image.Rect(0, 0, 100, 100)}, width: 100, height: 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, format, err := Process(data, tt.transform, 0)
+			require.NoError(t, err)
+			assert.Equal(t, FormatPNG, format)
+
+			cfg, name, err := image.DecodeConfig(bytes.NewReader(out))
+			require.NoError(t, err)
+			assert.Equal(t, "png", name)
+			assert.Equal(t, tt.width, cfg.Width)
+			assert.Equal(t, tt.height, cfg.Height)
+		})
+	}
+}
+
+func TestProcess_Format(t *testing.T) {
+	var gifData bytes.Buffer
+	require.NoError(t, gif.Encode(&gifData, halves(20, 10), nil))
+
+	tests := []struct {
+		name      string
+		data      []byte
+		transform Transform
+		format    Format
+		decoded   string
+	}{
+		{name: "keep png", data: encodePNG(t, halves(20, 10)), format: FormatPNG, decoded: "png"},
+		{name: "keep jpeg", data: encodeJPEG(t, halves(20, 10)), format: FormatJPEG, decoded: "jpeg"},
+		{name: "gif to png", data: gifData.Bytes(), format: FormatPNG, decoded: "png"},
+		{name: "png to jpeg", data: encodePNG(t, halves(20, 10)), transform: Transform{Format: FormatJPEG}, format: FormatJPEG, decoded: "jpeg"},
+		{name: "png to webp", data: encodePNG(t, halves(20, 10)), transform: Transform{Format: FormatWebP}, format: FormatWebP, decoded: "webp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, format, err := Process(tt.data, tt.transform, 0)
+			require.NoError(t, err)
+			assert.Equal(t, tt.format, format)
+
+			_, name, err := image.DecodeConfig(bytes.NewReader(out))
+			require.NoError(t, err)
+			assert.Equal(t, tt.decoded, name)
+		})
+	}
+}
+
+func TestProcess_Errors(t *testing.T) {
+	data := encodePNG(t, halves(200, 100))
+
+	_, _, err := Process([]byte("<svg/>"), Transform{}, 0)
+	assert.ErrorIs(t, err, ErrUnsupportedImage)
+
+	_, _, err = Process(data, Transform{}, 100*100)
+	assert.ErrorIs(t, err, ErrImageTooLarge)
+
+	_, _, err = Process(data, Transform{Crop: image.Rect(300, 300, 400, 400)}, 0)
+	assert.ErrorIs(t, err, ErrInvalidTransform)
+
+	_, _, err = Process(data, Transform{Width: 10, Fit: FitCover}, 0)
+	assert.ErrorIs(t, err, ErrInvalidTransform)
+}
+
+func TestProcess_ExifOrientation(t *testing.T) {
+	// The left half is red, the right half is blue
+	src := encodeJPEG(t, halves(40, 20))
+
+	tests := []struct {
+		name        string
+		order       binary.ByteOrder
+		orientation uint16
+		width       int
+		height      int
+		// top is the color expected at the top left corner
+		top color.Color
+	}{
+		{name: "normal", order: binary.BigEndian, orientation: 1, width: 40, height: 20, top: red},
+		{name: "mirrored", order: binary.LittleEndian, orientation: 2, width: 40, height: 20, top: blue},
+		{name: "rotated 180", order: binary.BigEndian, orientation: 3, width: 40, height: 20, top: blue},
+		{name: "rotated 90 cw", order: binary.LittleEndian, orientation: 6, width: 20, height: 40, top: red},
+		{name: "rotated 90 ccw", order: binary.BigEndian, orientation: 8, width: 20, height: 40, top: blue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := withExifOrientation(src, tt.order, tt.orientation)
+			require.Equal(t, int(tt.orientation), exifOrientation(data))
+
+			out, _, err := Process(data, Transform{Format: FormatPNG}, 0)
+			require.NoError(t, err)
+
+			img, err := png.Decode(bytes.NewReader(out))
+			require.NoError(t, err)
+			assert.Equal(t, tt.width, img.Bounds().Dx())
+			assert.Equal(t, tt.height, img.Bounds().Dy())
+			assertColor(t, tt.top, img.At(2, 2))
+		})
+	}
+}
+
+func TestExifOrientation_Absent(t *testing.T) {
+	assert.Equal(t, 1, exifOrientation(encodeJPEG(t, halves(4, 4))))
+	assert.Equal(t, 1, exifOrientation(encodePNG(t, halves(4, 4))))
+	assert.Equal(t, 1, exifOrientation(withExifOrientation(encodeJPEG(t, halves(4, 4)), binary.BigEndian, 9)))
+	assert.Equal(t, 1, exifOrientation([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0xFF, 0xFF}))
+}
+
+var (
+	red  = color.RGBA{R: 0xFF, A: 0xFF}
+	blue = color.RGBA{B: 0xFF, A: 0xFF}
+)
+
+// halves returns the image with the red left half and the blue right half.
+func halves(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if x < w/2 {
+				img.Set(x, y, red)
+			} else {
+				img.Set(x, y, blue)
+			}
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100}))
+	return buf.Bytes()
+}
+
+// withExifOrientation inserts the APP1 Exif segment with the orientation tag after the JPEG SOI marker.
+func withExifOrientation(data []byte, order binary.ByteOrder, orientation uint16) []byte {
+	tiff := make([]byte, 8+2+12+4)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], 1)
+	order.PutUint16(tiff[10:], orientationTag)
+	order.PutUint16(tiff[12:], 3)
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint16(tiff[18:], orientation)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(segment)+2))
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, app1...)
+	out = append(out, segment...)
+	return append(out, data[2:]...)
+}
+
+// assertColor compares the colors with the tolerance of the lossy encoding.
+func assertColor(t *testing.T, expected, actual color.Color) {
+	t.Helper()
+	er, eg, eb, _ := expected.RGBA()
+	ar, ag, ab, _ := actual.RGBA()
+	for _, c := range [][2]uint32{{er, ar}, {eg, ag}, {eb, ab}} {
+		diff := int(c[0]>>8) - int(c[1]>>8)
+		assert.LessOrEqualf(t, diff*diff, 40*40, "expected %v, got %v", expected, actual)
+	}
+}
@@ -0,0 +1,111 @@
+package local
+
+import (
+	"context"
+	"github.com/mandarine-io/baselib/pkg/storage/s3"
+	"io"
+	"time"
+)
+
+// bucketErrorClient is the client of the bucket which directories can't be created.
+// Every operation fails with the setup error.
+type bucketErrorClient struct {
+	parent *client
+	err    error
+}
+
+func (c *bucketErrorClient) Bucket(name string) s3.Client {
+	return c.parent.Bucket(name)
+}
+
+func (c *bucketErrorClient) Prefix(string) s3.Client {
+	return c
+}
+
+func (c *bucketErrorClient) CreateOne(context.Context, *s3.FileData) *s3.CreateDto {
+	return &s3.CreateDto{Error: c.err}
+}
+
+func (c *bucketErrorClient) CreateMany(ctx context.Context, files []*s3.FileData, opts ...s3.BatchOption) ([]*s3.CreateDto, error) {
+	return s3.BatchCreate(ctx, files, c.CreateOne, opts...)
+}
+
+func (c *bucketErrorClient) GetOne(context.Context, string) *s3.GetDto {
+	return &s3.GetDto{Error: c.err}
+}
+
+func (c *bucketErrorClient) GetMany(ctx context.Context, objectIDs []string, opts ...s3.BatchOption) (map[string]*s3.GetDto, error) {
+	return s3.BatchGet(ctx, objectIDs, c.GetOne, opts...)
+}
+
+func (c *bucketErrorClient) DeleteOne(context.Context, string) error {
+	return c.err
+}
+
+func (c *bucketErrorClient) DeleteMany(_ context.Context, objectIDs []string) map[string]error {
+	errMap := make(map[string]error, len(objectIDs))
+	for _, objectID := range objectIDs {
+		errMap[objectID] = c.err
+	}
+	return errMap
+}
+
+func (c *bucketErrorClient) GetRange(context.Context, string, int64, int64) (*s3.FileData, error) {
+	return nil, c.err
+}
+
+func (c *bucketErrorClient) InitiateUpload(context.Context, string, string, map[string]string) (string, error) {
+	return "", c.err
+}
+
+func (c *bucketErrorClient) UploadPart(context.Context, string, string, int, io.Reader, int64) (*s3.Part, error) {
+	return nil, c.err
+}
+
+func (c *bucketErrorClient) ListParts(context.Context, string, string) ([]s3.Part, error) {
+	return nil, c.err
+}
+
+func (c *bucketErrorClient) CompleteUpload(context.Context, string, string, []s3.Part) error {
+	return c.err
+}
+
+func (c *bucketErrorClient) AbortUpload(context.Context, string, string) error {
+	return c.err
+}
+
+func (c *bucketErrorClient) List(context.Context, string, string) (*s3.ListPage, error) {
+	return nil, c.err
+}
+
+func (c *bucketErrorClient) Stat(context.Context, string) (*s3.ObjectInfo, error) {
+	return nil, c.err
+}
+
+func (c *bucketErrorClient) Exists(context.Context, string) (bool, error) {
+	return false, c.err
+}
+
+func (c *bucketErrorClient) Copy(context.Context, string, string) error {
+	return c.err
+}
+
+func (c *bucketErrorClient) Move(context.Context, string, string) error {
+	return c.err
+}
+
+func (c *bucketErrorClient) UpdateMetadata(context.Context, string, map[string]string) error {
+	return c.err
+}
+
+func (c *bucketErrorClient) PresignGet(context.Context, string, time.Duration) (*s3.PresignedRequest, error) {
+	return nil, c.err
+}
+
+func (c *bucketErrorClient) PresignPut(context.Context, string, time.Duration, string, int64) (*s3.PresignedRequest, error) {
+	return nil, c.err
+}
+
+func (c *bucketErrorClient) PresignPost(context.Context, string, time.Duration, s3.PostPolicy) (*s3.PresignedPost, error) {
+	return nil, c.err
+}
@@ -0,0 +1,627 @@
+package local
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"github.com/goccy/go-json"
+	"github.com/mandarine-io/baselib/pkg/storage/s3"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	listPageSize = 1000
+
+	objectsDir = "objects"
+	metaDir    = "meta"
+	tmpDir     = "tmp"
+	uploadsDir = "uploads"
+	metaExt    = ".json"
+)
+
+type metadata struct {
+	ContentType  string            `json:"contentType"`
+	ETag         string            `json:"etag"`
+	UserMetadata map[string]string `json:"userMetadata,omitempty"`
+}
+
+// storage is shared by the clients of all buckets and prefixes. The lock makes
+// the replacement of the object and its metadata atomic for readers.
+type storage struct {
+	mu   sync.RWMutex
+	root string
+}
+
+type client struct {
+	storage    *storage
+	bucketName string
+	prefix     string
+}
+
+// NewClient creates the client storing objects in the directory root/<bucket>/objects
+// and their metadata in the sidecar files root/<bucket>/meta/<object>.json.
+// Objects are written to a temporary file and renamed, so readers never see partial objects.
+// Unlike S3, an object id can't be the "directory" of another one (e.g. "a" and "a/b").
+func NewClient(root string, bucketName string) (s3.Client, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &client{storage: &storage{root: root}, bucketName: bucketName}
+	if err := c.setupBucket(); err != nil {
+		return nil, err
+	}
+
+	log.Info().Msgf("use local storage %s", root)
+	return c, nil
+}
+
+// Bucket returns the client of the bucket creating its directories. If they can't be created,
+// the operations of the returned client fail with the setup error.
+func (c *client) Bucket(name string) s3.Client {
+	b := &client{storage: c.storage, bucketName: name}
+	if err := b.setupBucket(); err != nil {
+		log.Error().Stack().Err(err).Msgf("failed to create bucket %s", name)
+		return &bucketErrorClient{parent: c, err: err}
+	}
+	return b
+}
+
+func (c *client) Prefix(prefix string) s3.Client {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return c
+	}
+	return &client{storage: c.storage, bucketName: c.bucketName, prefix: c.prefix + prefix + "/"}
+}
+
+func (c *client) CreateOne(_ context.Context, file *s3.FileData) *s3.CreateDto {
+	log.Debug().Msg("create one object")
+	if file == nil {
+		return &s3.CreateDto{Error: errors.New("file is nil")}
+	}
+
+	key := c.key(file.ID)
+	if err := s3.ValidateKey(key); err != nil {
+		return &s3.CreateDto{Error: err}
+	}
+
+	tmpData, etag, err := c.writeTemp(file.Reader, file.Size)
+	if err != nil {
+		return &s3.CreateDto{Error: err}
+	}
+	defer os.Remove(tmpData)
+
+	tmpMeta, err := c.writeMetaTemp(metadata{
+		ContentType:  s3.ContentTypeOrDefault(file.ContentType),
+		ETag:         etag,
+		UserMetadata: s3.NormalizeMetadata(file.UserMetadata),
+	})
+	if err != nil {
+		return &s3.CreateDto{Error: err}
+	}
+	defer os.Remove(tmpMeta)
+
+	c.storage.mu.Lock()
+	defer c.storage.mu.Unlock()
+
+	if err := c.rename(tmpData, c.objectPath(key)); err != nil {
+		return &s3.CreateDto{Error: err}
+	}
+	if err := c.rename(tmpMeta, c.metaPath(key)); err != nil {
+		return &s3.CreateDto{Error: err}
+	}
+
+	return &s3.CreateDto{ObjectID: file.ID}
+}
+
+func (c *client) CreateMany(ctx context.Context, files []*s3.FileData, opts ...s3.BatchOption) ([]*s3.CreateDto, error) {
+	log.Debug().Msg("create many object")
+	return s3.BatchCreate(ctx, files, c.CreateOne, opts...)
+}
+
+func (c *client) GetOne(_ context.Context, objectID string) *s3.GetDto {
+	log.Debug().Msg("get one object")
+
+	key := c.key(objectID)
+	if err := s3.ValidateKey(key); err != nil {
+		return &s3.GetDto{Error: s3.ErrObjectNotFound}
+	}
+
+	c.storage.mu.RLock()
+	defer c.storage.mu.RUnlock()
+
+	f, err := os.Open(c.objectPath(key))
+	if err != nil {
+		return &s3.GetDto{Error: mapError(err)}
+	}
+
+	info, err := c.statLocked(key, f)
+	if err != nil {
+		_ = f.Close()
+		return &s3.GetDto{Error: err}
+	}
+
+	return &s3.GetDto{
+		Data: &s3.FileData{
+			ID:           objectID,
+			Size:         info.Size,
+			ContentType:  info.ContentType,
+			Reader:       f,
+			UserMetadata: info.UserMetadata,
+		},
+	}
+}
+
+func (c *client) GetMany(ctx context.Context, objectIDs []string, opts ...s3.BatchOption) (map[string]*s3.GetDto, error) {
+	log.Debug().Msg("get many object")
+	return s3.BatchGet(ctx, objectIDs, c.GetOne, opts...)
+}
+
+// DeleteOne deletes the object. Like S3, deleting the missing object is not an error.
+func (c *client) DeleteOne(_ context.Context, objectID string) error {
+	log.Debug().Msg("delete one object")
+
+	key := c.key(objectID)
+	if err := s3.ValidateKey(key); err != nil {
+		return nil
+	}
+
+	c.storage.mu.Lock()
+	defer c.storage.mu.Unlock()
+
+	return c.removeLocked(key)
+}
+
+func (c *client) DeleteMany(ctx context.Context, objectIDs []string) map[string]error {
+	log.Debug().Msg("delete many object")
+
+	errMap := make(map[string]error)
+	for _, objectID := range objectIDs {
+		if err := c.DeleteOne(ctx, objectID); err != nil {
+			errMap[objectID] = err
+		}
+	}
+	return errMap
+}
+
+func (c *client) List(_ context.Context, prefix string, cursor string) (*s3.ListPage, error) {
+	log.Debug().Msgf("list objects with prefix %s", prefix)
+
+	c.storage.mu.RLock()
+	defer c.storage.mu.RUnlock()
+
+	after := ""
+	if cursor != "" {
+		after = c.key(cursor)
+	}
+
+	// One more key is listed to know if there is the next page
+	keys := make([]string, 0)
+	err := c.walkKeys(c.bucketPath(objectsDir), "", c.key(prefix), after, func(key string) error {
+		keys = append(keys, key)
+		if len(keys) > listPageSize {
+			return fs.SkipAll
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.SkipAll) {
+		return nil, err
+	}
+
+	page := &s3.ListPage{Objects: make([]s3.ObjectInfo, 0, min(len(keys), listPageSize))}
+	if len(keys) > listPageSize {
+		keys = keys[:listPageSize]
+		page.NextCursor = c.objectID(keys[len(keys)-1])
+	}
+	for _, key := range keys {
+		info, err := c.statLocked(key, nil)
+		if errors.Is(err, s3.ErrObjectNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		info.UserMetadata = nil
+		page.Objects = append(page.Objects, *info)
+	}
+
+	return page, nil
+}
+
+// walkKeys calls fn for the object keys of the directory with the prefix after the key in the order
+// of keys. The directories before the key are skipped, so the page after the cursor is listed
+// without walking the previous objects.
+func (c *client) walkKeys(dir string, dirKey string, keyPrefix string, after string, fn func(key string) error) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// The keys of the directory entries start with "name/", so they are sorted by it to keep
+	// the order of keys, e.g. "a-b" goes before "a/b"
+	keys := make([]string, len(entries))
+	for i, entry := range entries {
+		keys[i] = dirKey + entry.Name()
+		if entry.IsDir() {
+			keys[i] += "/"
+		}
+	}
+	sort.Sort(entriesByKey{entries: entries, keys: keys})
+
+	for i, entry := range entries {
+		key := keys[i]
+		if !entry.IsDir() {
+			if !strings.HasPrefix(key, keyPrefix) || (after != "" && key <= after) {
+				continue
+			}
+			if err := fn(key); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !strings.HasPrefix(key, keyPrefix) && !strings.HasPrefix(keyPrefix, key) {
+			continue
+		}
+		// All keys of the directory are before the key
+		if key < after && !strings.HasPrefix(after, key) {
+			continue
+		}
+		if err := c.walkKeys(filepath.Join(dir, entry.Name()), key, keyPrefix, after, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *client) Stat(_ context.Context, objectID string) (*s3.ObjectInfo, error) {
+	log.Debug().Msg("stat object")
+
+	key := c.key(objectID)
+	if err := s3.ValidateKey(key); err != nil {
+		return nil, s3.ErrObjectNotFound
+	}
+
+	c.storage.mu.RLock()
+	defer c.storage.mu.RUnlock()
+
+	return c.statLocked(key, nil)
+}
+
+func (c *client) Exists(ctx context.Context, objectID string) (bool, error) {
+	log.Debug().Msg("check object exists")
+
+	_, err := c.Stat(ctx, objectID)
+	if errors.Is(err, s3.ErrObjectNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (c *client) Copy(_ context.Context, srcObjectID string, dstObjectID string) error {
+	log.Debug().Msg("copy object")
+
+	srcKey, dstKey := c.key(srcObjectID), c.key(dstObjectID)
+	if err := s3.ValidateKey(srcKey); err != nil {
+		return s3.ErrObjectNotFound
+	}
+	if err := s3.ValidateKey(dstKey); err != nil {
+		return err
+	}
+
+	c.storage.mu.RLock()
+	f, err := os.Open(c.objectPath(srcKey))
+	if err != nil {
+		c.storage.mu.RUnlock()
+		return mapError(err)
+	}
+	defer f.Close()
+	meta, err := c.readMeta(srcKey)
+	c.storage.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	tmpData, _, err := c.writeTemp(f, -1)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpData)
+
+	tmpMeta, err := c.writeMetaTemp(meta)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpMeta)
+
+	c.storage.mu.Lock()
+	defer c.storage.mu.Unlock()
+
+	if err := c.rename(tmpData, c.objectPath(dstKey)); err != nil {
+		return err
+	}
+	return c.rename(tmpMeta, c.metaPath(dstKey))
+}
+
+func (c *client) Move(_ context.Context, srcObjectID string, dstObjectID string) error {
+	log.Debug().Msg("move object")
+
+	srcKey, dstKey := c.key(srcObjectID), c.key(dstObjectID)
+	if err := s3.ValidateKey(srcKey); err != nil {
+		return s3.ErrObjectNotFound
+	}
+	if err := s3.ValidateKey(dstKey); err != nil {
+		return err
+	}
+	if srcKey == dstKey {
+		return nil
+	}
+
+	c.storage.mu.Lock()
+	defer c.storage.mu.Unlock()
+
+	if _, err := os.Stat(c.objectPath(srcKey)); err != nil {
+		return mapError(err)
+	}
+
+	meta, err := c.readMeta(srcKey)
+	if err != nil {
+		return err
+	}
+	tmpMeta, err := c.writeMetaTemp(meta)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpMeta)
+
+	if err := c.rename(c.objectPath(srcKey), c.objectPath(dstKey)); err != nil {
+		return err
+	}
+	if err := c.rename(tmpMeta, c.metaPath(dstKey)); err != nil {
+		return err
+	}
+	return c.removeLocked(srcKey)
+}
+
+func (c *client) UpdateMetadata(_ context.Context, objectID string, userMetadata map[string]string) error {
+	log.Debug().Msg("update object metadata")
+
+	key := c.key(objectID)
+	if err := s3.ValidateKey(key); err != nil {
+		return s3.ErrObjectNotFound
+	}
+
+	c.storage.mu.Lock()
+	defer c.storage.mu.Unlock()
+
+	if _, err := os.Stat(c.objectPath(key)); err != nil {
+		return mapError(err)
+	}
+
+	meta, err := c.readMeta(key)
+	if err != nil {
+		return err
+	}
+	meta.UserMetadata = s3.NormalizeMetadata(userMetadata)
+
+	tmpMeta, err := c.writeMetaTemp(meta)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpMeta)
+
+	if err := c.rename(tmpMeta, c.metaPath(key)); err != nil {
+		return err
+	}
+
+	// Like S3, the metadata replacement updates the modification time
+	now := time.Now()
+	return os.Chtimes(c.objectPath(key), now, now)
+}
+
+func (c *client) PresignGet(context.Context, string, time.Duration) (*s3.PresignedRequest, error) {
+	return nil, s3.ErrNotSupported
+}
+
+func (c *client) PresignPut(context.Context, string, time.Duration, string, int64) (*s3.PresignedRequest, error) {
+	return nil, s3.ErrNotSupported
+}
+
+func (c *client) PresignPost(context.Context, string, time.Duration, s3.PostPolicy) (*s3.PresignedPost, error) {
+	return nil, s3.ErrNotSupported
+}
+
+func (c *client) setupBucket() error {
+	if c.bucketName == "" || s3.ValidateKey(c.bucketName) != nil || strings.Contains(c.bucketName, "/") {
+		return errors.Errorf("invalid bucket name %q", c.bucketName)
+	}
+	for _, dir := range []string{objectsDir, metaDir, tmpDir, uploadsDir} {
+		if err := os.MkdirAll(c.bucketPath(dir), 0o755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// statLocked returns the info of the object. If f is not nil, it's used instead of opening the object.
+func (c *client) statLocked(key string, f *os.File) (*s3.ObjectInfo, error) {
+	var (
+		fi  os.FileInfo
+		err error
+	)
+	if f != nil {
+		fi, err = f.Stat()
+	} else {
+		fi, err = os.Stat(c.objectPath(key))
+	}
+	if err != nil {
+		return nil, mapError(err)
+	}
+	if fi.IsDir() {
+		return nil, s3.ErrObjectNotFound
+	}
+
+	meta, err := c.readMeta(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3.ObjectInfo{
+		ID:           c.objectID(key),
+		Size:         fi.Size(),
+		ContentType:  meta.ContentType,
+		ETag:         meta.ETag,
+		LastModified: fi.ModTime().UTC(),
+		UserMetadata: meta.UserMetadata,
+	}, nil
+}
+
+// readMeta reads the metadata sidecar. The object without the sidecar has the default metadata.
+func (c *client) readMeta(key string) (metadata, error) {
+	data, err := os.ReadFile(c.metaPath(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return metadata{ContentType: s3.ContentTypeOrDefault("")}, nil
+	}
+	if err != nil {
+		return metadata{}, err
+	}
+
+	var meta metadata
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return metadata{}, err
+	}
+	return meta, nil
+}
+
+// writeTemp writes size bytes of the reader (or the whole reader if size is negative)
+// to the synced temporary file and returns its path and MD5 hex.
+func (c *client) writeTemp(r io.Reader, size int64) (string, string, error) {
+	f, err := os.CreateTemp(c.bucketPath(tmpDir), "object-*")
+	if err != nil {
+		return "", "", err
+	}
+
+	hash := md5.New()
+	w := io.MultiWriter(f, hash)
+	if size >= 0 {
+		_, err = io.CopyN(w, r, size)
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+	} else {
+		_, err = io.Copy(w, r)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return "", "", err
+	}
+
+	return f.Name(), hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func (c *client) writeMetaTemp(meta metadata) (string, error) {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return "", err
+	}
+	path, _, err := c.writeTemp(bytes.NewReader(data), int64(len(data)))
+	return path, err
+}
+
+func (c *client) rename(from string, to string) error {
+	if err := os.MkdirAll(filepath.Dir(to), 0o755); err != nil {
+		return err
+	}
+	return os.Rename(from, to)
+}
+
+func (c *client) removeLocked(key string) error {
+	for _, path := range []string{c.objectPath(key), c.metaPath(key)} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	c.removeEmptyDirs(filepath.Dir(c.objectPath(key)), c.bucketPath(objectsDir))
+	c.removeEmptyDirs(filepath.Dir(c.metaPath(key)), c.bucketPath(metaDir))
+	return nil
+}
+
+// removeEmptyDirs removes the empty directories from dir up to root exclusively.
+func (c *client) removeEmptyDirs(dir string, root string) {
+	for dir != root && strings.HasPrefix(dir, root) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+func (c *client) bucketPath(dir string) string {
+	return filepath.Join(c.storage.root, c.bucketName, dir)
+}
+
+func (c *client) objectPath(key string) string {
+	return filepath.Join(c.bucketPath(objectsDir), filepath.FromSlash(key))
+}
+
+func (c *client) metaPath(key string) string {
+	return filepath.Join(c.bucketPath(metaDir), filepath.FromSlash(key)+metaExt)
+}
+
+func (c *client) key(objectID string) string {
+	return c.prefix + objectID
+}
+
+func (c *client) objectID(key string) string {
+	return strings.TrimPrefix(key, c.prefix)
+}
+
+type entriesByKey struct {
+	entries []fs.DirEntry
+	keys    []string
+}
+
+func (e entriesByKey) Len() int {
+	return len(e.keys)
+}
+
+func (e entriesByKey) Less(i, j int) bool {
+	return e.keys[i] < e.keys[j]
+}
+
+func (e entriesByKey) Swap(i, j int) {
+	e.entries[i], e.entries[j] = e.entries[j], e.entries[i]
+	e.keys[i], e.keys[j] = e.keys[j], e.keys[i]
+}
+
+func mapError(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return s3.ErrObjectNotFound
+	}
+	return err
+}
@@ -0,0 +1,16 @@
+package local
+
+import (
+	"github.com/mandarine-io/baselib/pkg/storage/s3"
+	"github.com/mandarine-io/baselib/pkg/storage/s3/s3test"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestClient_Conformance(t *testing.T) {
+	s3test.RunConformance(t, func(t *testing.T) s3.Client {
+		c, err := NewClient(t.TempDir(), "bucket")
+		require.NoError(t, err)
+		return c
+	})
+}
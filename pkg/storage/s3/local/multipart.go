@@ -0,0 +1,315 @@
+package local
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"github.com/goccy/go-json"
+	"github.com/google/uuid"
+	"github.com/mandarine-io/baselib/pkg/storage/s3"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	uploadInfoFile = "upload.json"
+	partExt        = ".part"
+	etagExt        = ".etag"
+)
+
+type uploadInfo struct {
+	Key string `json:"key"`
+	metadata
+}
+
+type sectionReadCloser struct {
+	*io.SectionReader
+	io.Closer
+}
+
+func (c *client) GetRange(_ context.Context, objectID string, offset int64, length int64) (*s3.FileData, error) {
+	log.Debug().Msgf("get object range %d+%d", offset, length)
+
+	key := c.key(objectID)
+	if err := s3.ValidateKey(key); err != nil {
+		return nil, s3.ErrObjectNotFound
+	}
+
+	c.storage.mu.RLock()
+	defer c.storage.mu.RUnlock()
+
+	f, err := os.Open(c.objectPath(key))
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	info, err := c.statLocked(key, f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	start, end, err := s3.RangeBounds(info.Size, offset, length)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return &s3.FileData{
+		ID:           objectID,
+		Size:         end - start,
+		ContentType:  info.ContentType,
+		Reader:       sectionReadCloser{SectionReader: io.NewSectionReader(f, start, end-start), Closer: f},
+		UserMetadata: info.UserMetadata,
+	}, nil
+}
+
+func (c *client) InitiateUpload(_ context.Context, objectID string, contentType string, userMetadata map[string]string) (string, error) {
+	log.Debug().Msg("initiate multipart upload")
+
+	key := c.key(objectID)
+	if err := s3.ValidateKey(key); err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(uploadInfo{
+		Key: key,
+		metadata: metadata{
+			ContentType:  contentType,
+			UserMetadata: s3.NormalizeMetadata(userMetadata),
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	uploadID := uuid.NewString()
+	dir := c.uploadPath(uploadID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, uploadInfoFile), data, 0o644); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", err
+	}
+
+	return uploadID, nil
+}
+
+func (c *client) UploadPart(
+	_ context.Context, objectID string, uploadID string, number int, r io.Reader, size int64,
+) (*s3.Part, error) {
+	log.Debug().Msgf("upload part %d", number)
+
+	if number < 1 || number > s3.MaxPartNumber {
+		return nil, s3.ErrInvalidPart
+	}
+	if _, err := c.readUpload(objectID, uploadID); err != nil {
+		return nil, err
+	}
+
+	tmpPart, etag, err := c.writeTemp(r, size)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmpPart)
+
+	fi, err := os.Stat(tmpPart)
+	if err != nil {
+		return nil, err
+	}
+
+	c.storage.mu.Lock()
+	defer c.storage.mu.Unlock()
+
+	partPath := c.partPath(uploadID, number)
+	if err := os.Rename(tmpPart, partPath+partExt); err != nil {
+		return nil, mapUploadError(err)
+	}
+	if err := os.WriteFile(partPath+etagExt, []byte(etag), 0o644); err != nil {
+		return nil, mapUploadError(err)
+	}
+
+	return &s3.Part{Number: number, ETag: etag, Size: fi.Size()}, nil
+}
+
+func (c *client) ListParts(_ context.Context, objectID string, uploadID string) ([]s3.Part, error) {
+	log.Debug().Msg("list uploaded parts")
+
+	if _, err := c.readUpload(objectID, uploadID); err != nil {
+		return nil, err
+	}
+
+	c.storage.mu.RLock()
+	defer c.storage.mu.RUnlock()
+
+	return c.listPartsLocked(uploadID)
+}
+
+func (c *client) CompleteUpload(_ context.Context, objectID string, uploadID string, parts []s3.Part) error {
+	log.Debug().Msg("complete multipart upload")
+
+	info, err := c.readUpload(objectID, uploadID)
+	if err != nil {
+		return err
+	}
+	if len(parts) == 0 {
+		return s3.ErrInvalidPart
+	}
+
+	parts = append([]s3.Part(nil), parts...)
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].Number < parts[j].Number
+	})
+
+	c.storage.mu.RLock()
+	uploaded, err := c.listPartsLocked(uploadID)
+	c.storage.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	uploadedByNumber := make(map[int]s3.Part, len(uploaded))
+	for _, p := range uploaded {
+		uploadedByNumber[p.Number] = p
+	}
+
+	readers := make([]io.Reader, 0, len(parts))
+	var sums []byte
+	for i, p := range parts {
+		u, ok := uploadedByNumber[p.Number]
+		if !ok || u.ETag != strings.Trim(p.ETag, `"`) || (i > 0 && parts[i-1].Number == p.Number) {
+			return s3.ErrInvalidPart
+		}
+		if i < len(parts)-1 && u.Size < s3.MinPartSize {
+			return s3.ErrPartTooSmall
+		}
+
+		f, err := os.Open(c.partPath(uploadID, p.Number) + partExt)
+		if err != nil {
+			return mapUploadError(err)
+		}
+		defer f.Close()
+		readers = append(readers, f)
+
+		sum, _ := hex.DecodeString(u.ETag)
+		sums = append(sums, sum...)
+	}
+
+	tmpData, _, err := c.writeTemp(io.MultiReader(readers...), -1)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpData)
+
+	sum := md5.Sum(sums)
+	meta := info.metadata
+	meta.ContentType = s3.ContentTypeOrDefault(meta.ContentType)
+	meta.ETag = fmt.Sprintf("%s-%d", hex.EncodeToString(sum[:]), len(parts))
+	tmpMeta, err := c.writeMetaTemp(meta)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpMeta)
+
+	c.storage.mu.Lock()
+	defer c.storage.mu.Unlock()
+
+	if err := c.rename(tmpData, c.objectPath(info.Key)); err != nil {
+		return err
+	}
+	if err := c.rename(tmpMeta, c.metaPath(info.Key)); err != nil {
+		return err
+	}
+	return os.RemoveAll(c.uploadPath(uploadID))
+}
+
+func (c *client) AbortUpload(_ context.Context, objectID string, uploadID string) error {
+	log.Debug().Msg("abort multipart upload")
+
+	if _, err := c.readUpload(objectID, uploadID); err != nil {
+		return err
+	}
+
+	c.storage.mu.Lock()
+	defer c.storage.mu.Unlock()
+
+	return os.RemoveAll(c.uploadPath(uploadID))
+}
+
+func (c *client) readUpload(objectID string, uploadID string) (*uploadInfo, error) {
+	if err := uuid.Validate(uploadID); err != nil {
+		return nil, s3.ErrUploadNotFound
+	}
+
+	data, err := os.ReadFile(filepath.Join(c.uploadPath(uploadID), uploadInfoFile))
+	if err != nil {
+		return nil, mapUploadError(err)
+	}
+
+	var info uploadInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, err
+	}
+	if info.Key != c.key(objectID) {
+		return nil, s3.ErrUploadNotFound
+	}
+	return &info, nil
+}
+
+func (c *client) listPartsLocked(uploadID string) ([]s3.Part, error) {
+	entries, err := os.ReadDir(c.uploadPath(uploadID))
+	if err != nil {
+		return nil, mapUploadError(err)
+	}
+
+	parts := make([]s3.Part, 0, len(entries))
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), partExt)
+		if !ok {
+			continue
+		}
+		number, err := strconv.Atoi(name)
+		if err != nil {
+			continue
+		}
+
+		fi, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		etag, err := os.ReadFile(c.partPath(uploadID, number) + etagExt)
+		if err != nil {
+			return nil, err
+		}
+
+		parts = append(parts, s3.Part{Number: number, ETag: string(etag), Size: fi.Size()})
+	}
+
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].Number < parts[j].Number
+	})
+	return parts, nil
+}
+
+func (c *client) uploadPath(uploadID string) string {
+	return filepath.Join(c.bucketPath(uploadsDir), uploadID)
+}
+
+func (c *client) partPath(uploadID string, number int) string {
+	return filepath.Join(c.uploadPath(uploadID), fmt.Sprintf("%05d", number))
+}
+
+func mapUploadError(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return s3.ErrUploadNotFound
+	}
+	return err
+}
@@ -0,0 +1,310 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"github.com/mandarine-io/baselib/pkg/storage/s3"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const listPageSize = 1000
+
+type object struct {
+	data         []byte
+	contentType  string
+	etag         string
+	lastModified time.Time
+	userMetadata map[string]string
+}
+
+// storage is shared by the clients of all buckets and prefixes.
+type storage struct {
+	mu      sync.RWMutex
+	buckets map[string]map[string]*object
+	uploads map[string]*upload
+}
+
+type client struct {
+	storage    *storage
+	bucketName string
+	prefix     string
+}
+
+// NewClient creates the client storing objects in memory. It's intended for tests.
+func NewClient(bucketName string) s3.Client {
+	return &client{
+		storage: &storage{
+			buckets: make(map[string]map[string]*object),
+			uploads: make(map[string]*upload),
+		},
+		bucketName: bucketName,
+	}
+}
+
+func (c *client) Bucket(name string) s3.Client {
+	return &client{storage: c.storage, bucketName: name}
+}
+
+func (c *client) Prefix(prefix string) s3.Client {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return c
+	}
+	return &client{storage: c.storage, bucketName: c.bucketName, prefix: c.prefix + prefix + "/"}
+}
+
+func (c *client) CreateOne(_ context.Context, file *s3.FileData) *s3.CreateDto {
+	log.Debug().Msg("create one object")
+	if file == nil {
+		return &s3.CreateDto{Error: errors.New("file is nil")}
+	}
+
+	key := c.key(file.ID)
+	if err := s3.ValidateKey(key); err != nil {
+		return &s3.CreateDto{Error: err}
+	}
+
+	data, err := readData(file)
+	if err != nil {
+		return &s3.CreateDto{Error: err}
+	}
+
+	sum := md5.Sum(data)
+	c.put(key, &object{
+		data:         data,
+		contentType:  s3.ContentTypeOrDefault(file.ContentType),
+		etag:         hex.EncodeToString(sum[:]),
+		lastModified: time.Now().UTC(),
+		userMetadata: s3.NormalizeMetadata(file.UserMetadata),
+	})
+
+	return &s3.CreateDto{ObjectID: file.ID}
+}
+
+func (c *client) CreateMany(ctx context.Context, files []*s3.FileData, opts ...s3.BatchOption) ([]*s3.CreateDto, error) {
+	log.Debug().Msg("create many object")
+	return s3.BatchCreate(ctx, files, c.CreateOne, opts...)
+}
+
+func (c *client) GetOne(_ context.Context, objectID string) *s3.GetDto {
+	log.Debug().Msg("get one object")
+
+	obj, ok := c.get(c.key(objectID))
+	if !ok {
+		return &s3.GetDto{Error: s3.ErrObjectNotFound}
+	}
+
+	return &s3.GetDto{
+		Data: &s3.FileData{
+			ID:           objectID,
+			Size:         int64(len(obj.data)),
+			ContentType:  obj.contentType,
+			Reader:       io.NopCloser(bytes.NewReader(obj.data)),
+			UserMetadata: s3.CopyMetadata(obj.userMetadata),
+		},
+	}
+}
+
+func (c *client) GetMany(ctx context.Context, objectIDs []string, opts ...s3.BatchOption) (map[string]*s3.GetDto, error) {
+	log.Debug().Msg("get many object")
+	return s3.BatchGet(ctx, objectIDs, c.GetOne, opts...)
+}
+
+func (c *client) DeleteOne(_ context.Context, objectID string) error {
+	log.Debug().Msg("delete one object")
+
+	c.storage.mu.Lock()
+	defer c.storage.mu.Unlock()
+
+	delete(c.storage.buckets[c.bucketName], c.key(objectID))
+	return nil
+}
+
+// DeleteMany deletes the objects. Like S3, missing objects are not errors, so the result is empty.
+func (c *client) DeleteMany(ctx context.Context, objectIDs []string) map[string]error {
+	log.Debug().Msg("delete many object")
+
+	errMap := make(map[string]error)
+	for _, objectID := range objectIDs {
+		if err := c.DeleteOne(ctx, objectID); err != nil {
+			errMap[objectID] = err
+		}
+	}
+	return errMap
+}
+
+func (c *client) List(_ context.Context, prefix string, cursor string) (*s3.ListPage, error) {
+	log.Debug().Msgf("list objects with prefix %s", prefix)
+
+	c.storage.mu.RLock()
+	defer c.storage.mu.RUnlock()
+
+	keyPrefix := c.key(prefix)
+	keys := make([]string, 0)
+	for key := range c.storage.buckets[c.bucketName] {
+		if strings.HasPrefix(key, keyPrefix) && (cursor == "" || key > c.key(cursor)) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	page := &s3.ListPage{Objects: make([]s3.ObjectInfo, 0, min(len(keys), listPageSize))}
+	if len(keys) > listPageSize {
+		keys = keys[:listPageSize]
+		page.NextCursor = c.objectID(keys[len(keys)-1])
+	}
+	for _, key := range keys {
+		info := c.objectInfo(key, c.storage.buckets[c.bucketName][key])
+		info.UserMetadata = nil
+		page.Objects = append(page.Objects, info)
+	}
+
+	return page, nil
+}
+
+func (c *client) Stat(_ context.Context, objectID string) (*s3.ObjectInfo, error) {
+	log.Debug().Msg("stat object")
+
+	obj, ok := c.get(c.key(objectID))
+	if !ok {
+		return nil, s3.ErrObjectNotFound
+	}
+
+	info := c.objectInfo(c.key(objectID), obj)
+	return &info, nil
+}
+
+func (c *client) Exists(_ context.Context, objectID string) (bool, error) {
+	log.Debug().Msg("check object exists")
+
+	_, ok := c.get(c.key(objectID))
+	return ok, nil
+}
+
+func (c *client) Copy(_ context.Context, srcObjectID string, dstObjectID string) error {
+	log.Debug().Msg("copy object")
+
+	if err := s3.ValidateKey(c.key(dstObjectID)); err != nil {
+		return err
+	}
+	obj, ok := c.get(c.key(srcObjectID))
+	if !ok {
+		return s3.ErrObjectNotFound
+	}
+
+	cp := *obj
+	cp.lastModified = time.Now().UTC()
+	cp.userMetadata = s3.CopyMetadata(obj.userMetadata)
+	c.put(c.key(dstObjectID), &cp)
+	return nil
+}
+
+func (c *client) Move(ctx context.Context, srcObjectID string, dstObjectID string) error {
+	log.Debug().Msg("move object")
+
+	if err := s3.ValidateKey(c.key(srcObjectID)); err != nil {
+		return s3.ErrObjectNotFound
+	}
+	if err := s3.ValidateKey(c.key(dstObjectID)); err != nil {
+		return err
+	}
+	if srcObjectID == dstObjectID {
+		return nil
+	}
+	if err := c.Copy(ctx, srcObjectID, dstObjectID); err != nil {
+		return err
+	}
+	return c.DeleteOne(ctx, srcObjectID)
+}
+
+func (c *client) UpdateMetadata(_ context.Context, objectID string, metadata map[string]string) error {
+	log.Debug().Msg("update object metadata")
+
+	obj, ok := c.get(c.key(objectID))
+	if !ok {
+		return s3.ErrObjectNotFound
+	}
+
+	cp := *obj
+	cp.lastModified = time.Now().UTC()
+	cp.userMetadata = s3.NormalizeMetadata(metadata)
+	c.put(c.key(objectID), &cp)
+	return nil
+}
+
+func (c *client) PresignGet(context.Context, string, time.Duration) (*s3.PresignedRequest, error) {
+	return nil, s3.ErrNotSupported
+}
+
+func (c *client) PresignPut(context.Context, string, time.Duration, string, int64) (*s3.PresignedRequest, error) {
+	return nil, s3.ErrNotSupported
+}
+
+func (c *client) PresignPost(context.Context, string, time.Duration, s3.PostPolicy) (*s3.PresignedPost, error) {
+	return nil, s3.ErrNotSupported
+}
+
+// get returns the object by key, the invalid keys are treated as missing like in the local storage.
+func (c *client) get(key string) (*object, bool) {
+	if s3.ValidateKey(key) != nil {
+		return nil, false
+	}
+
+	c.storage.mu.RLock()
+	defer c.storage.mu.RUnlock()
+
+	obj, ok := c.storage.buckets[c.bucketName][key]
+	return obj, ok
+}
+
+func (c *client) put(key string, obj *object) {
+	c.storage.mu.Lock()
+	defer c.storage.mu.Unlock()
+
+	bucket, ok := c.storage.buckets[c.bucketName]
+	if !ok {
+		bucket = make(map[string]*object)
+		c.storage.buckets[c.bucketName] = bucket
+	}
+	bucket[key] = obj
+}
+
+func (c *client) objectInfo(key string, obj *object) s3.ObjectInfo {
+	return s3.ObjectInfo{
+		ID:           c.objectID(key),
+		Size:         int64(len(obj.data)),
+		ContentType:  obj.contentType,
+		ETag:         obj.etag,
+		LastModified: obj.lastModified,
+		UserMetadata: s3.CopyMetadata(obj.userMetadata),
+	}
+}
+
+func (c *client) key(objectID string) string {
+	return c.prefix + objectID
+}
+
+func (c *client) objectID(key string) string {
+	return strings.TrimPrefix(key, c.prefix)
+}
+
+// readData reads file.Size bytes like S3 upload, or the whole reader if the size is negative.
+func readData(file *s3.FileData) ([]byte, error) {
+	if file.Size < 0 {
+		return io.ReadAll(file.Reader)
+	}
+
+	data := make([]byte, file.Size)
+	if _, err := io.ReadFull(file.Reader, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
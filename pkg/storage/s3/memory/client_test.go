@@ -0,0 +1,13 @@
+package memory
+
+import (
+	"github.com/mandarine-io/baselib/pkg/storage/s3"
+	"github.com/mandarine-io/baselib/pkg/storage/s3/s3test"
+	"testing"
+)
+
+func TestClient_Conformance(t *testing.T) {
+	s3test.RunConformance(t, func(*testing.T) s3.Client {
+		return NewClient("bucket")
+	})
+}
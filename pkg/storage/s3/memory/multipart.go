@@ -0,0 +1,209 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/mandarine-io/baselib/pkg/storage/s3"
+	"github.com/rs/zerolog/log"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+type upload struct {
+	bucketName   string
+	key          string
+	contentType  string
+	userMetadata map[string]string
+	parts        map[int]*part
+}
+
+type part struct {
+	data []byte
+	etag string
+}
+
+func (c *client) GetRange(_ context.Context, objectID string, offset int64, length int64) (*s3.FileData, error) {
+	log.Debug().Msgf("get object range %d+%d", offset, length)
+
+	obj, ok := c.get(c.key(objectID))
+	if !ok {
+		return nil, s3.ErrObjectNotFound
+	}
+
+	start, end, err := s3.RangeBounds(int64(len(obj.data)), offset, length)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3.FileData{
+		ID:           objectID,
+		Size:         end - start,
+		ContentType:  obj.contentType,
+		Reader:       io.NopCloser(bytes.NewReader(obj.data[start:end])),
+		UserMetadata: s3.CopyMetadata(obj.userMetadata),
+	}, nil
+}
+
+func (c *client) InitiateUpload(_ context.Context, objectID string, contentType string, metadata map[string]string) (string, error) {
+	log.Debug().Msg("initiate multipart upload")
+
+	if err := s3.ValidateKey(c.key(objectID)); err != nil {
+		return "", err
+	}
+
+	uploadID := uuid.NewString()
+
+	c.storage.mu.Lock()
+	defer c.storage.mu.Unlock()
+
+	c.storage.uploads[uploadID] = &upload{
+		bucketName:   c.bucketName,
+		key:          c.key(objectID),
+		contentType:  contentType,
+		userMetadata: s3.NormalizeMetadata(metadata),
+		parts:        make(map[int]*part),
+	}
+	return uploadID, nil
+}
+
+func (c *client) UploadPart(
+	_ context.Context, objectID string, uploadID string, number int, r io.Reader, size int64,
+) (*s3.Part, error) {
+	log.Debug().Msgf("upload part %d", number)
+
+	if number < 1 || number > s3.MaxPartNumber {
+		return nil, s3.ErrInvalidPart
+	}
+	if _, err := c.upload(objectID, uploadID); err != nil {
+		return nil, err
+	}
+
+	data, err := readData(&s3.FileData{Reader: io.NopCloser(r), Size: size})
+	if err != nil {
+		return nil, err
+	}
+	sum := md5.Sum(data)
+	p := &part{data: data, etag: hex.EncodeToString(sum[:])}
+
+	c.storage.mu.Lock()
+	defer c.storage.mu.Unlock()
+
+	u, ok := c.storage.uploads[uploadID]
+	if !ok {
+		return nil, s3.ErrUploadNotFound
+	}
+	u.parts[number] = p
+
+	return &s3.Part{Number: number, ETag: p.etag, Size: int64(len(data))}, nil
+}
+
+func (c *client) ListParts(_ context.Context, objectID string, uploadID string) ([]s3.Part, error) {
+	log.Debug().Msg("list uploaded parts")
+
+	c.storage.mu.RLock()
+	defer c.storage.mu.RUnlock()
+
+	u, err := c.uploadLocked(objectID, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := make([]s3.Part, 0, len(u.parts))
+	for number, p := range u.parts {
+		parts = append(parts, s3.Part{Number: number, ETag: p.etag, Size: int64(len(p.data))})
+	}
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].Number < parts[j].Number
+	})
+	return parts, nil
+}
+
+func (c *client) CompleteUpload(_ context.Context, objectID string, uploadID string, parts []s3.Part) error {
+	log.Debug().Msg("complete multipart upload")
+
+	c.storage.mu.Lock()
+	defer c.storage.mu.Unlock()
+
+	u, err := c.uploadLocked(objectID, uploadID)
+	if err != nil {
+		return err
+	}
+
+	if len(parts) == 0 {
+		return s3.ErrInvalidPart
+	}
+
+	parts = append([]s3.Part(nil), parts...)
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].Number < parts[j].Number
+	})
+
+	var (
+		data []byte
+		sums []byte
+	)
+	for i, p := range parts {
+		uploaded, ok := u.parts[p.Number]
+		if !ok || uploaded.etag != strings.Trim(p.ETag, `"`) || (i > 0 && parts[i-1].Number == p.Number) {
+			return s3.ErrInvalidPart
+		}
+		if i < len(parts)-1 && len(uploaded.data) < s3.MinPartSize {
+			return s3.ErrPartTooSmall
+		}
+
+		data = append(data, uploaded.data...)
+		sum, _ := hex.DecodeString(uploaded.etag)
+		sums = append(sums, sum...)
+	}
+	sum := md5.Sum(sums)
+	bucket, ok := c.storage.buckets[u.bucketName]
+	if !ok {
+		bucket = make(map[string]*object)
+		c.storage.buckets[u.bucketName] = bucket
+	}
+	bucket[u.key] = &object{
+		data:         data,
+		contentType:  s3.ContentTypeOrDefault(u.contentType),
+		etag:         fmt.Sprintf("%s-%d", hex.EncodeToString(sum[:]), len(parts)),
+		lastModified: time.Now().UTC(),
+		userMetadata: u.userMetadata,
+	}
+	delete(c.storage.uploads, uploadID)
+
+	return nil
+}
+
+func (c *client) AbortUpload(_ context.Context, objectID string, uploadID string) error {
+	log.Debug().Msg("abort multipart upload")
+
+	c.storage.mu.Lock()
+	defer c.storage.mu.Unlock()
+
+	if _, err := c.uploadLocked(objectID, uploadID); err != nil {
+		return err
+	}
+	delete(c.storage.uploads, uploadID)
+	return nil
+}
+
+func (c *client) upload(objectID string, uploadID string) (*upload, error) {
+	c.storage.mu.RLock()
+	defer c.storage.mu.RUnlock()
+	return c.uploadLocked(objectID, uploadID)
+}
+
+func (c *client) uploadLocked(objectID string, uploadID string) (*upload, error) {
+	u, ok := c.storage.uploads[uploadID]
+	if !ok || u.bucketName != c.bucketName || u.key != c.key(objectID) {
+		return nil, s3.ErrUploadNotFound
+	}
+	return u, nil
+}
+
+// rangeBounds returns the bounds [start, end) of the range like S3 does.
@@ -0,0 +1,114 @@
+package minio
+
+import (
+	"context"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
+	"github.com/rs/zerolog/log"
+)
+
+type BucketConfig struct {
+	Name string
+	// Versioning enables object versioning of the bucket.
+	Versioning bool
+	// Lifecycle is the list of lifecycle rules of the bucket. If empty, the lifecycle is not changed.
+	Lifecycle []LifecycleRule
+	// Retention is the default retention of objects. Object locking can be enabled only
+	// on bucket creation, so it's ignored for existing buckets without locking.
+	Retention *Retention
+	// Encryption is the default server-side encryption of objects, EncryptionS3 or EncryptionKMS.
+	// If nil, the encryption is not changed.
+	Encryption *Encryption
+}
+
+type LifecycleRule struct {
+	ID string
+	// Prefix limits the rule to the objects with the key prefix.
+	Prefix string
+	// ExpirationDays removes the objects after the number of days since creation.
+	ExpirationDays int
+	// NoncurrentExpirationDays removes noncurrent versions after the number of days.
+	NoncurrentExpirationDays int
+	// AbortIncompleteUploadDays aborts incomplete multipart uploads after the number of days.
+	AbortIncompleteUploadDays int
+}
+
+type Retention struct {
+	// Mode is "GOVERNANCE" or "COMPLIANCE".
+	Mode string
+	Days uint
+}
+
+func setupBucket(ctx context.Context, minioClient *minio.Client, cfg BucketConfig) error {
+	log.Info().Msgf("check bucket \"%s\"", cfg.Name)
+	exists, err := minioClient.BucketExists(ctx, cfg.Name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		log.Info().Msgf("create bucket \"%s\"", cfg.Name)
+		err = minioClient.MakeBucket(ctx, cfg.Name, minio.MakeBucketOptions{ObjectLocking: cfg.Retention != nil})
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.Versioning {
+		log.Info().Msgf("enable versioning of bucket \"%s\"", cfg.Name)
+		if err := minioClient.EnableVersioning(ctx, cfg.Name); err != nil {
+			return err
+		}
+	}
+
+	if len(cfg.Lifecycle) > 0 {
+		log.Info().Msgf("set lifecycle of bucket \"%s\"", cfg.Name)
+		if err := minioClient.SetBucketLifecycle(ctx, cfg.Name, lifecycleConfig(cfg.Lifecycle)); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Retention != nil {
+		log.Info().Msgf("set retention of bucket \"%s\"", cfg.Name)
+		mode := minio.RetentionMode(cfg.Retention.Mode)
+		days := cfg.Retention.Days
+		unit := minio.Days
+		if err := minioClient.SetObjectLockConfig(ctx, cfg.Name, &mode, &days, &unit); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Encryption != nil {
+		log.Info().Msgf("set encryption of bucket \"%s\"", cfg.Name)
+		config, err := cfg.Encryption.bucketConfiguration()
+		if err != nil {
+			return err
+		}
+		if err := minioClient.SetBucketEncryption(ctx, cfg.Name, config); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func lifecycleConfig(rules []LifecycleRule) *lifecycle.Configuration {
+	config := lifecycle.NewConfiguration()
+	for _, r := range rules {
+		rule := lifecycle.Rule{
+			ID:         r.ID,
+			Status:     "Enabled",
+			RuleFilter: lifecycle.Filter{Prefix: r.Prefix},
+		}
+		if r.ExpirationDays > 0 {
+			rule.Expiration.Days = lifecycle.ExpirationDays(r.ExpirationDays)
+		}
+		if r.NoncurrentExpirationDays > 0 {
+			rule.NoncurrentVersionExpiration.NoncurrentDays = lifecycle.ExpirationDays(r.NoncurrentExpirationDays)
+		}
+		if r.AbortIncompleteUploadDays > 0 {
+			rule.AbortIncompleteMultipartUpload.DaysAfterInitiation = lifecycle.ExpirationDays(r.AbortIncompleteUploadDays)
+		}
+		config.Rules = append(config.Rules, rule)
+	}
+	return config
+}
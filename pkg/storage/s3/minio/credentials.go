@@ -0,0 +1,108 @@
+package minio
+
+import (
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/pkg/errors"
+	"net/http"
+	"os"
+)
+
+const (
+	// CredentialsStatic provides Config.AccessKey, Config.SecretKey and Config.SessionToken.
+	CredentialsStatic = "static"
+	// CredentialsEnv provides AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN
+	// or MINIO_ROOT_USER, MINIO_ROOT_PASSWORD environment variables.
+	CredentialsEnv = "env"
+	// CredentialsFile provides the profile of the AWS shared credentials file.
+	CredentialsFile = "file"
+	// CredentialsIAM provides the credentials of the EC2 instance or ECS task role,
+	// or the EKS service account from AWS_WEB_IDENTITY_TOKEN_FILE and AWS_ROLE_ARN.
+	CredentialsIAM = "iam"
+	// CredentialsWebIdentity exchanges the web identity token of Config.WebIdentity with STS.
+	CredentialsWebIdentity = "web-identity"
+)
+
+var ErrInvalidCredentials = errors.New("invalid credentials config")
+
+// WebIdentityConfig is the config of CredentialsWebIdentity.
+type WebIdentityConfig struct {
+	// TokenFile is the file of the OIDC token, it's read on every credentials refresh.
+	TokenFile string
+	// RoleARN is the role assumed with the token. MinIO doesn't require it.
+	RoleARN string
+	// STSEndpoint is the URL of STS, e.g. "https://sts.amazonaws.com" or the MinIO server URL.
+	STSEndpoint string
+}
+
+// newCredentials returns the chain of the credential providers of the config.
+// IAM and STS endpoints are requested with the default transport, since the TLS config
+// of the storage (server name, client certificate) doesn't apply to them.
+func newCredentials(cfg *Config) (*credentials.Credentials, error) {
+	sources := cfg.Credentials
+	if len(sources) == 0 {
+		sources = []string{CredentialsStatic}
+	}
+
+	providers := make([]credentials.Provider, 0, len(sources))
+	for _, source := range sources {
+		switch source {
+		case CredentialsStatic:
+			providers = append(providers, &credentials.Static{
+				Value: credentials.Value{
+					AccessKeyID:     cfg.AccessKey,
+					SecretAccessKey: cfg.SecretKey,
+					SessionToken:    cfg.SessionToken,
+					SignerType:      credentials.SignatureV4,
+				},
+			})
+		case CredentialsEnv:
+			providers = append(providers, &credentials.EnvAWS{}, &credentials.EnvMinio{})
+		case CredentialsFile:
+			providers = append(providers, &credentials.FileAWSCredentials{
+				Filename: cfg.CredentialsFile,
+				Profile:  cfg.CredentialsProfile,
+			})
+		case CredentialsIAM:
+			providers = append(providers, &credentials.IAM{
+				Client: credentialsClient(),
+				Region: cfg.Region,
+			})
+		case CredentialsWebIdentity:
+			provider, err := webIdentityProvider(cfg.WebIdentity)
+			if err != nil {
+				return nil, err
+			}
+			providers = append(providers, provider)
+		default:
+			return nil, errors.Wrapf(ErrInvalidCredentials, "unknown source %s", source)
+		}
+	}
+
+	if len(providers) == 1 {
+		return credentials.New(providers[0]), nil
+	}
+	return credentials.NewChainCredentials(providers), nil
+}
+
+func webIdentityProvider(cfg *WebIdentityConfig) (credentials.Provider, error) {
+	if cfg == nil || cfg.TokenFile == "" || cfg.STSEndpoint == "" {
+		return nil, errors.Wrap(ErrInvalidCredentials, "web identity token file and sts endpoint are required")
+	}
+
+	return &credentials.STSWebIdentity{
+		Client:      credentialsClient(),
+		STSEndpoint: cfg.STSEndpoint,
+		RoleARN:     cfg.RoleARN,
+		GetWebIDTokenExpiry: func() (*credentials.WebIdentityToken, error) {
+			token, err := os.ReadFile(cfg.TokenFile)
+			if err != nil {
+				return nil, err
+			}
+			return &credentials.WebIdentityToken{Token: string(token)}, nil
+		},
+	}, nil
+}
+
+func credentialsClient() *http.Client {
+	return &http.Client{Transport: http.DefaultTransport.(*http.Transport).Clone()}
+}
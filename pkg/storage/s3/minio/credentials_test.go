@@ -0,0 +1,113 @@
+package minio
+
+import (
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewCredentials_Static(t *testing.T) {
+	creds, err := newCredentials(&Config{AccessKey: "access", SecretKey: "secret", SessionToken: "token"})
+	require.NoError(t, err)
+
+	value, err := creds.Get()
+	require.NoError(t, err)
+	assert.Equal(t, "access", value.AccessKeyID)
+	assert.Equal(t, "secret", value.SecretAccessKey)
+	assert.Equal(t, "token", value.SessionToken)
+}
+
+func TestNewCredentials_Chain(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "env-access")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "env-secret")
+
+	// Empty static credentials are skipped by the chain
+	creds, err := newCredentials(&Config{Credentials: []string{CredentialsStatic, CredentialsEnv}})
+	require.NoError(t, err)
+
+	value, err := creds.Get()
+	require.NoError(t, err)
+	assert.Equal(t, "env-access", value.AccessKeyID)
+	assert.Equal(t, "env-secret", value.SecretAccessKey)
+}
+
+func TestNewCredentials_File(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "credentials")
+	require.NoError(t, os.WriteFile(file, []byte(
+		"[default]\naws_access_key_id = default-access\naws_secret_access_key = default-secret\n"+
+			"[backup]\naws_access_key_id = backup-access\naws_secret_access_key = backup-secret\n",
+	), 0o600))
+
+	creds, err := newCredentials(&Config{
+		Credentials:        []string{CredentialsFile},
+		CredentialsFile:    file,
+		CredentialsProfile: "backup",
+	})
+	require.NoError(t, err)
+
+	value, err := creds.Get()
+	require.NoError(t, err)
+	assert.Equal(t, "backup-access", value.AccessKeyID)
+}
+
+func TestNewCredentials_UnknownSource(t *testing.T) {
+	_, err := newCredentials(&Config{Credentials: []string{CredentialsEnv, "vault"}})
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	assert.ErrorContains(t, err, "vault")
+}
+
+func TestNewCredentials_WebIdentity(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   *WebIdentityConfig
+		valid bool
+	}{
+		{name: "missing config", cfg: nil},
+		{name: "missing token file", cfg: &WebIdentityConfig{STSEndpoint: "https://sts.amazonaws.com"}},
+		{name: "missing sts endpoint", cfg: &WebIdentityConfig{TokenFile: "/var/run/token"}},
+		{
+			name:  "valid",
+			cfg:   &WebIdentityConfig{TokenFile: "/var/run/token", STSEndpoint: "https://sts.amazonaws.com", RoleARN: "arn:aws:iam::1:role/app"},
+			valid: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			creds, err := newCredentials(&Config{Credentials: []string{CredentialsWebIdentity}, WebIdentity: tt.cfg})
+			if !tt.valid {
+				assert.ErrorIs(t, err, ErrInvalidCredentials)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotNil(t, creds)
+		})
+	}
+}
+
+func TestWebIdentityProvider_ReadsTokenFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(file, []byte("first"), 0o600))
+
+	provider, err := webIdentityProvider(&WebIdentityConfig{TokenFile: file, STSEndpoint: "https://sts.amazonaws.com"})
+	require.NoError(t, err)
+	sts, ok := provider.(*credentials.STSWebIdentity)
+	require.True(t, ok)
+	assert.Equal(t, "https://sts.amazonaws.com", sts.STSEndpoint)
+
+	// The token is read again on every refresh, so the rotated token is used
+	token, err := sts.GetWebIDTokenExpiry()
+	require.NoError(t, err)
+	assert.Equal(t, "first", token.Token)
+
+	require.NoError(t, os.WriteFile(file, []byte("second"), 0o600))
+	token, err = sts.GetWebIDTokenExpiry()
+	require.NoError(t, err)
+	assert.Equal(t, "second", token.Token)
+
+	require.NoError(t, os.Remove(file))
+	_, err = sts.GetWebIDTokenExpiry()
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,77 @@
+package minio
+
+import (
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+	"github.com/minio/minio-go/v7/pkg/sse"
+	"github.com/pkg/errors"
+)
+
+const (
+	// EncryptionS3 encrypts objects with keys managed by the storage.
+	EncryptionS3 = "SSE-S3"
+	// EncryptionKMS encrypts objects with the KMS key.
+	EncryptionKMS = "SSE-KMS"
+	// EncryptionC encrypts objects with the key provided by the client. The storage doesn't
+	// keep the key, so objects can't be read without it.
+	EncryptionC = "SSE-C"
+)
+
+var ErrInvalidEncryption = errors.New("invalid server-side encryption config")
+
+// Encryption is the server-side encryption config.
+type Encryption struct {
+	// Type is EncryptionS3, EncryptionKMS or EncryptionC.
+	Type string
+	// KMSKeyID is the key id of EncryptionKMS.
+	KMSKeyID string
+	// CustomerKey is the 32 bytes key of EncryptionC.
+	CustomerKey []byte
+}
+
+func (e *Encryption) serverSide() (encrypt.ServerSide, error) {
+	if e == nil {
+		return nil, nil
+	}
+
+	switch e.Type {
+	case EncryptionS3:
+		return encrypt.NewSSE(), nil
+	case EncryptionKMS:
+		if e.KMSKeyID == "" {
+			return nil, errors.Wrap(ErrInvalidEncryption, "kms key id is empty")
+		}
+		return encrypt.NewSSEKMS(e.KMSKeyID, nil)
+	case EncryptionC:
+		serverSide, err := encrypt.NewSSEC(e.CustomerKey)
+		if err != nil {
+			return nil, errors.Wrap(ErrInvalidEncryption, err.Error())
+		}
+		return serverSide, nil
+	default:
+		return nil, errors.Wrapf(ErrInvalidEncryption, "unknown type %s", e.Type)
+	}
+}
+
+// bucketConfiguration returns the default encryption of the bucket, SSE-C can't be the default.
+func (e *Encryption) bucketConfiguration() (*sse.Configuration, error) {
+	switch e.Type {
+	case EncryptionS3:
+		return sse.NewConfigurationSSES3(), nil
+	case EncryptionKMS:
+		if e.KMSKeyID == "" {
+			return nil, errors.Wrap(ErrInvalidEncryption, "kms key id is empty")
+		}
+		return sse.NewConfigurationSSEKMS(e.KMSKeyID), nil
+	default:
+		return nil, errors.Wrapf(ErrInvalidEncryption, "type %s can't be the bucket default", e.Type)
+	}
+}
+
+// customerKey returns the server-side encryption if it's SSE-C. Reads and copy sources
+// need the key of SSE-C only, other types are applied by the storage.
+func (c *client) customerKey() encrypt.ServerSide {
+	if c.sse != nil && c.sse.Type() == encrypt.SSEC {
+		return c.sse
+	}
+	return nil
+}
@@ -2,55 +2,124 @@ package minio
 
 import (
 	"context"
-	"fmt"
 	"github.com/mandarine-io/baselib/pkg/storage/s3"
 	"github.com/minio/minio-go/v7"
-	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
-	"log/slog"
-	"sync"
+	"slices"
+	"strings"
 )
 
 type Config struct {
-	Address    string
-	AccessKey  string
-	SecretKey  string
+	// Address is the host and port of the storage, e.g. "s3.eu-central-1.amazonaws.com".
+	Address   string
+	AccessKey string
+	SecretKey string
+	// SessionToken is the session token of temporary static credentials.
+	SessionToken string
+	// Credentials is the chain of credential sources tried in order until one provides credentials:
+	// CredentialsStatic, CredentialsEnv, CredentialsFile, CredentialsIAM or CredentialsWebIdentity.
+	// If empty, the static credentials are used.
+	Credentials []string
+	// CredentialsFile is the AWS shared credentials file of CredentialsFile.
+	// If empty, AWS_SHARED_CREDENTIALS_FILE or "~/.aws/credentials" is used.
+	CredentialsFile string
+	// CredentialsProfile is the profile of CredentialsFile. If empty, AWS_PROFILE or "default" is used.
+	CredentialsProfile string
+	// WebIdentity is the config of CredentialsWebIdentity.
+	WebIdentity *WebIdentityConfig
+	// Region is the region of buckets. If empty, it's requested from the storage.
+	Region string
+	// BucketLookup is BucketLookupAuto, BucketLookupPath or BucketLookupDNS. If empty, BucketLookupAuto is used.
+	BucketLookup string
+	// Secure enables TLS.
+	Secure bool
+	// TLS configures TLS if Secure is set. If nil, the system CA certificates are trusted.
+	TLS *TLSConfig
+	// BucketName is the default bucket of the client.
 	BucketName string
+	// Buckets are created and configured on setup. They are addressed with Client.Bucket.
+	// If the default bucket is not in the list, it's created with the default configuration.
+	Buckets []BucketConfig
+	// Encryption is the server-side encryption of uploaded objects. If nil, the bucket default is used.
+	// Presigned requests are not supported with EncryptionC, since they would expose the key.
+	Encryption *Encryption
 }
 
 type client struct {
 	minio      *minio.Client
 	bucketName string
+	prefix     string
+	sse        encrypt.ServerSide
 }
 
 func MustNewMinioClient(cfg *Config) s3.Client {
-	// Configure to use MinIO Server
+	c, err := NewMinioClient(cfg)
+	if err != nil {
+		log.Fatal().Stack().Err(err).Msg("failed to connect to minio")
+	}
+	return c
+}
+
+// NewMinioClient connects to the S3-compatible storage and sets up the buckets of the config.
+func NewMinioClient(cfg *Config) (s3.Client, error) {
 	ctx := context.Background()
+	serverSide, err := cfg.Encryption.serverSide()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to setup minio encryption")
+	}
+
+	transport, err := newTransport(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to setup minio tls")
+	}
+	creds, err := newCredentials(cfg)
+	if err != nil {
+		return nil, err
+	}
+	lookup, err := bucketLookup(cfg.BucketLookup)
+	if err != nil {
+		return nil, err
+	}
+
 	minioClient, err := minio.New(cfg.Address, &minio.Options{
-		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
-		Secure: false,
+		Creds:        creds,
+		Secure:       cfg.Secure,
+		Transport:    transport,
+		Region:       cfg.Region,
+		BucketLookup: lookup,
 	})
 	if err != nil {
-		log.Fatal().Stack().Err(err).Msg("failed to connect to minio")
+		return nil, errors.Wrap(err, "failed to connect to minio")
 	}
 	log.Info().Msgf("connected to minio host %s", cfg.Address)
 
-	// Check if bucket exists
-	log.Info().Msgf("check bucket \"%s\"", cfg.BucketName)
-	exists, err := minioClient.BucketExists(ctx, cfg.BucketName)
-	if err != nil {
-		log.Fatal().Stack().Err(err).Msg("failed to check minio bucket")
+	buckets := cfg.Buckets
+	if cfg.BucketName != "" && !slices.ContainsFunc(buckets, func(b BucketConfig) bool { return b.Name == cfg.BucketName }) {
+		buckets = append([]BucketConfig{{Name: cfg.BucketName}}, buckets...)
 	}
-	if !exists {
-		slog.Info(fmt.Sprintf("Create bucket \"%s\"", cfg.BucketName))
-		err = minioClient.MakeBucket(ctx, cfg.BucketName, minio.MakeBucketOptions{})
-		if err != nil {
-			log.Fatal().Stack().Err(err).Msg("failed to create minio bucket")
+	for _, bucket := range buckets {
+		if err := setupBucket(ctx, minioClient, bucket); err != nil {
+			return nil, errors.Wrapf(err, "failed to setup minio bucket %s", bucket.Name)
 		}
 	}
 
-	return &client{minio: minioClient, bucketName: cfg.BucketName}
+	return &client{minio: minioClient, bucketName: cfg.BucketName, sse: serverSide}, nil
+}
+
+// Bucket returns the client of another bucket sharing the connection.
+func (c *client) Bucket(name string) s3.Client {
+	return &client{minio: c.minio, bucketName: name, sse: c.sse}
+}
+
+// Prefix returns the client of the same bucket storing objects under the key prefix.
+func (c *client) Prefix(prefix string) s3.Client {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return c
+	}
+	return &client{minio: c.minio, bucketName: c.bucketName, prefix: c.prefix + prefix + "/", sse: c.sse}
 }
 
 func (c *client) CreateOne(ctx context.Context, file *s3.FileData) *s3.CreateDto {
@@ -61,56 +130,32 @@ func (c *client) CreateOne(ctx context.Context, file *s3.FileData) *s3.CreateDto
 
 	// Upload
 	info, err := c.minio.PutObject(
-		ctx, c.bucketName, file.ID, file.Reader, file.Size,
+		ctx, c.bucketName, c.key(file.ID), file.Reader, file.Size,
 		minio.PutObjectOptions{
 			SendContentMd5:        true,
 			PartSize:              10 * 1024 * 1024,
 			ConcurrentStreamParts: true,
 			ContentType:           file.ContentType,
 			UserMetadata:          file.UserMetadata,
+			ServerSideEncryption:  c.sse,
 		})
 	if err != nil {
 		return &s3.CreateDto{Error: err}
 	}
-	return &s3.CreateDto{ObjectID: info.Key}
+	return &s3.CreateDto{ObjectID: c.objectID(info.Key)}
 }
 
-func (c *client) CreateMany(ctx context.Context, files []*s3.FileData) map[string]*s3.CreateDto {
+func (c *client) CreateMany(ctx context.Context, files []*s3.FileData, opts ...s3.BatchOption) ([]*s3.CreateDto, error) {
 	log.Debug().Msg("create many object")
-
-	type entry struct {
-		filename string
-		dto      *s3.CreateDto
-	}
-
-	dtoCh := make(chan *entry, len(files))
-	var wg sync.WaitGroup
-
-	for _, file := range files {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			dtoCh <- &entry{filename: file.UserMetadata[s3.OriginalFilenameMetadata], dto: c.CreateOne(ctx, file)}
-		}()
-	}
-
-	go func() {
-		wg.Wait()
-		close(dtoCh)
-	}()
-
-	dtoMap := make(map[string]*s3.CreateDto)
-	for entry := range dtoCh {
-		dtoMap[entry.filename] = entry.dto
-	}
-
-	return dtoMap
+	return s3.BatchCreate(ctx, files, c.CreateOne, opts...)
 }
 
 func (c *client) GetOne(ctx context.Context, objectID string) *s3.GetDto {
 	log.Debug().Msg("get one object")
 
-	object, err := c.minio.GetObject(ctx, c.bucketName, objectID, minio.GetObjectOptions{})
+	object, err := c.minio.GetObject(ctx, c.bucketName, c.key(objectID), minio.GetObjectOptions{
+		ServerSideEncryption: c.customerKey(),
+	})
 	if err != nil {
 		if errors.As(err, &minio.ErrorResponse{}) && err.(minio.ErrorResponse).Code == "NoSuchKey" {
 			return &s3.GetDto{Error: s3.ErrObjectNotFound}
@@ -131,56 +176,30 @@ func (c *client) GetOne(ctx context.Context, objectID string) *s3.GetDto {
 
 	return &s3.GetDto{
 		Data: &s3.FileData{
-			Reader:      object,
-			ID:          stat.Key,
-			Size:        stat.Size,
-			ContentType: stat.ContentType,
+			Reader:       object,
+			ID:           c.objectID(stat.Key),
+			Size:         stat.Size,
+			ContentType:  stat.ContentType,
+			UserMetadata: userMetadata(stat),
 		},
 	}
 }
 
-func (c *client) GetMany(ctx context.Context, objectIDs []string) map[string]*s3.GetDto {
+func (c *client) GetMany(ctx context.Context, objectIDs []string, opts ...s3.BatchOption) (map[string]*s3.GetDto, error) {
 	log.Debug().Msg("get many object")
-
-	type entry struct {
-		objectID string
-		dto      *s3.GetDto
-	}
-
-	dtoCh := make(chan *entry, len(objectIDs))
-	var wg sync.WaitGroup
-
-	for _, objectID := range objectIDs {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			dtoCh <- &entry{objectID: objectID, dto: c.GetOne(ctx, objectID)}
-		}()
-	}
-
-	go func() {
-		wg.Wait()
-		close(dtoCh)
-	}()
-
-	dtoMap := make(map[string]*s3.GetDto)
-	for entry := range dtoCh {
-		dtoMap[entry.objectID] = entry.dto
-	}
-
-	return dtoMap
+	return s3.BatchGet(ctx, objectIDs, c.GetOne, opts...)
 }
 
 func (c *client) DeleteOne(ctx context.Context, objectID string) error {
 	log.Debug().Msg("delete one object")
-	return c.minio.RemoveObject(ctx, c.bucketName, objectID, minio.RemoveObjectOptions{})
+	return c.minio.RemoveObject(ctx, c.bucketName, c.key(objectID), minio.RemoveObjectOptions{})
 }
 
 func (c *client) DeleteMany(ctx context.Context, objectIDs []string) map[string]error {
 	log.Debug().Msg("delete many object")
 	objectIdCh := make(chan minio.ObjectInfo, len(objectIDs))
 	for _, objectID := range objectIDs {
-		objectIdCh <- minio.ObjectInfo{Key: objectID}
+		objectIdCh <- minio.ObjectInfo{Key: c.key(objectID)}
 	}
 	close(objectIdCh)
 
@@ -188,8 +207,16 @@ func (c *client) DeleteMany(ctx context.Context, objectIDs []string) map[string]
 
 	errMap := make(map[string]error)
 	for obj := range objCh {
-		errMap[obj.ObjectName] = obj.Err
+		errMap[c.objectID(obj.ObjectName)] = obj.Err
 	}
 
 	return errMap
 }
+
+func (c *client) key(objectID string) string {
+	return c.prefix + objectID
+}
+
+func (c *client) objectID(key string) string {
+	return strings.TrimPrefix(key, c.prefix)
+}
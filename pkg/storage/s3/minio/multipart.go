@@ -0,0 +1,133 @@
+package minio
+
+import (
+	"context"
+	"github.com/mandarine-io/baselib/pkg/storage/s3"
+	"github.com/minio/minio-go/v7"
+	"github.com/rs/zerolog/log"
+	"io"
+	"sort"
+)
+
+const listPartsPageSize = 1000
+
+func (c *client) GetRange(ctx context.Context, objectID string, offset int64, length int64) (*s3.FileData, error) {
+	log.Debug().Msgf("get object range %d+%d", offset, length)
+
+	if offset < 0 || length == 0 {
+		return nil, s3.ErrInvalidRange
+	}
+
+	opts := minio.GetObjectOptions{ServerSideEncryption: c.customerKey()}
+	var err error
+	switch {
+	case length > 0:
+		err = opts.SetRange(offset, offset+length-1)
+	case offset > 0:
+		err = opts.SetRange(offset, 0)
+	}
+	if err != nil {
+		return nil, s3.ErrInvalidRange
+	}
+
+	object, err := c.minio.GetObject(ctx, c.bucketName, c.key(objectID), opts)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	// The size of the ranged response is the size of the range
+	stat, err := object.Stat()
+	if err != nil {
+		_ = object.Close()
+		return nil, mapError(err)
+	}
+
+	return &s3.FileData{
+		ID:           c.objectID(stat.Key),
+		Size:         stat.Size,
+		ContentType:  stat.ContentType,
+		Reader:       object,
+		UserMetadata: userMetadata(stat),
+	}, nil
+}
+
+func (c *client) InitiateUpload(
+	ctx context.Context, objectID string, contentType string, metadata map[string]string,
+) (string, error) {
+	log.Debug().Msg("initiate multipart upload")
+
+	core := minio.Core{Client: c.minio}
+	return core.NewMultipartUpload(ctx, c.bucketName, c.key(objectID), minio.PutObjectOptions{
+		ContentType:          contentType,
+		UserMetadata:         metadata,
+		ServerSideEncryption: c.sse,
+	})
+}
+
+func (c *client) UploadPart(
+	ctx context.Context, objectID string, uploadID string, number int, r io.Reader, size int64,
+) (*s3.Part, error) {
+	log.Debug().Msgf("upload part %d", number)
+
+	if number < 1 || number > s3.MaxPartNumber {
+		return nil, s3.ErrInvalidPart
+	}
+
+	core := minio.Core{Client: c.minio}
+	part, err := core.PutObjectPart(ctx, c.bucketName, c.key(objectID), uploadID, number, r, size, minio.PutObjectPartOptions{
+		SSE: c.customerKey(),
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	return &s3.Part{Number: part.PartNumber, ETag: part.ETag, Size: part.Size}, nil
+}
+
+func (c *client) ListParts(ctx context.Context, objectID string, uploadID string) ([]s3.Part, error) {
+	log.Debug().Msg("list uploaded parts")
+
+	core := minio.Core{Client: c.minio}
+	parts := make([]s3.Part, 0)
+	marker := 0
+	for {
+		result, err := core.ListObjectParts(ctx, c.bucketName, c.key(objectID), uploadID, marker, listPartsPageSize)
+		if err != nil {
+			return nil, mapError(err)
+		}
+
+		for _, part := range result.ObjectParts {
+			parts = append(parts, s3.Part{Number: part.PartNumber, ETag: part.ETag, Size: part.Size})
+		}
+
+		if !result.IsTruncated {
+			return parts, nil
+		}
+		marker = result.NextPartNumberMarker
+	}
+}
+
+func (c *client) CompleteUpload(ctx context.Context, objectID string, uploadID string, parts []s3.Part) error {
+	log.Debug().Msg("complete multipart upload")
+
+	completeParts := make([]minio.CompletePart, 0, len(parts))
+	for _, part := range parts {
+		completeParts = append(completeParts, minio.CompletePart{PartNumber: part.Number, ETag: part.ETag})
+	}
+	sort.Slice(completeParts, func(i, j int) bool {
+		return completeParts[i].PartNumber < completeParts[j].PartNumber
+	})
+
+	core := minio.Core{Client: c.minio}
+	_, err := core.CompleteMultipartUpload(ctx, c.bucketName, c.key(objectID), uploadID, completeParts, minio.PutObjectOptions{
+		ServerSideEncryption: c.customerKey(),
+	})
+	return mapError(err)
+}
+
+func (c *client) AbortUpload(ctx context.Context, objectID string, uploadID string) error {
+	log.Debug().Msg("abort multipart upload")
+
+	core := minio.Core{Client: c.minio}
+	return mapError(core.AbortMultipartUpload(ctx, c.bucketName, c.key(objectID), uploadID))
+}
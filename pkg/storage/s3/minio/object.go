@@ -0,0 +1,164 @@
+package minio
+
+import (
+	"context"
+	"github.com/mandarine-io/baselib/pkg/storage/s3"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"strings"
+)
+
+const listPageSize = 1000
+
+func (c *client) List(ctx context.Context, prefix string, cursor string) (*s3.ListPage, error) {
+	log.Debug().Msgf("list objects with prefix %s", prefix)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	opts := minio.ListObjectsOptions{
+		Prefix:    c.key(prefix),
+		Recursive: true,
+		MaxKeys:   listPageSize,
+	}
+	if cursor != "" {
+		opts.StartAfter = c.key(cursor)
+	}
+
+	page := &s3.ListPage{Objects: make([]s3.ObjectInfo, 0)}
+	for obj := range c.minio.ListObjects(ctx, c.bucketName, opts) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		if len(page.Objects) == listPageSize {
+			page.NextCursor = page.Objects[len(page.Objects)-1].ID
+			break
+		}
+		page.Objects = append(page.Objects, c.objectInfo(obj))
+	}
+
+	return page, nil
+}
+
+func (c *client) Stat(ctx context.Context, objectID string) (*s3.ObjectInfo, error) {
+	log.Debug().Msg("stat object")
+
+	stat, err := c.minio.StatObject(ctx, c.bucketName, c.key(objectID), minio.StatObjectOptions{
+		ServerSideEncryption: c.customerKey(),
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	info := c.objectInfo(stat)
+	return &info, nil
+}
+
+func (c *client) Exists(ctx context.Context, objectID string) (bool, error) {
+	log.Debug().Msg("check object exists")
+
+	_, err := c.Stat(ctx, objectID)
+	if errors.Is(err, s3.ErrObjectNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (c *client) Copy(ctx context.Context, srcObjectID string, dstObjectID string) error {
+	log.Debug().Msg("copy object")
+
+	_, err := c.minio.CopyObject(ctx,
+		minio.CopyDestOptions{Bucket: c.bucketName, Object: c.key(dstObjectID), Encryption: c.sse},
+		minio.CopySrcOptions{Bucket: c.bucketName, Object: c.key(srcObjectID), Encryption: c.customerKey()},
+	)
+	return mapError(err)
+}
+
+func (c *client) Move(ctx context.Context, srcObjectID string, dstObjectID string) error {
+	log.Debug().Msg("move object")
+
+	if err := c.Copy(ctx, srcObjectID, dstObjectID); err != nil {
+		return err
+	}
+	return c.DeleteOne(ctx, srcObjectID)
+}
+
+func (c *client) UpdateMetadata(ctx context.Context, objectID string, metadata map[string]string) error {
+	log.Debug().Msg("update object metadata")
+
+	stat, err := c.minio.StatObject(ctx, c.bucketName, c.key(objectID), minio.StatObjectOptions{
+		ServerSideEncryption: c.customerKey(),
+	})
+	if err != nil {
+		return mapError(err)
+	}
+
+	// Metadata replacement resets the content type, so it's copied explicitly
+	userMetadata := make(map[string]string, len(metadata)+1)
+	for k, v := range metadata {
+		userMetadata[k] = v
+	}
+	if stat.ContentType != "" {
+		userMetadata["Content-Type"] = stat.ContentType
+	}
+
+	_, err = c.minio.CopyObject(ctx,
+		minio.CopyDestOptions{
+			Bucket:          c.bucketName,
+			Object:          c.key(objectID),
+			UserMetadata:    userMetadata,
+			ReplaceMetadata: true,
+			Encryption:      c.sse,
+		},
+		minio.CopySrcOptions{Bucket: c.bucketName, Object: c.key(objectID), Encryption: c.customerKey()},
+	)
+	return mapError(err)
+}
+
+func (c *client) objectInfo(obj minio.ObjectInfo) s3.ObjectInfo {
+	return s3.ObjectInfo{
+		ID:           c.objectID(obj.Key),
+		Size:         obj.Size,
+		ContentType:  obj.ContentType,
+		ETag:         obj.ETag,
+		LastModified: obj.LastModified,
+		UserMetadata: userMetadata(obj),
+	}
+}
+
+// userMetadata returns the user metadata with the same keys as they were set,
+// e.g. s3.OriginalFilenameMetadata.
+func userMetadata(obj minio.ObjectInfo) map[string]string {
+	if len(obj.UserMetadata) == 0 {
+		return nil
+	}
+
+	metadata := make(map[string]string, len(obj.UserMetadata))
+	for k, v := range obj.UserMetadata {
+		metadata[s3.UserMetadataPrefix+strings.ToLower(k)] = v
+	}
+	return metadata
+}
+
+func mapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	switch minio.ToErrorResponse(err).Code {
+	case "NoSuchKey", "NoSuchObject":
+		return s3.ErrObjectNotFound
+	case "InvalidRange":
+		return s3.ErrInvalidRange
+	case "NoSuchUpload":
+		return s3.ErrUploadNotFound
+	case "InvalidPart", "InvalidPartOrder":
+		return s3.ErrInvalidPart
+	case "EntityTooSmall":
+		return s3.ErrPartTooSmall
+	}
+	return err
+}
@@ -0,0 +1,122 @@
+package minio
+
+import (
+	"context"
+	"github.com/mandarine-io/baselib/pkg/storage/s3"
+	"github.com/minio/minio-go/v7"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (c *client) PresignGet(ctx context.Context, objectID string, ttl time.Duration) (*s3.PresignedRequest, error) {
+	log.Debug().Msg("presign get object")
+
+	if c.customerKey() != nil {
+		return nil, s3.ErrNotSupported
+	}
+
+	u, err := c.minio.PresignedGetObject(ctx, c.bucketName, c.key(objectID), ttl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3.PresignedRequest{
+		URL:       u.String(),
+		Method:    http.MethodGet,
+		Headers:   http.Header{},
+		ExpiresAt: time.Now().Add(ttl),
+	}, nil
+}
+
+func (c *client) PresignPut(
+	ctx context.Context, objectID string, ttl time.Duration, contentType string, maxSize int64,
+) (*s3.PresignedRequest, error) {
+	log.Debug().Msg("presign put object")
+
+	if c.customerKey() != nil {
+		return nil, s3.ErrNotSupported
+	}
+
+	headers := http.Header{}
+	if contentType != "" {
+		headers.Set("Content-Type", contentType)
+	}
+	// PUT can't be limited by content-length-range, only the exact size is signed
+	if maxSize > 0 {
+		headers.Set("Content-Length", strconv.FormatInt(maxSize, 10))
+	}
+	if c.sse != nil {
+		c.sse.Marshal(headers)
+	}
+
+	u, err := c.minio.PresignHeader(ctx, http.MethodPut, c.bucketName, c.key(objectID), ttl, nil, headers)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3.PresignedRequest{
+		URL:       u.String(),
+		Method:    http.MethodPut,
+		Headers:   headers,
+		ExpiresAt: time.Now().Add(ttl),
+	}, nil
+}
+
+func (c *client) PresignPost(
+	ctx context.Context, objectID string, ttl time.Duration, policy s3.PostPolicy,
+) (*s3.PresignedPost, error) {
+	log.Debug().Msg("presign post object")
+
+	if c.customerKey() != nil {
+		return nil, s3.ErrNotSupported
+	}
+
+	expiresAt := time.Now().Add(ttl)
+
+	p := minio.NewPostPolicy()
+	if err := p.SetBucket(c.bucketName); err != nil {
+		return nil, err
+	}
+	if err := p.SetKey(c.key(objectID)); err != nil {
+		return nil, err
+	}
+	if err := p.SetExpires(expiresAt.UTC()); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case strings.HasSuffix(policy.ContentType, "/"):
+		if err := p.SetContentTypeStartsWith(policy.ContentType); err != nil {
+			return nil, err
+		}
+	case policy.ContentType != "":
+		if err := p.SetContentType(policy.ContentType); err != nil {
+			return nil, err
+		}
+	}
+
+	if policy.MaxSize > 0 {
+		if err := p.SetContentLengthRange(policy.MinSize, policy.MaxSize); err != nil {
+			return nil, err
+		}
+	}
+
+	for key, value := range policy.UserMetadata {
+		key = strings.TrimPrefix(strings.ToLower(key), s3.UserMetadataPrefix)
+		if err := p.SetUserMetadata(key, value); err != nil {
+			return nil, err
+		}
+	}
+
+	p.SetEncryption(c.sse)
+
+	u, formData, err := c.minio.PresignedPostPolicy(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3.PresignedPost{URL: u.String(), FormData: formData, ExpiresAt: expiresAt}, nil
+}
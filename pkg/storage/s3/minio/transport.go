@@ -0,0 +1,90 @@
+package minio
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"net/http"
+	"os"
+)
+
+const (
+	// BucketLookupAuto detects the addressing by the endpoint: virtual-host style for AWS
+	// and Google Cloud, path style for others.
+	BucketLookupAuto = "auto"
+	// BucketLookupPath addresses buckets in the path: "https://host/bucket/key".
+	BucketLookupPath = "path"
+	// BucketLookupDNS addresses buckets in the host: "https://bucket.host/key".
+	BucketLookupDNS = "dns"
+)
+
+type TLSConfig struct {
+	// CAFile is the PEM file of CA certificates trusted in addition to the system ones.
+	CAFile string
+	// CertFile and KeyFile are the PEM files of the client certificate for mutual TLS.
+	CertFile string
+	KeyFile  string
+	// ServerName overrides the server name verified in the certificate.
+	ServerName string
+	// InsecureSkipVerify disables the verification of the server certificate. Use it only in development.
+	InsecureSkipVerify bool
+}
+
+func newTransport(cfg *Config) (*http.Transport, error) {
+	transport, err := minio.DefaultTransport(cfg.Secure)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.Secure || cfg.TLS == nil {
+		return transport, nil
+	}
+
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	tlsConfig := transport.TLSClientConfig
+	tlsConfig.ServerName = cfg.TLS.ServerName
+	tlsConfig.InsecureSkipVerify = cfg.TLS.InsecureSkipVerify
+
+	if cfg.TLS.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(cfg.TLS.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificates found in %s", cfg.TLS.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.TLS.CertFile != "" || cfg.TLS.KeyFile != "" {
+		if cfg.TLS.CertFile == "" || cfg.TLS.KeyFile == "" {
+			return nil, errors.New("both client certificate and key files are required")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return transport, nil
+}
+
+func bucketLookup(lookup string) (minio.BucketLookupType, error) {
+	switch lookup {
+	case "", BucketLookupAuto:
+		return minio.BucketLookupAuto, nil
+	case BucketLookupPath:
+		return minio.BucketLookupPath, nil
+	case BucketLookupDNS:
+		return minio.BucketLookupDNS, nil
+	default:
+		return 0, errors.Errorf("unknown bucket lookup %s", lookup)
+	}
+}
@@ -0,0 +1,108 @@
+package minio
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/minio/minio-go/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCert writes the self-signed certificate and its key to the PEM files in dir.
+func writeCert(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+func TestNewTransport_TLS(t *testing.T) {
+	certFile, keyFile := writeCert(t, t.TempDir())
+
+	transport, err := newTransport(&Config{
+		Secure: true,
+		TLS: &TLSConfig{
+			CAFile:     certFile,
+			CertFile:   certFile,
+			KeyFile:    keyFile,
+			ServerName: "storage.internal",
+		},
+	})
+	require.NoError(t, err)
+
+	tlsConfig := transport.TLSClientConfig
+	require.NotNil(t, tlsConfig)
+	assert.Equal(t, "storage.internal", tlsConfig.ServerName)
+	assert.NotNil(t, tlsConfig.RootCAs)
+	assert.Len(t, tlsConfig.Certificates, 1)
+	assert.False(t, tlsConfig.InsecureSkipVerify)
+}
+
+func TestNewTransport_TLSIgnoredWithoutSecure(t *testing.T) {
+	transport, err := newTransport(&Config{TLS: &TLSConfig{CAFile: "/missing/ca.pem"}})
+	require.NoError(t, err)
+	assert.NotNil(t, transport)
+}
+
+func TestNewTransport_Errors(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir)
+	notPEM := filepath.Join(dir, "not.pem")
+	require.NoError(t, os.WriteFile(notPEM, []byte("not a certificate"), 0o600))
+
+	tests := []struct {
+		name string
+		tls  *TLSConfig
+	}{
+		{name: "missing ca file", tls: &TLSConfig{CAFile: filepath.Join(dir, "missing.pem")}},
+		{name: "ca file without certificates", tls: &TLSConfig{CAFile: notPEM}},
+		{name: "cert without key", tls: &TLSConfig{CertFile: certFile}},
+		{name: "key without cert", tls: &TLSConfig{KeyFile: keyFile}},
+		{name: "invalid key", tls: &TLSConfig{CertFile: certFile, KeyFile: notPEM}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newTransport(&Config{Secure: true, TLS: tt.tls})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestBucketLookup(t *testing.T) {
+	lookup, err := bucketLookup("")
+	require.NoError(t, err)
+	assert.Equal(t, minio.BucketLookupAuto, lookup)
+
+	lookup, err = bucketLookup(BucketLookupPath)
+	require.NoError(t, err)
+	assert.Equal(t, minio.BucketLookupPath, lookup)
+
+	_, err = bucketLookup("virtual")
+	assert.Error(t, err)
+}
@@ -4,9 +4,13 @@ package mock
 
 import (
 	context "context"
+	io "io"
 
-	s3 "github.com/mandarine-io/baselib/pkg/storage/s3"
 	mock "github.com/stretchr/testify/mock"
+
+	s3 "github.com/mandarine-io/baselib/pkg/storage/s3"
+
+	time "time"
 )
 
 // ClientMock is an autogenerated mock type for the Client type
@@ -22,26 +26,236 @@ func (_m *ClientMock) EXPECT() *ClientMock_Expecter {
 	return &ClientMock_Expecter{mock: &_m.Mock}
 }
 
-// CreateMany provides a mock function with given fields: ctx, files
-func (_m *ClientMock) CreateMany(ctx context.Context, files []*s3.FileData) map[string]*s3.CreateDto {
-	ret := _m.Called(ctx, files)
+// AbortUpload provides a mock function with given fields: ctx, objectID, uploadID
+func (_m *ClientMock) AbortUpload(ctx context.Context, objectID string, uploadID string) error {
+	ret := _m.Called(ctx, objectID, uploadID)
 
 	if len(ret) == 0 {
-		panic("no return value specified for CreateMany")
+		panic("no return value specified for AbortUpload")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, objectID, uploadID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ClientMock_AbortUpload_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'AbortUpload'
+type ClientMock_AbortUpload_Call struct {
+	*mock.Call
+}
+
+// AbortUpload is a helper method to define mock.On call
+//   - ctx context.Context
+//   - objectID string
+//   - uploadID string
+func (_e *ClientMock_Expecter) AbortUpload(ctx interface{}, objectID interface{}, uploadID interface{}) *ClientMock_AbortUpload_Call {
+	return &ClientMock_AbortUpload_Call{Call: _e.mock.On("AbortUpload", ctx, objectID, uploadID)}
+}
+
+func (_c *ClientMock_AbortUpload_Call) Run(run func(ctx context.Context, objectID string, uploadID string)) *ClientMock_AbortUpload_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(string))
+	})
+	return _c
+}
+
+func (_c *ClientMock_AbortUpload_Call) Return(_a0 error) *ClientMock_AbortUpload_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *ClientMock_AbortUpload_Call) RunAndReturn(run func(context.Context, string, string) error) *ClientMock_AbortUpload_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Bucket provides a mock function with given fields: name
+func (_m *ClientMock) Bucket(name string) s3.Client {
+	ret := _m.Called(name)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Bucket")
 	}
 
-	var r0 map[string]*s3.CreateDto
-	if rf, ok := ret.Get(0).(func(context.Context, []*s3.FileData) map[string]*s3.CreateDto); ok {
-		r0 = rf(ctx, files)
+	var r0 s3.Client
+	if rf, ok := ret.Get(0).(func(string) s3.Client); ok {
+		r0 = rf(name)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(map[string]*s3.CreateDto)
+			r0 = ret.Get(0).(s3.Client)
 		}
 	}
 
 	return r0
 }
 
+// ClientMock_Bucket_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Bucket'
+type ClientMock_Bucket_Call struct {
+	*mock.Call
+}
+
+// Bucket is a helper method to define mock.On call
+//   - name string
+func (_e *ClientMock_Expecter) Bucket(name interface{}) *ClientMock_Bucket_Call {
+	return &ClientMock_Bucket_Call{Call: _e.mock.On("Bucket", name)}
+}
+
+func (_c *ClientMock_Bucket_Call) Run(run func(name string)) *ClientMock_Bucket_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string))
+	})
+	return _c
+}
+
+func (_c *ClientMock_Bucket_Call) Return(_a0 s3.Client) *ClientMock_Bucket_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *ClientMock_Bucket_Call) RunAndReturn(run func(string) s3.Client) *ClientMock_Bucket_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// CompleteUpload provides a mock function with given fields: ctx, objectID, uploadID, parts
+func (_m *ClientMock) CompleteUpload(ctx context.Context, objectID string, uploadID string, parts []s3.Part) error {
+	ret := _m.Called(ctx, objectID, uploadID, parts)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CompleteUpload")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, []s3.Part) error); ok {
+		r0 = rf(ctx, objectID, uploadID, parts)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ClientMock_CompleteUpload_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CompleteUpload'
+type ClientMock_CompleteUpload_Call struct {
+	*mock.Call
+}
+
+// CompleteUpload is a helper method to define mock.On call
+//   - ctx context.Context
+//   - objectID string
+//   - uploadID string
+//   - parts []s3.Part
+func (_e *ClientMock_Expecter) CompleteUpload(ctx interface{}, objectID interface{}, uploadID interface{}, parts interface{}) *ClientMock_CompleteUpload_Call {
+	return &ClientMock_CompleteUpload_Call{Call: _e.mock.On("CompleteUpload", ctx, objectID, uploadID, parts)}
+}
+
+func (_c *ClientMock_CompleteUpload_Call) Run(run func(ctx context.Context, objectID string, uploadID string, parts []s3.Part)) *ClientMock_CompleteUpload_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(string), args[3].([]s3.Part))
+	})
+	return _c
+}
+
+func (_c *ClientMock_CompleteUpload_Call) Return(_a0 error) *ClientMock_CompleteUpload_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *ClientMock_CompleteUpload_Call) RunAndReturn(run func(context.Context, string, string, []s3.Part) error) *ClientMock_CompleteUpload_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Copy provides a mock function with given fields: ctx, srcObjectID, dstObjectID
+func (_m *ClientMock) Copy(ctx context.Context, srcObjectID string, dstObjectID string) error {
+	ret := _m.Called(ctx, srcObjectID, dstObjectID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Copy")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, srcObjectID, dstObjectID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ClientMock_Copy_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Copy'
+type ClientMock_Copy_Call struct {
+	*mock.Call
+}
+
+// Copy is a helper method to define mock.On call
+//   - ctx context.Context
+//   - srcObjectID string
+//   - dstObjectID string
+func (_e *ClientMock_Expecter) Copy(ctx interface{}, srcObjectID interface{}, dstObjectID interface{}) *ClientMock_Copy_Call {
+	return &ClientMock_Copy_Call{Call: _e.mock.On("Copy", ctx, srcObjectID, dstObjectID)}
+}
+
+func (_c *ClientMock_Copy_Call) Run(run func(ctx context.Context, srcObjectID string, dstObjectID string)) *ClientMock_Copy_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(string))
+	})
+	return _c
+}
+
+func (_c *ClientMock_Copy_Call) Return(_a0 error) *ClientMock_Copy_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *ClientMock_Copy_Call) RunAndReturn(run func(context.Context, string, string) error) *ClientMock_Copy_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// CreateMany provides a mock function with given fields: ctx, files, opts
+func (_m *ClientMock) CreateMany(ctx context.Context, files []*s3.FileData, opts ...s3.BatchOption) ([]*s3.CreateDto, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, files)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateMany")
+	}
+
+	var r0 []*s3.CreateDto
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []*s3.FileData, ...s3.BatchOption) ([]*s3.CreateDto, error)); ok {
+		return rf(ctx, files, opts...)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []*s3.FileData, ...s3.BatchOption) []*s3.CreateDto); ok {
+		r0 = rf(ctx, files, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*s3.CreateDto)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []*s3.FileData, ...s3.BatchOption) error); ok {
+		r1 = rf(ctx, files, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ClientMock_CreateMany_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CreateMany'
 type ClientMock_CreateMany_Call struct {
 	*mock.Call
@@ -50,23 +264,31 @@ type ClientMock_CreateMany_Call struct {
 // CreateMany is a helper method to define mock.On call
 //   - ctx context.Context
 //   - files []*s3.FileData
-func (_e *ClientMock_Expecter) CreateMany(ctx interface{}, files interface{}) *ClientMock_CreateMany_Call {
-	return &ClientMock_CreateMany_Call{Call: _e.mock.On("CreateMany", ctx, files)}
+//   - opts ...s3.BatchOption
+func (_e *ClientMock_Expecter) CreateMany(ctx interface{}, files interface{}, opts ...interface{}) *ClientMock_CreateMany_Call {
+	return &ClientMock_CreateMany_Call{Call: _e.mock.On("CreateMany",
+		append([]interface{}{ctx, files}, opts...)...)}
 }
 
-func (_c *ClientMock_CreateMany_Call) Run(run func(ctx context.Context, files []*s3.FileData)) *ClientMock_CreateMany_Call {
+func (_c *ClientMock_CreateMany_Call) Run(run func(ctx context.Context, files []*s3.FileData, opts ...s3.BatchOption)) *ClientMock_CreateMany_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(context.Context), args[1].([]*s3.FileData))
+		variadicArgs := make([]s3.BatchOption, len(args)-2)
+		for i, a := range args[2:] {
+			if a != nil {
+				variadicArgs[i] = a.(s3.BatchOption)
+			}
+		}
+		run(args[0].(context.Context), args[1].([]*s3.FileData), variadicArgs...)
 	})
 	return _c
 }
 
-func (_c *ClientMock_CreateMany_Call) Return(_a0 map[string]*s3.CreateDto) *ClientMock_CreateMany_Call {
-	_c.Call.Return(_a0)
+func (_c *ClientMock_CreateMany_Call) Return(_a0 []*s3.CreateDto, _a1 error) *ClientMock_CreateMany_Call {
+	_c.Call.Return(_a0, _a1)
 	return _c
 }
 
-func (_c *ClientMock_CreateMany_Call) RunAndReturn(run func(context.Context, []*s3.FileData) map[string]*s3.CreateDto) *ClientMock_CreateMany_Call {
+func (_c *ClientMock_CreateMany_Call) RunAndReturn(run func(context.Context, []*s3.FileData, ...s3.BatchOption) ([]*s3.CreateDto, error)) *ClientMock_CreateMany_Call {
 	_c.Call.Return(run)
 	return _c
 }
@@ -216,24 +438,98 @@ func (_c *ClientMock_DeleteOne_Call) RunAndReturn(run func(context.Context, stri
 	return _c
 }
 
-// GetMany provides a mock function with given fields: ctx, objectIDs
-func (_m *ClientMock) GetMany(ctx context.Context, objectIDs []string) map[string]*s3.GetDto {
-	ret := _m.Called(ctx, objectIDs)
+// Exists provides a mock function with given fields: ctx, objectID
+func (_m *ClientMock) Exists(ctx context.Context, objectID string) (bool, error) {
+	ret := _m.Called(ctx, objectID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Exists")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (bool, error)); ok {
+		return rf(ctx, objectID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) bool); ok {
+		r0 = rf(ctx, objectID)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, objectID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ClientMock_Exists_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Exists'
+type ClientMock_Exists_Call struct {
+	*mock.Call
+}
+
+// Exists is a helper method to define mock.On call
+//   - ctx context.Context
+//   - objectID string
+func (_e *ClientMock_Expecter) Exists(ctx interface{}, objectID interface{}) *ClientMock_Exists_Call {
+	return &ClientMock_Exists_Call{Call: _e.mock.On("Exists", ctx, objectID)}
+}
+
+func (_c *ClientMock_Exists_Call) Run(run func(ctx context.Context, objectID string)) *ClientMock_Exists_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *ClientMock_Exists_Call) Return(_a0 bool, _a1 error) *ClientMock_Exists_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *ClientMock_Exists_Call) RunAndReturn(run func(context.Context, string) (bool, error)) *ClientMock_Exists_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetMany provides a mock function with given fields: ctx, objectIDs, opts
+func (_m *ClientMock) GetMany(ctx context.Context, objectIDs []string, opts ...s3.BatchOption) (map[string]*s3.GetDto, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, objectIDs)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetMany")
 	}
 
 	var r0 map[string]*s3.GetDto
-	if rf, ok := ret.Get(0).(func(context.Context, []string) map[string]*s3.GetDto); ok {
-		r0 = rf(ctx, objectIDs)
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []string, ...s3.BatchOption) (map[string]*s3.GetDto, error)); ok {
+		return rf(ctx, objectIDs, opts...)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []string, ...s3.BatchOption) map[string]*s3.GetDto); ok {
+		r0 = rf(ctx, objectIDs, opts...)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(map[string]*s3.GetDto)
 		}
 	}
 
-	return r0
+	if rf, ok := ret.Get(1).(func(context.Context, []string, ...s3.BatchOption) error); ok {
+		r1 = rf(ctx, objectIDs, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
 
 // ClientMock_GetMany_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetMany'
@@ -244,23 +540,31 @@ type ClientMock_GetMany_Call struct {
 // GetMany is a helper method to define mock.On call
 //   - ctx context.Context
 //   - objectIDs []string
-func (_e *ClientMock_Expecter) GetMany(ctx interface{}, objectIDs interface{}) *ClientMock_GetMany_Call {
-	return &ClientMock_GetMany_Call{Call: _e.mock.On("GetMany", ctx, objectIDs)}
+//   - opts ...s3.BatchOption
+func (_e *ClientMock_Expecter) GetMany(ctx interface{}, objectIDs interface{}, opts ...interface{}) *ClientMock_GetMany_Call {
+	return &ClientMock_GetMany_Call{Call: _e.mock.On("GetMany",
+		append([]interface{}{ctx, objectIDs}, opts...)...)}
 }
 
-func (_c *ClientMock_GetMany_Call) Run(run func(ctx context.Context, objectIDs []string)) *ClientMock_GetMany_Call {
+func (_c *ClientMock_GetMany_Call) Run(run func(ctx context.Context, objectIDs []string, opts ...s3.BatchOption)) *ClientMock_GetMany_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(context.Context), args[1].([]string))
+		variadicArgs := make([]s3.BatchOption, len(args)-2)
+		for i, a := range args[2:] {
+			if a != nil {
+				variadicArgs[i] = a.(s3.BatchOption)
+			}
+		}
+		run(args[0].(context.Context), args[1].([]string), variadicArgs...)
 	})
 	return _c
 }
 
-func (_c *ClientMock_GetMany_Call) Return(_a0 map[string]*s3.GetDto) *ClientMock_GetMany_Call {
-	_c.Call.Return(_a0)
+func (_c *ClientMock_GetMany_Call) Return(_a0 map[string]*s3.GetDto, _a1 error) *ClientMock_GetMany_Call {
+	_c.Call.Return(_a0, _a1)
 	return _c
 }
 
-func (_c *ClientMock_GetMany_Call) RunAndReturn(run func(context.Context, []string) map[string]*s3.GetDto) *ClientMock_GetMany_Call {
+func (_c *ClientMock_GetMany_Call) RunAndReturn(run func(context.Context, []string, ...s3.BatchOption) (map[string]*s3.GetDto, error)) *ClientMock_GetMany_Call {
 	_c.Call.Return(run)
 	return _c
 }
@@ -314,6 +618,695 @@ func (_c *ClientMock_GetOne_Call) RunAndReturn(run func(context.Context, string)
 	return _c
 }
 
+// GetRange provides a mock function with given fields: ctx, objectID, offset, length
+func (_m *ClientMock) GetRange(ctx context.Context, objectID string, offset int64, length int64) (*s3.FileData, error) {
+	ret := _m.Called(ctx, objectID, offset, length)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetRange")
+	}
+
+	var r0 *s3.FileData
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, int64, int64) (*s3.FileData, error)); ok {
+		return rf(ctx, objectID, offset, length)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, int64, int64) *s3.FileData); ok {
+		r0 = rf(ctx, objectID, offset, length)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*s3.FileData)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, int64, int64) error); ok {
+		r1 = rf(ctx, objectID, offset, length)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ClientMock_GetRange_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetRange'
+type ClientMock_GetRange_Call struct {
+	*mock.Call
+}
+
+// GetRange is a helper method to define mock.On call
+//   - ctx context.Context
+//   - objectID string
+//   - offset int64
+//   - length int64
+func (_e *ClientMock_Expecter) GetRange(ctx interface{}, objectID interface{}, offset interface{}, length interface{}) *ClientMock_GetRange_Call {
+	return &ClientMock_GetRange_Call{Call: _e.mock.On("GetRange", ctx, objectID, offset, length)}
+}
+
+func (_c *ClientMock_GetRange_Call) Run(run func(ctx context.Context, objectID string, offset int64, length int64)) *ClientMock_GetRange_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(int64), args[3].(int64))
+	})
+	return _c
+}
+
+func (_c *ClientMock_GetRange_Call) Return(_a0 *s3.FileData, _a1 error) *ClientMock_GetRange_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *ClientMock_GetRange_Call) RunAndReturn(run func(context.Context, string, int64, int64) (*s3.FileData, error)) *ClientMock_GetRange_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// InitiateUpload provides a mock function with given fields: ctx, objectID, contentType, metadata
+func (_m *ClientMock) InitiateUpload(ctx context.Context, objectID string, contentType string, metadata map[string]string) (string, error) {
+	ret := _m.Called(ctx, objectID, contentType, metadata)
+
+	if len(ret) == 0 {
+		panic("no return value specified for InitiateUpload")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, map[string]string) (string, error)); ok {
+		return rf(ctx, objectID, contentType, metadata)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, map[string]string) string); ok {
+		r0 = rf(ctx, objectID, contentType, metadata)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, map[string]string) error); ok {
+		r1 = rf(ctx, objectID, contentType, metadata)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ClientMock_InitiateUpload_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'InitiateUpload'
+type ClientMock_InitiateUpload_Call struct {
+	*mock.Call
+}
+
+// InitiateUpload is a helper method to define mock.On call
+//   - ctx context.Context
+//   - objectID string
+//   - contentType string
+//   - metadata map[string]string
+func (_e *ClientMock_Expecter) InitiateUpload(ctx interface{}, objectID interface{}, contentType interface{}, metadata interface{}) *ClientMock_InitiateUpload_Call {
+	return &ClientMock_InitiateUpload_Call{Call: _e.mock.On("InitiateUpload", ctx, objectID, contentType, metadata)}
+}
+
+func (_c *ClientMock_InitiateUpload_Call) Run(run func(ctx context.Context, objectID string, contentType string, metadata map[string]string)) *ClientMock_InitiateUpload_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(string), args[3].(map[string]string))
+	})
+	return _c
+}
+
+func (_c *ClientMock_InitiateUpload_Call) Return(_a0 string, _a1 error) *ClientMock_InitiateUpload_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *ClientMock_InitiateUpload_Call) RunAndReturn(run func(context.Context, string, string, map[string]string) (string, error)) *ClientMock_InitiateUpload_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// List provides a mock function with given fields: ctx, prefix, cursor
+func (_m *ClientMock) List(ctx context.Context, prefix string, cursor string) (*s3.ListPage, error) {
+	ret := _m.Called(ctx, prefix, cursor)
+
+	if len(ret) == 0 {
+		panic("no return value specified for List")
+	}
+
+	var r0 *s3.ListPage
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (*s3.ListPage, error)); ok {
+		return rf(ctx, prefix, cursor)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *s3.ListPage); ok {
+		r0 = rf(ctx, prefix, cursor)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*s3.ListPage)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, prefix, cursor)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ClientMock_List_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'List'
+type ClientMock_List_Call struct {
+	*mock.Call
+}
+
+// List is a helper method to define mock.On call
+//   - ctx context.Context
+//   - prefix string
+//   - cursor string
+func (_e *ClientMock_Expecter) List(ctx interface{}, prefix interface{}, cursor interface{}) *ClientMock_List_Call {
+	return &ClientMock_List_Call{Call: _e.mock.On("List", ctx, prefix, cursor)}
+}
+
+func (_c *ClientMock_List_Call) Run(run func(ctx context.Context, prefix string, cursor string)) *ClientMock_List_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(string))
+	})
+	return _c
+}
+
+func (_c *ClientMock_List_Call) Return(_a0 *s3.ListPage, _a1 error) *ClientMock_List_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *ClientMock_List_Call) RunAndReturn(run func(context.Context, string, string) (*s3.ListPage, error)) *ClientMock_List_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListParts provides a mock function with given fields: ctx, objectID, uploadID
+func (_m *ClientMock) ListParts(ctx context.Context, objectID string, uploadID string) ([]s3.Part, error) {
+	ret := _m.Called(ctx, objectID, uploadID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListParts")
+	}
+
+	var r0 []s3.Part
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) ([]s3.Part, error)); ok {
+		return rf(ctx, objectID, uploadID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) []s3.Part); ok {
+		r0 = rf(ctx, objectID, uploadID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]s3.Part)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, objectID, uploadID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ClientMock_ListParts_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListParts'
+type ClientMock_ListParts_Call struct {
+	*mock.Call
+}
+
+// ListParts is a helper method to define mock.On call
+//   - ctx context.Context
+//   - objectID string
+//   - uploadID string
+func (_e *ClientMock_Expecter) ListParts(ctx interface{}, objectID interface{}, uploadID interface{}) *ClientMock_ListParts_Call {
+	return &ClientMock_ListParts_Call{Call: _e.mock.On("ListParts", ctx, objectID, uploadID)}
+}
+
+func (_c *ClientMock_ListParts_Call) Run(run func(ctx context.Context, objectID string, uploadID string)) *ClientMock_ListParts_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(string))
+	})
+	return _c
+}
+
+func (_c *ClientMock_ListParts_Call) Return(_a0 []s3.Part, _a1 error) *ClientMock_ListParts_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *ClientMock_ListParts_Call) RunAndReturn(run func(context.Context, string, string) ([]s3.Part, error)) *ClientMock_ListParts_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Move provides a mock function with given fields: ctx, srcObjectID, dstObjectID
+func (_m *ClientMock) Move(ctx context.Context, srcObjectID string, dstObjectID string) error {
+	ret := _m.Called(ctx, srcObjectID, dstObjectID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Move")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, srcObjectID, dstObjectID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ClientMock_Move_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Move'
+type ClientMock_Move_Call struct {
+	*mock.Call
+}
+
+// Move is a helper method to define mock.On call
+//   - ctx context.Context
+//   - srcObjectID string
+//   - dstObjectID string
+func (_e *ClientMock_Expecter) Move(ctx interface{}, srcObjectID interface{}, dstObjectID interface{}) *ClientMock_Move_Call {
+	return &ClientMock_Move_Call{Call: _e.mock.On("Move", ctx, srcObjectID, dstObjectID)}
+}
+
+func (_c *ClientMock_Move_Call) Run(run func(ctx context.Context, srcObjectID string, dstObjectID string)) *ClientMock_Move_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(string))
+	})
+	return _c
+}
+
+func (_c *ClientMock_Move_Call) Return(_a0 error) *ClientMock_Move_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *ClientMock_Move_Call) RunAndReturn(run func(context.Context, string, string) error) *ClientMock_Move_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Prefix provides a mock function with given fields: prefix
+func (_m *ClientMock) Prefix(prefix string) s3.Client {
+	ret := _m.Called(prefix)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Prefix")
+	}
+
+	var r0 s3.Client
+	if rf, ok := ret.Get(0).(func(string) s3.Client); ok {
+		r0 = rf(prefix)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(s3.Client)
+		}
+	}
+
+	return r0
+}
+
+// ClientMock_Prefix_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Prefix'
+type ClientMock_Prefix_Call struct {
+	*mock.Call
+}
+
+// Prefix is a helper method to define mock.On call
+//   - prefix string
+func (_e *ClientMock_Expecter) Prefix(prefix interface{}) *ClientMock_Prefix_Call {
+	return &ClientMock_Prefix_Call{Call: _e.mock.On("Prefix", prefix)}
+}
+
+func (_c *ClientMock_Prefix_Call) Run(run func(prefix string)) *ClientMock_Prefix_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string))
+	})
+	return _c
+}
+
+func (_c *ClientMock_Prefix_Call) Return(_a0 s3.Client) *ClientMock_Prefix_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *ClientMock_Prefix_Call) RunAndReturn(run func(string) s3.Client) *ClientMock_Prefix_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// PresignGet provides a mock function with given fields: ctx, objectID, ttl
+func (_m *ClientMock) PresignGet(ctx context.Context, objectID string, ttl time.Duration) (*s3.PresignedRequest, error) {
+	ret := _m.Called(ctx, objectID, ttl)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PresignGet")
+	}
+
+	var r0 *s3.PresignedRequest
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Duration) (*s3.PresignedRequest, error)); ok {
+		return rf(ctx, objectID, ttl)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Duration) *s3.PresignedRequest); ok {
+		r0 = rf(ctx, objectID, ttl)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*s3.PresignedRequest)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, time.Duration) error); ok {
+		r1 = rf(ctx, objectID, ttl)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ClientMock_PresignGet_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'PresignGet'
+type ClientMock_PresignGet_Call struct {
+	*mock.Call
+}
+
+// PresignGet is a helper method to define mock.On call
+//   - ctx context.Context
+//   - objectID string
+//   - ttl time.Duration
+func (_e *ClientMock_Expecter) PresignGet(ctx interface{}, objectID interface{}, ttl interface{}) *ClientMock_PresignGet_Call {
+	return &ClientMock_PresignGet_Call{Call: _e.mock.On("PresignGet", ctx, objectID, ttl)}
+}
+
+func (_c *ClientMock_PresignGet_Call) Run(run func(ctx context.Context, objectID string, ttl time.Duration)) *ClientMock_PresignGet_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(time.Duration))
+	})
+	return _c
+}
+
+func (_c *ClientMock_PresignGet_Call) Return(_a0 *s3.PresignedRequest, _a1 error) *ClientMock_PresignGet_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *ClientMock_PresignGet_Call) RunAndReturn(run func(context.Context, string, time.Duration) (*s3.PresignedRequest, error)) *ClientMock_PresignGet_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// PresignPost provides a mock function with given fields: ctx, objectID, ttl, policy
+func (_m *ClientMock) PresignPost(ctx context.Context, objectID string, ttl time.Duration, policy s3.PostPolicy) (*s3.PresignedPost, error) {
+	ret := _m.Called(ctx, objectID, ttl, policy)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PresignPost")
+	}
+
+	var r0 *s3.PresignedPost
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Duration, s3.PostPolicy) (*s3.PresignedPost, error)); ok {
+		return rf(ctx, objectID, ttl, policy)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Duration, s3.PostPolicy) *s3.PresignedPost); ok {
+		r0 = rf(ctx, objectID, ttl, policy)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*s3.PresignedPost)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, time.Duration, s3.PostPolicy) error); ok {
+		r1 = rf(ctx, objectID, ttl, policy)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ClientMock_PresignPost_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'PresignPost'
+type ClientMock_PresignPost_Call struct {
+	*mock.Call
+}
+
+// PresignPost is a helper method to define mock.On call
+//   - ctx context.Context
+//   - objectID string
+//   - ttl time.Duration
+//   - policy s3.PostPolicy
+func (_e *ClientMock_Expecter) PresignPost(ctx interface{}, objectID interface{}, ttl interface{}, policy interface{}) *ClientMock_PresignPost_Call {
+	return &ClientMock_PresignPost_Call{Call: _e.mock.On("PresignPost", ctx, objectID, ttl, policy)}
+}
+
+func (_c *ClientMock_PresignPost_Call) Run(run func(ctx context.Context, objectID string, ttl time.Duration, policy s3.PostPolicy)) *ClientMock_PresignPost_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(time.Duration), args[3].(s3.PostPolicy))
+	})
+	return _c
+}
+
+func (_c *ClientMock_PresignPost_Call) Return(_a0 *s3.PresignedPost, _a1 error) *ClientMock_PresignPost_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *ClientMock_PresignPost_Call) RunAndReturn(run func(context.Context, string, time.Duration, s3.PostPolicy) (*s3.PresignedPost, error)) *ClientMock_PresignPost_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// PresignPut provides a mock function with given fields: ctx, objectID, ttl, contentType, maxSize
+func (_m *ClientMock) PresignPut(ctx context.Context, objectID string, ttl time.Duration, contentType string, maxSize int64) (*s3.PresignedRequest, error) {
+	ret := _m.Called(ctx, objectID, ttl, contentType, maxSize)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PresignPut")
+	}
+
+	var r0 *s3.PresignedRequest
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Duration, string, int64) (*s3.PresignedRequest, error)); ok {
+		return rf(ctx, objectID, ttl, contentType, maxSize)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Duration, string, int64) *s3.PresignedRequest); ok {
+		r0 = rf(ctx, objectID, ttl, contentType, maxSize)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*s3.PresignedRequest)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, time.Duration, string, int64) error); ok {
+		r1 = rf(ctx, objectID, ttl, contentType, maxSize)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ClientMock_PresignPut_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'PresignPut'
+type ClientMock_PresignPut_Call struct {
+	*mock.Call
+}
+
+// PresignPut is a helper method to define mock.On call
+//   - ctx context.Context
+//   - objectID string
+//   - ttl time.Duration
+//   - contentType string
+//   - maxSize int64
+func (_e *ClientMock_Expecter) PresignPut(ctx interface{}, objectID interface{}, ttl interface{}, contentType interface{}, maxSize interface{}) *ClientMock_PresignPut_Call {
+	return &ClientMock_PresignPut_Call{Call: _e.mock.On("PresignPut", ctx, objectID, ttl, contentType, maxSize)}
+}
+
+func (_c *ClientMock_PresignPut_Call) Run(run func(ctx context.Context, objectID string, ttl time.Duration, contentType string, maxSize int64)) *ClientMock_PresignPut_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(time.Duration), args[3].(string), args[4].(int64))
+	})
+	return _c
+}
+
+func (_c *ClientMock_PresignPut_Call) Return(_a0 *s3.PresignedRequest, _a1 error) *ClientMock_PresignPut_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *ClientMock_PresignPut_Call) RunAndReturn(run func(context.Context, string, time.Duration, string, int64) (*s3.PresignedRequest, error)) *ClientMock_PresignPut_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Stat provides a mock function with given fields: ctx, objectID
+func (_m *ClientMock) Stat(ctx context.Context, objectID string) (*s3.ObjectInfo, error) {
+	ret := _m.Called(ctx, objectID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Stat")
+	}
+
+	var r0 *s3.ObjectInfo
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*s3.ObjectInfo, error)); ok {
+		return rf(ctx, objectID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *s3.ObjectInfo); ok {
+		r0 = rf(ctx, objectID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*s3.ObjectInfo)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, objectID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ClientMock_Stat_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Stat'
+type ClientMock_Stat_Call struct {
+	*mock.Call
+}
+
+// Stat is a helper method to define mock.On call
+//   - ctx context.Context
+//   - objectID string
+func (_e *ClientMock_Expecter) Stat(ctx interface{}, objectID interface{}) *ClientMock_Stat_Call {
+	return &ClientMock_Stat_Call{Call: _e.mock.On("Stat", ctx, objectID)}
+}
+
+func (_c *ClientMock_Stat_Call) Run(run func(ctx context.Context, objectID string)) *ClientMock_Stat_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *ClientMock_Stat_Call) Return(_a0 *s3.ObjectInfo, _a1 error) *ClientMock_Stat_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *ClientMock_Stat_Call) RunAndReturn(run func(context.Context, string) (*s3.ObjectInfo, error)) *ClientMock_Stat_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// UpdateMetadata provides a mock function with given fields: ctx, objectID, metadata
+func (_m *ClientMock) UpdateMetadata(ctx context.Context, objectID string, metadata map[string]string) error {
+	ret := _m.Called(ctx, objectID, metadata)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateMetadata")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, map[string]string) error); ok {
+		r0 = rf(ctx, objectID, metadata)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ClientMock_UpdateMetadata_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UpdateMetadata'
+type ClientMock_UpdateMetadata_Call struct {
+	*mock.Call
+}
+
+// UpdateMetadata is a helper method to define mock.On call
+//   - ctx context.Context
+//   - objectID string
+//   - metadata map[string]string
+func (_e *ClientMock_Expecter) UpdateMetadata(ctx interface{}, objectID interface{}, metadata interface{}) *ClientMock_UpdateMetadata_Call {
+	return &ClientMock_UpdateMetadata_Call{Call: _e.mock.On("UpdateMetadata", ctx, objectID, metadata)}
+}
+
+func (_c *ClientMock_UpdateMetadata_Call) Run(run func(ctx context.Context, objectID string, metadata map[string]string)) *ClientMock_UpdateMetadata_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(map[string]string))
+	})
+	return _c
+}
+
+func (_c *ClientMock_UpdateMetadata_Call) Return(_a0 error) *ClientMock_UpdateMetadata_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *ClientMock_UpdateMetadata_Call) RunAndReturn(run func(context.Context, string, map[string]string) error) *ClientMock_UpdateMetadata_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// UploadPart provides a mock function with given fields: ctx, objectID, uploadID, number, r, size
+func (_m *ClientMock) UploadPart(ctx context.Context, objectID string, uploadID string, number int, r io.Reader, size int64) (*s3.Part, error) {
+	ret := _m.Called(ctx, objectID, uploadID, number, r, size)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UploadPart")
+	}
+
+	var r0 *s3.Part
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, int, io.Reader, int64) (*s3.Part, error)); ok {
+		return rf(ctx, objectID, uploadID, number, r, size)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, int, io.Reader, int64) *s3.Part); ok {
+		r0 = rf(ctx, objectID, uploadID, number, r, size)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*s3.Part)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, int, io.Reader, int64) error); ok {
+		r1 = rf(ctx, objectID, uploadID, number, r, size)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ClientMock_UploadPart_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UploadPart'
+type ClientMock_UploadPart_Call struct {
+	*mock.Call
+}
+
+// UploadPart is a helper method to define mock.On call
+//   - ctx context.Context
+//   - objectID string
+//   - uploadID string
+//   - number int
+//   - r io.Reader
+//   - size int64
+func (_e *ClientMock_Expecter) UploadPart(ctx interface{}, objectID interface{}, uploadID interface{}, number interface{}, r interface{}, size interface{}) *ClientMock_UploadPart_Call {
+	return &ClientMock_UploadPart_Call{Call: _e.mock.On("UploadPart", ctx, objectID, uploadID, number, r, size)}
+}
+
+func (_c *ClientMock_UploadPart_Call) Run(run func(ctx context.Context, objectID string, uploadID string, number int, r io.Reader, size int64)) *ClientMock_UploadPart_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(string), args[3].(int), args[4].(io.Reader), args[5].(int64))
+	})
+	return _c
+}
+
+func (_c *ClientMock_UploadPart_Call) Return(_a0 *s3.Part, _a1 error) *ClientMock_UploadPart_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *ClientMock_UploadPart_Call) RunAndReturn(run func(context.Context, string, string, int, io.Reader, int64) (*s3.Part, error)) *ClientMock_UploadPart_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // NewClientMock creates a new instance of ClientMock. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewClientMock(t interface {
@@ -0,0 +1,76 @@
+package s3
+
+import (
+	"strings"
+)
+
+// The helpers of the storage implementations, so they behave the same way as S3.
+
+const (
+	UserMetadataPrefix = "x-amz-meta-"
+	DefaultContentType = "application/octet-stream"
+)
+
+// ValidateKey rejects the keys that can't be mapped to a path: empty, with empty, "." or ".."
+// segments or with backslashes. The storages treat such objects as missing.
+func ValidateKey(key string) error {
+	if key == "" {
+		return ErrInvalidObjectID
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." || strings.ContainsRune(segment, '\\') {
+			return ErrInvalidObjectID
+		}
+	}
+	return nil
+}
+
+// RangeBounds returns the bounds [start, end) of the range of the object like S3 does.
+func RangeBounds(size int64, offset int64, length int64) (int64, int64, error) {
+	if offset < 0 || length == 0 || (offset >= size && size > 0) || (size == 0 && offset > 0) {
+		return 0, 0, ErrInvalidRange
+	}
+
+	end := size
+	if length > 0 && offset+length < size {
+		end = offset + length
+	}
+	return offset, end, nil
+}
+
+// ContentTypeOrDefault returns the content type S3 stores for the declared one.
+func ContentTypeOrDefault(value string) string {
+	if value == "" {
+		return DefaultContentType
+	}
+	return value
+}
+
+// NormalizeMetadata returns the metadata with the keys as they are returned by S3.
+func NormalizeMetadata(metadata map[string]string) map[string]string {
+	if len(metadata) == 0 {
+		return nil
+	}
+
+	normalized := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		k = strings.ToLower(k)
+		if !strings.HasPrefix(k, UserMetadataPrefix) {
+			k = UserMetadataPrefix + k
+		}
+		normalized[k] = v
+	}
+	return normalized
+}
+
+func CopyMetadata(metadata map[string]string) map[string]string {
+	if metadata == nil {
+		return nil
+	}
+
+	cp := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		cp[k] = v
+	}
+	return cp
+}
@@ -0,0 +1,361 @@
+// Package s3test checks that the storage implementations behave like S3.
+package s3test
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"github.com/mandarine-io/baselib/pkg/storage/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+)
+
+// listPageSize is the page size of S3 listing.
+const listPageSize = 1000
+
+// RunConformance runs the tests of the storage behaviour. newClient returns the client of the empty bucket.
+func RunConformance(t *testing.T, newClient func(t *testing.T) s3.Client) {
+	t.Run("CreateAndGet", func(t *testing.T) { testCreateAndGet(t, newClient(t)) })
+	t.Run("InvalidKeys", func(t *testing.T) { testInvalidKeys(t, newClient(t)) })
+	t.Run("Prefix", func(t *testing.T) { testPrefix(t, newClient(t)) })
+	t.Run("ListPaging", func(t *testing.T) { testListPaging(t, newClient(t)) })
+	t.Run("GetRange", func(t *testing.T) { testGetRange(t, newClient(t)) })
+	t.Run("Multipart", func(t *testing.T) { testMultipart(t, newClient(t)) })
+	t.Run("MultipartRules", func(t *testing.T) { testMultipartRules(t, newClient(t)) })
+	t.Run("CopyMove", func(t *testing.T) { testCopyMove(t, newClient(t)) })
+	t.Run("UpdateMetadata", func(t *testing.T) { testUpdateMetadata(t, newClient(t)) })
+}
+
+func testCreateAndGet(t *testing.T, c s3.Client) {
+	ctx := context.Background()
+	create(t, c, "dir/a.txt", "hello", "", map[string]string{"Owner": "me"})
+
+	data := read(t, c, "dir/a.txt")
+	assert.Equal(t, "hello", data)
+
+	info, err := c.Stat(ctx, "dir/a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "dir/a.txt", info.ID)
+	assert.EqualValues(t, 5, info.Size)
+	assert.Equal(t, s3.DefaultContentType, info.ContentType)
+	assert.Equal(t, md5Hex("hello"), info.ETag)
+	assert.Equal(t, map[string]string{"x-amz-meta-owner": "me"}, info.UserMetadata)
+
+	exists, err := c.Exists(ctx, "dir/a.txt")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	require.NoError(t, c.DeleteOne(ctx, "dir/a.txt"))
+	require.NoError(t, c.DeleteOne(ctx, "dir/a.txt"))
+
+	exists, err = c.Exists(ctx, "dir/a.txt")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	assert.ErrorIs(t, c.GetOne(ctx, "dir/a.txt").Error, s3.ErrObjectNotFound)
+	_, err = c.Stat(ctx, "dir/a.txt")
+	assert.ErrorIs(t, err, s3.ErrObjectNotFound)
+}
+
+func testInvalidKeys(t *testing.T, c s3.Client) {
+	ctx := context.Background()
+	create(t, c, "a", "data", "", nil)
+
+	for _, id := range []string{"", "a//b", "./a", "../a", "a/..", `a\b`} {
+		dto := c.CreateOne(ctx, &s3.FileData{ID: id, Size: 1, Reader: io.NopCloser(bytes.NewReader([]byte("x")))})
+		assert.ErrorIs(t, dto.Error, s3.ErrInvalidObjectID, id)
+
+		assert.ErrorIs(t, c.GetOne(ctx, id).Error, s3.ErrObjectNotFound, id)
+		_, err := c.Stat(ctx, id)
+		assert.ErrorIs(t, err, s3.ErrObjectNotFound, id)
+		exists, err := c.Exists(ctx, id)
+		assert.NoError(t, err, id)
+		assert.False(t, exists, id)
+		_, err = c.GetRange(ctx, id, 0, -1)
+		assert.ErrorIs(t, err, s3.ErrObjectNotFound, id)
+		assert.NoError(t, c.DeleteOne(ctx, id), id)
+		assert.ErrorIs(t, c.UpdateMetadata(ctx, id, nil), s3.ErrObjectNotFound, id)
+		assert.ErrorIs(t, c.Copy(ctx, id, "b"), s3.ErrObjectNotFound, id)
+		assert.ErrorIs(t, c.Copy(ctx, "a", id), s3.ErrInvalidObjectID, id)
+		assert.ErrorIs(t, c.Move(ctx, id, "b"), s3.ErrObjectNotFound, id)
+		assert.ErrorIs(t, c.Move(ctx, "a", id), s3.ErrInvalidObjectID, id)
+		_, err = c.InitiateUpload(ctx, id, "", nil)
+		assert.ErrorIs(t, err, s3.ErrInvalidObjectID, id)
+	}
+
+	assert.Equal(t, "data", read(t, c, "a"))
+}
+
+func testPrefix(t *testing.T, c s3.Client) {
+	ctx := context.Background()
+	create(t, c.Prefix("/p/"), "x", "data", "", nil)
+
+	assert.Equal(t, "data", read(t, c, "p/x"))
+	assert.Equal(t, "data", read(t, c.Prefix("p"), "x"))
+
+	page, err := c.Prefix("p").List(ctx, "", "")
+	require.NoError(t, err)
+	require.Len(t, page.Objects, 1)
+	assert.Equal(t, "x", page.Objects[0].ID)
+}
+
+func testListPaging(t *testing.T, c s3.Client) {
+	ctx := context.Background()
+
+	n := listPageSize + 1
+	for i := 0; i < n; i++ {
+		create(t, c, fmt.Sprintf("list/%04d", i), "x", "", map[string]string{"k": "v"})
+	}
+	create(t, c, "other", "x", "", nil)
+
+	page, err := c.List(ctx, "list/", "")
+	require.NoError(t, err)
+	require.Len(t, page.Objects, listPageSize)
+	assert.Equal(t, "list/0000", page.Objects[0].ID)
+	assert.Equal(t, fmt.Sprintf("list/%04d", listPageSize-1), page.NextCursor)
+	assert.Nil(t, page.Objects[0].UserMetadata)
+	assert.EqualValues(t, 1, page.Objects[0].Size)
+	assert.Equal(t, md5Hex("x"), page.Objects[0].ETag)
+
+	page, err = c.List(ctx, "list/", page.NextCursor)
+	require.NoError(t, err)
+	require.Len(t, page.Objects, 1)
+	assert.Equal(t, fmt.Sprintf("list/%04d", n-1), page.Objects[0].ID)
+	assert.Empty(t, page.NextCursor)
+
+	page, err = c.List(ctx, "list/", fmt.Sprintf("list/%04d", n-1))
+	require.NoError(t, err)
+	assert.Empty(t, page.Objects)
+	assert.Empty(t, page.NextCursor)
+
+	page, err = c.List(ctx, "missing/", "")
+	require.NoError(t, err)
+	assert.Empty(t, page.Objects)
+
+	count := 0
+	require.NoError(t, s3.Walk(ctx, c, "", func(s3.ObjectInfo) error {
+		count++
+		return nil
+	}))
+	assert.Equal(t, n+1, count)
+}
+
+func testGetRange(t *testing.T, c s3.Client) {
+	ctx := context.Background()
+	create(t, c, "digits", "0123456789", "text/plain", nil)
+	create(t, c, "empty", "", "", nil)
+
+	tests := []struct {
+		name   string
+		id     string
+		offset int64
+		length int64
+		want   string
+		err    error
+	}{
+		{name: "whole", id: "digits", offset: 0, length: -1, want: "0123456789"},
+		{name: "middle", id: "digits", offset: 3, length: 4, want: "3456"},
+		{name: "to the end", id: "digits", offset: 7, length: -1, want: "789"},
+		{name: "last byte", id: "digits", offset: 9, length: 1, want: "9"},
+		{name: "truncated", id: "digits", offset: 8, length: 100, want: "89"},
+		{name: "offset at the end", id: "digits", offset: 10, length: 1, err: s3.ErrInvalidRange},
+		{name: "negative offset", id: "digits", offset: -1, length: 1, err: s3.ErrInvalidRange},
+		{name: "zero length", id: "digits", offset: 0, length: 0, err: s3.ErrInvalidRange},
+		{name: "empty object", id: "empty", offset: 0, length: -1, want: ""},
+		{name: "beyond empty object", id: "empty", offset: 1, length: -1, err: s3.ErrInvalidRange},
+		{name: "missing object", id: "missing", offset: 0, length: -1, err: s3.ErrObjectNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := c.GetRange(ctx, tt.id, tt.offset, tt.length)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			defer file.Reader.Close()
+
+			data, err := io.ReadAll(file.Reader)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(data))
+			assert.EqualValues(t, len(tt.want), file.Size)
+		})
+	}
+}
+
+func testMultipart(t *testing.T, c s3.Client) {
+	ctx := context.Background()
+
+	uploadID, err := c.InitiateUpload(ctx, "big", "text/plain", map[string]string{"K": "v"})
+	require.NoError(t, err)
+
+	first := bytes.Repeat([]byte("a"), s3.MinPartSize)
+	// Parts are uploaded in any order and the part with the same number replaces the previous one
+	_, err = c.UploadPart(ctx, "big", uploadID, 2, bytes.NewReader([]byte("old")), 3)
+	require.NoError(t, err)
+	part2, err := c.UploadPart(ctx, "big", uploadID, 2, bytes.NewReader([]byte("tail")), 4)
+	require.NoError(t, err)
+	part1, err := c.UploadPart(ctx, "big", uploadID, 1, bytes.NewReader(first), int64(len(first)))
+	require.NoError(t, err)
+	assert.Equal(t, md5Hex(string(first)), part1.ETag)
+
+	parts, err := c.ListParts(ctx, "big", uploadID)
+	require.NoError(t, err)
+	assert.Equal(t, []s3.Part{*part1, *part2}, parts)
+
+	_, err = c.ListParts(ctx, "other", uploadID)
+	assert.ErrorIs(t, err, s3.ErrUploadNotFound)
+
+	require.NoError(t, c.CompleteUpload(ctx, "big", uploadID, []s3.Part{*part2, *part1}))
+
+	info, err := c.Stat(ctx, "big")
+	require.NoError(t, err)
+	assert.EqualValues(t, len(first)+4, info.Size)
+	assert.Equal(t, "text/plain", info.ContentType)
+	assert.Equal(t, map[string]string{"x-amz-meta-k": "v"}, info.UserMetadata)
+
+	sum1, _ := hex.DecodeString(part1.ETag)
+	sum2, _ := hex.DecodeString(part2.ETag)
+	assert.Equal(t, md5Hex(string(sum1)+string(sum2))+"-2", info.ETag)
+
+	file, err := c.GetRange(ctx, "big", int64(len(first))-1, -1)
+	require.NoError(t, err)
+	defer file.Reader.Close()
+	data, err := io.ReadAll(file.Reader)
+	require.NoError(t, err)
+	assert.Equal(t, "atail", string(data))
+
+	_, err = c.ListParts(ctx, "big", uploadID)
+	assert.ErrorIs(t, err, s3.ErrUploadNotFound)
+}
+
+func testMultipartRules(t *testing.T, c s3.Client) {
+	ctx := context.Background()
+
+	uploadID, err := c.InitiateUpload(ctx, "obj", "", nil)
+	require.NoError(t, err)
+
+	for _, number := range []int{0, s3.MaxPartNumber + 1} {
+		_, err = c.UploadPart(ctx, "obj", uploadID, number, bytes.NewReader([]byte("x")), 1)
+		assert.ErrorIs(t, err, s3.ErrInvalidPart, number)
+	}
+	_, err = c.UploadPart(ctx, "obj", "missing", 1, bytes.NewReader([]byte("x")), 1)
+	assert.Error(t, err)
+
+	part1, err := c.UploadPart(ctx, "obj", uploadID, 1, bytes.NewReader([]byte("small")), 5)
+	require.NoError(t, err)
+	part2, err := c.UploadPart(ctx, "obj", uploadID, 2, bytes.NewReader([]byte("last")), 4)
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, c.CompleteUpload(ctx, "obj", uploadID, nil), s3.ErrInvalidPart)
+	assert.ErrorIs(t, c.CompleteUpload(ctx, "obj", uploadID, []s3.Part{*part1, *part2}), s3.ErrPartTooSmall)
+	assert.ErrorIs(t, c.CompleteUpload(ctx, "obj", uploadID, []s3.Part{{Number: 1, ETag: md5Hex("other")}}), s3.ErrInvalidPart)
+	assert.ErrorIs(t, c.CompleteUpload(ctx, "obj", uploadID, []s3.Part{{Number: 3, ETag: part2.ETag}}), s3.ErrInvalidPart)
+
+	// The single part may be smaller than the minimum and the quoted etag is accepted
+	quoted := s3.Part{Number: 2, ETag: `"` + part2.ETag + `"`}
+	require.NoError(t, c.CompleteUpload(ctx, "obj", uploadID, []s3.Part{quoted}))
+	assert.Equal(t, "last", read(t, c, "obj"))
+
+	uploadID, err = c.InitiateUpload(ctx, "aborted", "", nil)
+	require.NoError(t, err)
+	_, err = c.UploadPart(ctx, "aborted", uploadID, 1, bytes.NewReader([]byte("x")), 1)
+	require.NoError(t, err)
+	require.NoError(t, c.AbortUpload(ctx, "aborted", uploadID))
+	assert.ErrorIs(t, c.AbortUpload(ctx, "aborted", uploadID), s3.ErrUploadNotFound)
+	_, err = c.ListParts(ctx, "aborted", uploadID)
+	assert.ErrorIs(t, err, s3.ErrUploadNotFound)
+
+	exists, err := c.Exists(ctx, "aborted")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func testCopyMove(t *testing.T, c s3.Client) {
+	ctx := context.Background()
+	create(t, c, "src", "data", "text/plain", map[string]string{"k": "v"})
+
+	require.NoError(t, c.Copy(ctx, "src", "copy"))
+	assert.Equal(t, "data", read(t, c, "src"))
+	assert.Equal(t, "data", read(t, c, "copy"))
+
+	info, err := c.Stat(ctx, "copy")
+	require.NoError(t, err)
+	assert.Equal(t, "text/plain", info.ContentType)
+	assert.Equal(t, md5Hex("data"), info.ETag)
+	assert.Equal(t, map[string]string{"x-amz-meta-k": "v"}, info.UserMetadata)
+
+	require.NoError(t, c.Move(ctx, "copy", "moved"))
+	assert.Equal(t, "data", read(t, c, "moved"))
+	exists, err := c.Exists(ctx, "copy")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	info, err = c.Stat(ctx, "moved")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"x-amz-meta-k": "v"}, info.UserMetadata)
+
+	require.NoError(t, c.Move(ctx, "moved", "moved"))
+	assert.Equal(t, "data", read(t, c, "moved"))
+
+	create(t, c, "dst", "old", "", nil)
+	require.NoError(t, c.Copy(ctx, "src", "dst"))
+	assert.Equal(t, "data", read(t, c, "dst"))
+
+	assert.ErrorIs(t, c.Copy(ctx, "missing", "dst"), s3.ErrObjectNotFound)
+	assert.ErrorIs(t, c.Move(ctx, "missing", "dst"), s3.ErrObjectNotFound)
+	assert.Equal(t, "data", read(t, c, "dst"))
+}
+
+func testUpdateMetadata(t *testing.T, c s3.Client) {
+	ctx := context.Background()
+	create(t, c, "obj", "data", "text/plain", map[string]string{"old": "1"})
+
+	require.NoError(t, c.UpdateMetadata(ctx, "obj", map[string]string{"New": "2", "x-amz-meta-other": "3"}))
+
+	info, err := c.Stat(ctx, "obj")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"x-amz-meta-new": "2", "x-amz-meta-other": "3"}, info.UserMetadata)
+	assert.Equal(t, "text/plain", info.ContentType)
+	assert.Equal(t, "data", read(t, c, "obj"))
+
+	require.NoError(t, c.UpdateMetadata(ctx, "obj", nil))
+	info, err = c.Stat(ctx, "obj")
+	require.NoError(t, err)
+	assert.Empty(t, info.UserMetadata)
+
+	assert.ErrorIs(t, c.UpdateMetadata(ctx, "missing", nil), s3.ErrObjectNotFound)
+}
+
+func create(t *testing.T, c s3.Client, id string, data string, contentType string, metadata map[string]string) {
+	t.Helper()
+
+	dto := c.CreateOne(context.Background(), &s3.FileData{
+		ID:           id,
+		Size:         int64(len(data)),
+		ContentType:  contentType,
+		Reader:       io.NopCloser(bytes.NewReader([]byte(data))),
+		UserMetadata: metadata,
+	})
+	require.NoError(t, dto.Error)
+}
+
+func read(t *testing.T, c s3.Client, id string) string {
+	t.Helper()
+
+	dto := c.GetOne(context.Background(), id)
+	require.NoError(t, dto.Error)
+	defer dto.Data.Reader.Close()
+
+	data, err := io.ReadAll(dto.Data.Reader)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func md5Hex(data string) string {
+	sum := md5.Sum([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,117 @@
+package clamav
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"github.com/mandarine-io/baselib/pkg/storage/s3/upload"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout   = time.Minute
+	defaultChunkSize = 64 * 1024
+)
+
+type Config struct {
+	// Network is "tcp" or "unix". Defaults to "tcp".
+	Network string
+	// Address is the address of clamd, e.g. "localhost:3310" or "/var/run/clamav/clamd.ctl".
+	Address string
+	// Timeout limits the whole scan. Defaults to 1 minute.
+	Timeout time.Duration
+}
+
+type scanner struct {
+	cfg  Config
+	dial func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// NewScanner creates the scanner sending the content to clamd with the INSTREAM command.
+// The content must not exceed StreamMaxLength of clamd.
+func NewScanner(cfg Config) upload.Scanner {
+	if cfg.Network == "" {
+		cfg.Network = "tcp"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	var dialer net.Dialer
+	return &scanner{cfg: cfg, dial: dialer.DialContext}
+}
+
+func (s *scanner) Scan(ctx context.Context, r io.Reader) error {
+	log.Debug().Msg("scan content with clamav")
+
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+
+	conn, err := s.dial(ctx, s.cfg.Network, s.cfg.Address)
+	if err != nil {
+		return errors.Wrap(err, "failed to connect to clamd")
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(conn)
+	buf := make([]byte, defaultChunkSize)
+	size := make([]byte, 4)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := w.Write(size); err != nil {
+				return err
+			}
+			if _, err := w.Write(buf[:n]); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// The zero-length chunk terminates the stream
+	binary.BigEndian.PutUint32(size, 0)
+	if _, err := w.Write(size); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return parseReply(strings.TrimRight(reply, "\x00\n"))
+}
+
+// parseReply parses "stream: OK", "stream: <signature> FOUND" or "<message> ERROR".
+func parseReply(reply string) error {
+	reply = strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case reply == "OK":
+		return nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return errors.Wrap(upload.ErrInfected, strings.TrimSuffix(reply, " FOUND"))
+	default:
+		return errors.Errorf("clamd error: %s", reply)
+	}
+}
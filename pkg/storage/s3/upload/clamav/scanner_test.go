@@ -0,0 +1,148 @@
+package clamav
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"github.com/mandarine-io/baselib/pkg/storage/s3/upload"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeClamd serves one INSTREAM command over the pipe and replies with reply.
+// The received content is sent to the returned channel.
+func fakeClamd(t *testing.T, s *scanner, reply string) <-chan []byte {
+	received := make(chan []byte, 1)
+	s.dial = func(context.Context, string, string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go func() {
+			defer server.Close()
+			data, err := readStream(server)
+			if err != nil {
+				t.Errorf("failed to read stream: %v", err)
+				return
+			}
+			received <- data
+			_, _ = server.Write([]byte(reply + "\x00"))
+		}()
+		return client, nil
+	}
+	return received
+}
+
+func readStream(conn net.Conn) ([]byte, error) {
+	cmd := make([]byte, len("zINSTREAM\x00"))
+	if _, err := io.ReadFull(conn, cmd); err != nil {
+		return nil, err
+	}
+	if string(cmd) != "zINSTREAM\x00" {
+		return nil, errors.Errorf("unexpected command %q", cmd)
+	}
+
+	var data []byte
+	size := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(conn, size); err != nil {
+			return nil, err
+		}
+		n := binary.BigEndian.Uint32(size)
+		if n == 0 {
+			return data, nil
+		}
+		chunk := make([]byte, n)
+		if _, err := io.ReadFull(conn, chunk); err != nil {
+			return nil, err
+		}
+		data = append(data, chunk...)
+	}
+}
+
+func TestScanner_Scan(t *testing.T) {
+	// Larger than the chunk size to send several chunks
+	content := bytes.Repeat([]byte("0123456789"), defaultChunkSize/5)
+
+	tests := []struct {
+		name    string
+		reply   string
+		check   func(t *testing.T, err error)
+		content []byte
+	}{
+		{
+			name:    "clean",
+			reply:   "stream: OK",
+			content: content,
+			check: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name:    "empty",
+			reply:   "stream: OK",
+			content: []byte{},
+			check: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name:    "infected",
+			reply:   "stream: Eicar-Signature FOUND",
+			content: []byte("EICAR"),
+			check: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, upload.ErrInfected)
+				assert.Contains(t, err.Error(), "Eicar-Signature")
+			},
+		},
+		{
+			name:    "error",
+			reply:   "INSTREAM size limit exceeded. ERROR",
+			content: content,
+			check: func(t *testing.T, err error) {
+				require.Error(t, err)
+				assert.NotErrorIs(t, err, upload.ErrInfected)
+				assert.Contains(t, err.Error(), "size limit exceeded")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScanner(Config{Address: "clamd:3310"}).(*scanner)
+			received := fakeClamd(t, s, tt.reply)
+
+			err := s.Scan(context.Background(), bytes.NewReader(tt.content))
+			tt.check(t, err)
+			assert.Equal(t, len(tt.content), len(<-received))
+		})
+	}
+}
+
+func TestScanner_ScanTimeout(t *testing.T) {
+	s := NewScanner(Config{Address: "clamd:3310", Timeout: 50 * time.Millisecond}).(*scanner)
+	s.dial = func(context.Context, string, string) (net.Conn, error) {
+		client, server := net.Pipe()
+		t.Cleanup(func() {
+			_ = server.Close()
+		})
+		// clamd never reads the stream
+		return client, nil
+	}
+
+	err := s.Scan(context.Background(), bytes.NewReader([]byte("content")))
+	var netErr net.Error
+	require.True(t, errors.As(err, &netErr))
+	assert.True(t, netErr.Timeout())
+}
+
+func TestScanner_ScanDialError(t *testing.T) {
+	s := NewScanner(Config{Address: "clamd:3310"}).(*scanner)
+	s.dial = func(context.Context, string, string) (net.Conn, error) {
+		return nil, errors.New("connection refused")
+	}
+
+	err := s.Scan(context.Background(), bytes.NewReader([]byte("content")))
+	assert.ErrorContains(t, err, "failed to connect to clamd")
+}
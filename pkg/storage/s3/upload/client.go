@@ -0,0 +1,268 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/mandarine-io/baselib/pkg/storage/s3"
+	"github.com/mandarine-io/baselib/pkg/transport/http/model"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	// ChecksumMetadata is the metadata key of the SHA-256 hex checksum of the object.
+	ChecksumMetadata = "x-amz-meta-sha256"
+
+	sniffLen = 512
+)
+
+var (
+	ErrFileTooLarge          = model.NewI18nError("file is too large", "errors.file_too_large")
+	ErrContentTypeNotAllowed = model.NewI18nError("content type is not allowed", "errors.content_type_not_allowed")
+	ErrContentTypeMismatch   = model.NewI18nError("content doesn't match content type", "errors.content_type_mismatch")
+	ErrInfected              = model.NewI18nError("file is infected", "errors.file_infected")
+)
+
+// Scanner checks the content before it's stored, e.g. with an antivirus.
+type Scanner interface {
+	// Scan returns the error wrapping ErrInfected if the content must be rejected.
+	Scan(ctx context.Context, r io.Reader) error
+}
+
+type Config struct {
+	// MaxSize is the maximum size of the object in bytes. If zero, the size is not limited.
+	MaxSize int64
+	// AllowedTypes is the list of allowed content types: exact ("image/png") or wildcards ("image/*").
+	// If empty, any content type is allowed.
+	AllowedTypes []string
+	// Scanner checks the content before upload. If nil, the content is not scanned.
+	Scanner Scanner
+	// TempDir is the directory of temporary files. If empty, the default one is used.
+	TempDir string
+}
+
+type client struct {
+	s3.Client
+	cfg Config
+}
+
+// NewClient wraps the client to validate uploads of CreateOne and CreateMany. The content is
+// spooled to a temporary file, so the object is stored only after the whole content is checked:
+//   - the size is limited by MaxSize;
+//   - the declared content type is checked against AllowedTypes and against the type sniffed
+//     from the first bytes. The sniffer knows few formats, so only the mismatching families are
+//     rejected, e.g. the binary declared as text. The declared type is stored, the sniffed one
+//     is used only if the type is not declared;
+//   - the SHA-256 checksum is stored in ChecksumMetadata;
+//   - the content is scanned by Scanner.
+//
+// Multipart and presigned uploads bypass the validation.
+func NewClient(s3Client s3.Client, cfg Config) s3.Client {
+	return &client{Client: s3Client, cfg: cfg}
+}
+
+func (c *client) Bucket(name string) s3.Client {
+	return &client{Client: c.Client.Bucket(name), cfg: c.cfg}
+}
+
+func (c *client) Prefix(prefix string) s3.Client {
+	return &client{Client: c.Client.Prefix(prefix), cfg: c.cfg}
+}
+
+func (c *client) CreateOne(ctx context.Context, file *s3.FileData) *s3.CreateDto {
+	log.Debug().Msg("validate and create one object")
+	if file == nil {
+		return &s3.CreateDto{Error: errors.New("file is nil")}
+	}
+
+	validated, cleanup, err := c.validate(ctx, file)
+	if err != nil {
+		return &s3.CreateDto{Error: err}
+	}
+	defer cleanup()
+
+	return c.Client.CreateOne(ctx, validated)
+}
+
+func (c *client) CreateMany(ctx context.Context, files []*s3.FileData, opts ...s3.BatchOption) ([]*s3.CreateDto, error) {
+	log.Debug().Msg("validate and create many object")
+	return s3.BatchCreate(ctx, files, c.CreateOne, opts...)
+}
+
+// validate spools the content to a temporary file checking it and returns the file data
+// reading from the temporary file.
+func (c *client) validate(ctx context.Context, file *s3.FileData) (*s3.FileData, func(), error) {
+	if c.cfg.MaxSize > 0 && file.Size > c.cfg.MaxSize {
+		return nil, nil, ErrFileTooLarge
+	}
+
+	var r io.Reader = file.Reader
+	if file.Size >= 0 {
+		r = io.LimitReader(r, file.Size)
+	}
+
+	// Sniff the content type before spooling to reject disallowed files early
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, nil, err
+	}
+	head = head[:n]
+
+	sniffed := http.DetectContentType(head)
+	contentType := file.ContentType
+	if contentType == "" {
+		contentType = sniffed
+	}
+	if !c.allowed(contentType) {
+		log.Debug().Msgf("content type %s is not allowed", contentType)
+		return nil, nil, ErrContentTypeNotAllowed
+	}
+	if !consistent(contentType, sniffed) {
+		log.Debug().Msgf("content type %s doesn't match sniffed %s", contentType, sniffed)
+		return nil, nil, ErrContentTypeMismatch
+	}
+
+	tmp, err := os.CreateTemp(c.cfg.TempDir, "upload-*")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}
+
+	hash := sha256.New()
+	src := io.MultiReader(bytes.NewReader(head), r)
+	if c.cfg.MaxSize > 0 {
+		src = io.LimitReader(src, c.cfg.MaxSize+1)
+	}
+	size, err := io.Copy(io.MultiWriter(tmp, hash), src)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	if c.cfg.MaxSize > 0 && size > c.cfg.MaxSize {
+		cleanup()
+		return nil, nil, ErrFileTooLarge
+	}
+	if file.Size >= 0 && size != file.Size {
+		cleanup()
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+
+	if c.cfg.Scanner != nil {
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+		if err := c.cfg.Scanner.Scan(ctx, tmp); err != nil {
+			log.Warn().Err(err).Msgf("upload of object %s is rejected by scanner", file.ID)
+			cleanup()
+			return nil, nil, err
+		}
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	metadata := make(map[string]string, len(file.UserMetadata)+1)
+	for k, v := range file.UserMetadata {
+		metadata[k] = v
+	}
+	metadata[ChecksumMetadata] = hex.EncodeToString(hash.Sum(nil))
+
+	return &s3.FileData{
+		ID:           file.ID,
+		Size:         size,
+		ContentType:  contentType,
+		Reader:       io.NopCloser(tmp),
+		UserMetadata: metadata,
+	}, cleanup, nil
+}
+
+func (c *client) allowed(contentType string) bool {
+	if len(c.cfg.AllowedTypes) == 0 {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, allowed := range c.cfg.AllowedTypes {
+		allowed = strings.ToLower(allowed)
+		if prefix, ok := strings.CutSuffix(allowed, "/*"); ok {
+			if strings.HasPrefix(mediaType, prefix+"/") {
+				return true
+			}
+			continue
+		}
+		if mediaType == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// consistent reports whether the content sniffed by http.DetectContentType may have the declared type.
+// The sniffer recognizes only the signatures, so e.g. docx is sniffed as zip and json as text.
+func consistent(declared string, sniffed string) bool {
+	declared, _, err := mime.ParseMediaType(declared)
+	if err != nil {
+		return false
+	}
+	sniffed, _, _ = mime.ParseMediaType(sniffed)
+	if declared == sniffed {
+		return true
+	}
+
+	switch {
+	case sniffed == "application/octet-stream":
+		// Unknown binary signature
+		return !textual(declared)
+	case strings.HasPrefix(sniffed, "text/"):
+		return textual(declared)
+	case sniffed == "application/zip":
+		return zipBased(declared)
+	}
+
+	// The sniffer can't tell e.g. audio/webm from video/webm
+	family := func(t string) string {
+		family, _, _ := strings.Cut(t, "/")
+		return family
+	}
+	switch family(sniffed) {
+	case "image", "audio", "video":
+		return family(declared) == family(sniffed)
+	default:
+		return false
+	}
+}
+
+func textual(mediaType string) bool {
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript", "application/x-ndjson",
+		"application/yaml", "application/x-yaml", "application/toml", "application/sql", "image/svg+xml":
+		return true
+	}
+	return strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml")
+}
+
+func zipBased(mediaType string) bool {
+	return mediaType == "application/java-archive" ||
+		strings.HasPrefix(mediaType, "application/vnd.openxmlformats-officedocument.") ||
+		strings.HasPrefix(mediaType, "application/vnd.oasis.opendocument.") ||
+		strings.HasSuffix(mediaType, "+zip")
+}
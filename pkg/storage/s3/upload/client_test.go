@@ -0,0 +1,176 @@
+package upload
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/mandarine-io/baselib/pkg/storage/s3"
+	"github.com/mandarine-io/baselib/pkg/storage/s3/memory"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"strings"
+	"testing"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+type scannerFunc func(ctx context.Context, r io.Reader) error
+
+func (f scannerFunc) Scan(ctx context.Context, r io.Reader) error {
+	return f(ctx, r)
+}
+
+func TestClient_CreateOneKeepsDeclaredContentType(t *testing.T) {
+	var docx bytes.Buffer
+	zw := zip.NewWriter(&docx)
+	_, err := zw.Create("word/document.xml")
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	tests := []struct {
+		name        string
+		data        []byte
+		declared    string
+		contentType string
+	}{
+		{
+			name:        "docx",
+			data:        docx.Bytes(),
+			declared:    "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+			contentType: "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		},
+		{name: "json", data: []byte(`{"a":1}`), declared: "application/json", contentType: "application/json"},
+		{name: "csv", data: []byte("a,b\n1,2\n"), declared: "text/csv; charset=utf-8", contentType: "text/csv; charset=utf-8"},
+		{name: "png", data: pngHeader, declared: "image/png", contentType: "image/png"},
+		{name: "undeclared", data: pngHeader, declared: "", contentType: "image/png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			c := NewClient(memory.NewClient("test"), Config{})
+
+			dto := c.CreateOne(ctx, fileData("file", tt.data, tt.declared))
+			require.NoError(t, dto.Error)
+
+			info, err := c.Stat(ctx, "file")
+			require.NoError(t, err)
+			assert.Equal(t, tt.contentType, info.ContentType)
+			assert.Equal(t, int64(len(tt.data)), info.Size)
+
+			sum := sha256.Sum256(tt.data)
+			assert.Equal(t, hex.EncodeToString(sum[:]), info.UserMetadata[ChecksumMetadata])
+		})
+	}
+}
+
+func TestClient_CreateOneRejectsMismatchedContent(t *testing.T) {
+	c := NewClient(memory.NewClient("test"), Config{})
+
+	dto := c.CreateOne(context.Background(), fileData("file", []byte("plain text"), "image/png"))
+	assert.ErrorIs(t, dto.Error, ErrContentTypeMismatch)
+
+	dto = c.CreateOne(context.Background(), fileData("file", pngHeader, "application/json"))
+	assert.ErrorIs(t, dto.Error, ErrContentTypeMismatch)
+
+	exists, err := c.Exists(context.Background(), "file")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestClient_CreateOneAllowedTypes(t *testing.T) {
+	c := NewClient(memory.NewClient("test"), Config{AllowedTypes: []string{"image/*", "application/JSON"}})
+
+	assert.NoError(t, c.CreateOne(context.Background(), fileData("a", pngHeader, "image/png")).Error)
+	assert.NoError(t, c.CreateOne(context.Background(), fileData("b", []byte(`{}`), "application/json")).Error)
+	assert.NoError(t, c.CreateOne(context.Background(), fileData("c", pngHeader, "")).Error)
+	assert.ErrorIs(t, c.CreateOne(context.Background(), fileData("d", []byte("a,b"), "text/csv")).Error, ErrContentTypeNotAllowed)
+	assert.ErrorIs(t, c.CreateOne(context.Background(), fileData("e", []byte("a,b"), "")).Error, ErrContentTypeNotAllowed)
+}
+
+func TestClient_CreateOneMaxSize(t *testing.T) {
+	c := NewClient(memory.NewClient("test"), Config{MaxSize: 8})
+
+	assert.NoError(t, c.CreateOne(context.Background(), fileData("a", []byte("12345678"), "text/plain")).Error)
+	assert.ErrorIs(t, c.CreateOne(context.Background(), fileData("b", []byte("123456789"), "text/plain")).Error, ErrFileTooLarge)
+
+	// The unknown size is limited while spooling
+	file := fileData("c", []byte("123456789"), "text/plain")
+	file.Size = -1
+	assert.ErrorIs(t, c.CreateOne(context.Background(), file).Error, ErrFileTooLarge)
+
+	file = fileData("d", []byte("1234"), "text/plain")
+	file.Size = -1
+	require.NoError(t, c.CreateOne(context.Background(), file).Error)
+	info, err := c.Stat(context.Background(), "d")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), info.Size)
+}
+
+func TestClient_CreateOneShortContent(t *testing.T) {
+	c := NewClient(memory.NewClient("test"), Config{})
+
+	file := fileData("file", []byte("1234"), "text/plain")
+	file.Size = 8
+	assert.ErrorIs(t, c.CreateOne(context.Background(), file).Error, io.ErrUnexpectedEOF)
+}
+
+func TestClient_CreateOneScanner(t *testing.T) {
+	var scanned []byte
+	c := NewClient(memory.NewClient("test"), Config{
+		Scanner: scannerFunc(func(_ context.Context, r io.Reader) error {
+			data, err := io.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			scanned = data
+			if strings.Contains(string(data), "EICAR") {
+				return errors.Wrap(ErrInfected, "Eicar-Signature")
+			}
+			return nil
+		}),
+	})
+
+	require.NoError(t, c.CreateOne(context.Background(), fileData("clean", []byte("clean"), "text/plain")).Error)
+	assert.Equal(t, []byte("clean"), scanned)
+
+	// The stored content is read again after scanning
+	got := c.GetOne(context.Background(), "clean")
+	require.NoError(t, got.Error)
+	data, err := io.ReadAll(got.Data.Reader)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("clean"), data)
+
+	dto := c.CreateOne(context.Background(), fileData("infected", []byte("EICAR test"), "text/plain"))
+	assert.ErrorIs(t, dto.Error, ErrInfected)
+	exists, err := c.Exists(context.Background(), "infected")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestClient_CreateMany(t *testing.T) {
+	c := NewClient(memory.NewClient("test"), Config{AllowedTypes: []string{"text/plain"}})
+
+	dtos, err := c.CreateMany(context.Background(), []*s3.FileData{
+		fileData("a", []byte("a"), "text/plain"),
+		fileData("b", pngHeader, "image/png"),
+	})
+	var batchErr *s3.BatchError
+	require.True(t, errors.As(err, &batchErr))
+	assert.ErrorIs(t, err, ErrContentTypeNotAllowed)
+	require.Len(t, dtos, 2)
+	assert.NoError(t, dtos[0].Error)
+	assert.ErrorIs(t, dtos[1].Error, ErrContentTypeNotAllowed)
+}
+
+func fileData(id string, data []byte, contentType string) *s3.FileData {
+	return &s3.FileData{
+		ID:          id,
+		Size:        int64(len(data)),
+		ContentType: contentType,
+		Reader:      io.NopCloser(bytes.NewReader(data)),
+	}
+}
@@ -0,0 +1,17 @@
+package taskqueue
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestServer_Backoff(t *testing.T) {
+	s := NewServer(nil, Config{RetryBackoff: time.Second, MaxRetryBackoff: 10 * time.Second})
+
+	assert.Equal(t, time.Second, s.backoff(1))
+	assert.Equal(t, 2*time.Second, s.backoff(2))
+	assert.Equal(t, 8*time.Second, s.backoff(4))
+	assert.Equal(t, 10*time.Second, s.backoff(5))
+	assert.Equal(t, 10*time.Second, s.backoff(100))
+}
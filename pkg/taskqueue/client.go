@@ -0,0 +1,120 @@
+package taskqueue
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/goccy/go-json"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+const (
+	defaultMaxRetries = 3
+	defaultUniqueTTL  = 24 * time.Hour
+)
+
+type enqueueOptions struct {
+	processAt  time.Time
+	maxRetries int
+	timeout    time.Duration
+	unique     bool
+	uniqueKey  string
+	uniqueTTL  time.Duration
+}
+
+type EnqueueOption func(*enqueueOptions)
+
+// WithDelay processes the task after the delay.
+func WithDelay(delay time.Duration) EnqueueOption {
+	return func(o *enqueueOptions) {
+		o.processAt = time.Now().Add(delay)
+	}
+}
+
+// WithProcessAt processes the task at the given time.
+func WithProcessAt(t time.Time) EnqueueOption {
+	return func(o *enqueueOptions) {
+		o.processAt = t
+	}
+}
+
+// WithMaxRetries sets the number of retries of the failed task. Defaults to 3.
+func WithMaxRetries(n int) EnqueueOption {
+	return func(o *enqueueOptions) {
+		o.maxRetries = n
+	}
+}
+
+// WithTimeout limits the duration of a single attempt. It should be less than the visibility timeout.
+func WithTimeout(timeout time.Duration) EnqueueOption {
+	return func(o *enqueueOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithUnique rejects the task with ErrDuplicateTask while the task with the same key is in the queue,
+// but no longer than ttl (24 hours if zero). If the key is empty, it's derived from the task type and payload.
+func WithUnique(key string, ttl time.Duration) EnqueueOption {
+	return func(o *enqueueOptions) {
+		o.unique = true
+		o.uniqueKey = key
+		o.uniqueTTL = ttl
+	}
+}
+
+type Client struct {
+	broker Broker
+}
+
+func NewClient(broker Broker) *Client {
+	return &Client{broker: broker}
+}
+
+// Enqueue encodes the payload as JSON and stores the task. It returns the id of the task.
+func (c *Client) Enqueue(ctx context.Context, taskType string, payload any, opts ...EnqueueOption) (string, error) {
+	log.Debug().Msgf("enqueue task %s", taskType)
+
+	o := &enqueueOptions{maxRetries: defaultMaxRetries}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	task := Task{
+		ID:         uuid.NewString(),
+		Type:       taskType,
+		Payload:    data,
+		MaxRetries: o.maxRetries,
+		Timeout:    o.timeout,
+		EnqueuedAt: now,
+		ProcessAt:  o.processAt,
+	}
+	if task.ProcessAt.IsZero() {
+		task.ProcessAt = now
+	}
+
+	var uniqueTTL time.Duration
+	if o.unique {
+		task.UniqueKey = o.uniqueKey
+		if task.UniqueKey == "" {
+			sum := sha256.Sum256(append([]byte(taskType+":"), data...))
+			task.UniqueKey = taskType + ":" + hex.EncodeToString(sum[:])
+		}
+		uniqueTTL = o.uniqueTTL
+		if uniqueTTL <= 0 {
+			uniqueTTL = defaultUniqueTTL
+		}
+	}
+
+	if err := c.broker.Enqueue(ctx, task, uniqueTTL); err != nil {
+		return "", err
+	}
+	return task.ID, nil
+}
@@ -0,0 +1,165 @@
+package memory
+
+import (
+	"context"
+	"github.com/mandarine-io/baselib/pkg/taskqueue"
+	"github.com/rs/zerolog/log"
+	"sort"
+	"sync"
+	"time"
+)
+
+const defaultDeadLetterSize = 1000
+
+type uniqueLock struct {
+	id        string
+	expiresAt time.Time
+}
+
+type broker struct {
+	mu         sync.Mutex
+	tasks      map[string]taskqueue.Task
+	ready      []string
+	scheduled  map[string]time.Time
+	processing map[string]time.Time
+	unique     map[string]uniqueLock
+	dead       []taskqueue.Task
+	deadSize   int
+}
+
+// NewBroker creates the broker storing tasks in memory. Tasks are lost on restart,
+// so it's intended for tests and single-instance deployments.
+func NewBroker(deadLetterSize int) taskqueue.Broker {
+	if deadLetterSize <= 0 {
+		deadLetterSize = defaultDeadLetterSize
+	}
+	return &broker{
+		tasks:      make(map[string]taskqueue.Task),
+		scheduled:  make(map[string]time.Time),
+		processing: make(map[string]time.Time),
+		unique:     make(map[string]uniqueLock),
+		deadSize:   deadLetterSize,
+	}
+}
+
+func (b *broker) Enqueue(_ context.Context, task taskqueue.Task, uniqueTTL time.Duration) error {
+	log.Debug().Msgf("enqueue task %s to memory queue", task.ID)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if uniqueTTL > 0 {
+		b.pruneUnique(now)
+		if lock, ok := b.unique[task.UniqueKey]; ok && now.Before(lock.expiresAt) {
+			return taskqueue.ErrDuplicateTask
+		}
+		b.unique[task.UniqueKey] = uniqueLock{id: task.ID, expiresAt: now.Add(uniqueTTL)}
+	}
+
+	b.tasks[task.ID] = task
+	if task.ProcessAt.After(now) {
+		b.scheduled[task.ID] = task.ProcessAt
+	} else {
+		b.ready = append(b.ready, task.ID)
+	}
+	return nil
+}
+
+func (b *broker) Dequeue(_ context.Context, visibilityTimeout time.Duration) (taskqueue.Task, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.forward(b.scheduled, now)
+	b.forward(b.processing, now)
+
+	for len(b.ready) > 0 {
+		id := b.ready[0]
+		b.ready = b.ready[1:]
+
+		task, ok := b.tasks[id]
+		if !ok {
+			continue
+		}
+
+		log.Debug().Msgf("dequeue task %s from memory queue", id)
+		b.processing[id] = now.Add(visibilityTimeout)
+		return task, nil
+	}
+
+	return taskqueue.Task{}, taskqueue.ErrNoTask
+}
+
+func (b *broker) Ack(_ context.Context, task taskqueue.Task) error {
+	log.Debug().Msgf("ack task %s in memory queue", task.ID)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.remove(task)
+	return nil
+}
+
+func (b *broker) Retry(_ context.Context, task taskqueue.Task) error {
+	log.Debug().Msgf("retry task %s in memory queue", task.ID)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.processing, task.ID)
+	b.tasks[task.ID] = task
+	b.scheduled[task.ID] = task.ProcessAt
+	return nil
+}
+
+func (b *broker) Kill(_ context.Context, task taskqueue.Task) error {
+	log.Debug().Msgf("kill task %s in memory queue", task.ID)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.remove(task)
+	b.dead = append(b.dead, task)
+	if len(b.dead) > b.deadSize {
+		b.dead = b.dead[len(b.dead)-b.deadSize:]
+	}
+	return nil
+}
+
+// forward moves the tasks from the set whose time has come to the ready list in time order.
+func (b *broker) forward(set map[string]time.Time, now time.Time) {
+	var ids []string
+	for id, at := range set {
+		if !at.After(now) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return set[ids[i]].Before(set[ids[j]])
+	})
+
+	for _, id := range ids {
+		delete(set, id)
+		b.ready = append(b.ready, id)
+	}
+}
+
+// pruneUnique deletes the expired unique keys, so the keys of the tasks which are never
+// acknowledged or killed don't accumulate.
+func (b *broker) pruneUnique(now time.Time) {
+	for key, lock := range b.unique {
+		if !now.Before(lock.expiresAt) {
+			delete(b.unique, key)
+		}
+	}
+}
+
+func (b *broker) remove(task taskqueue.Task) {
+	delete(b.processing, task.ID)
+	delete(b.scheduled, task.ID)
+	delete(b.tasks, task.ID)
+	if lock, ok := b.unique[task.UniqueKey]; ok && lock.id == task.ID {
+		delete(b.unique, task.UniqueKey)
+	}
+}
@@ -0,0 +1,130 @@
+package memory
+
+import (
+	"context"
+	"github.com/mandarine-io/baselib/pkg/taskqueue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestBroker_DequeuesInOrder(t *testing.T) {
+	ctx := context.Background()
+	b := NewBroker(0)
+
+	now := time.Now()
+	require.NoError(t, b.Enqueue(ctx, taskqueue.Task{ID: "1", ProcessAt: now}, 0))
+	require.NoError(t, b.Enqueue(ctx, taskqueue.Task{ID: "2", ProcessAt: now}, 0))
+	require.NoError(t, b.Enqueue(ctx, taskqueue.Task{ID: "later", ProcessAt: now.Add(time.Hour)}, 0))
+
+	for _, id := range []string{"1", "2"} {
+		task, err := b.Dequeue(ctx, time.Minute)
+		require.NoError(t, err)
+		assert.Equal(t, id, task.ID)
+	}
+	_, err := b.Dequeue(ctx, time.Minute)
+	assert.ErrorIs(t, err, taskqueue.ErrNoTask)
+}
+
+func TestBroker_RedeliversAfterVisibilityTimeout(t *testing.T) {
+	ctx := context.Background()
+	b := NewBroker(0)
+	require.NoError(t, b.Enqueue(ctx, taskqueue.Task{ID: "1", ProcessAt: time.Now()}, 0))
+
+	task, err := b.Dequeue(ctx, time.Hour)
+	require.NoError(t, err)
+	_, err = b.Dequeue(ctx, time.Hour)
+	assert.ErrorIs(t, err, taskqueue.ErrNoTask)
+
+	// The task is hidden until the deadline only
+	b.(*broker).processing[task.ID] = time.Now()
+	redelivered, err := b.Dequeue(ctx, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, task.ID, redelivered.ID)
+
+	require.NoError(t, b.Ack(ctx, redelivered))
+	b.(*broker).processing[task.ID] = time.Now()
+	_, err = b.Dequeue(ctx, time.Hour)
+	assert.ErrorIs(t, err, taskqueue.ErrNoTask)
+}
+
+func TestBroker_Retry(t *testing.T) {
+	ctx := context.Background()
+	b := NewBroker(0)
+	require.NoError(t, b.Enqueue(ctx, taskqueue.Task{ID: "1", ProcessAt: time.Now()}, 0))
+
+	task, err := b.Dequeue(ctx, time.Hour)
+	require.NoError(t, err)
+
+	task.Attempt = 1
+	task.ProcessAt = time.Now().Add(time.Hour)
+	require.NoError(t, b.Retry(ctx, task))
+	_, err = b.Dequeue(ctx, time.Hour)
+	assert.ErrorIs(t, err, taskqueue.ErrNoTask)
+
+	task.ProcessAt = time.Now()
+	require.NoError(t, b.Retry(ctx, task))
+	retried, err := b.Dequeue(ctx, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 1, retried.Attempt)
+}
+
+func TestBroker_UniqueTasks(t *testing.T) {
+	ctx := context.Background()
+	b := NewBroker(0)
+
+	task := taskqueue.Task{ID: "1", UniqueKey: "key", ProcessAt: time.Now()}
+	require.NoError(t, b.Enqueue(ctx, task, time.Hour))
+	assert.ErrorIs(t, b.Enqueue(ctx, taskqueue.Task{ID: "2", UniqueKey: "key"}, time.Hour), taskqueue.ErrDuplicateTask)
+
+	// The key is released when the task is processed
+	dequeued, err := b.Dequeue(ctx, time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, b.Ack(ctx, dequeued))
+	require.NoError(t, b.Enqueue(ctx, taskqueue.Task{ID: "3", UniqueKey: "key"}, time.Hour))
+
+	// The key of the killed task is released too
+	require.NoError(t, b.Kill(ctx, taskqueue.Task{ID: "3", UniqueKey: "key"}))
+	require.NoError(t, b.Enqueue(ctx, taskqueue.Task{ID: "4", UniqueKey: "key"}, time.Hour))
+}
+
+func TestBroker_PrunesExpiredUniqueKeys(t *testing.T) {
+	ctx := context.Background()
+	b := NewBroker(0)
+
+	require.NoError(t, b.Enqueue(ctx, taskqueue.Task{ID: "1", UniqueKey: "expired", ProcessAt: time.Now()}, time.Nanosecond))
+	time.Sleep(time.Millisecond)
+
+	require.NoError(t, b.Enqueue(ctx, taskqueue.Task{ID: "2", UniqueKey: "expired"}, time.Hour))
+	require.NoError(t, b.Enqueue(ctx, taskqueue.Task{ID: "3", UniqueKey: "other", ProcessAt: time.Now()}, time.Nanosecond))
+	time.Sleep(time.Millisecond)
+	require.NoError(t, b.Enqueue(ctx, taskqueue.Task{ID: "4", UniqueKey: "new"}, time.Hour))
+
+	unique := b.(*broker).unique
+	assert.Len(t, unique, 2)
+	assert.Equal(t, "2", unique["expired"].id)
+	assert.Equal(t, "4", unique["new"].id)
+}
+
+func TestBroker_DeadLetters(t *testing.T) {
+	ctx := context.Background()
+	b := NewBroker(2)
+
+	for _, id := range []string{"1", "2", "3"} {
+		require.NoError(t, b.Enqueue(ctx, taskqueue.Task{ID: id, ProcessAt: time.Now()}, 0))
+		task, err := b.Dequeue(ctx, time.Hour)
+		require.NoError(t, err)
+		task.LastError = "failed"
+		require.NoError(t, b.Kill(ctx, task))
+	}
+
+	dead := b.(*broker).dead
+	require.Len(t, dead, 2)
+	assert.Equal(t, "2", dead[0].ID)
+	assert.Equal(t, "3", dead[1].ID)
+	assert.Equal(t, "failed", dead[1].LastError)
+
+	_, err := b.Dequeue(ctx, time.Hour)
+	assert.ErrorIs(t, err, taskqueue.ErrNoTask)
+}
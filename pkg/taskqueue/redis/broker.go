@@ -0,0 +1,216 @@
+package redis
+
+import (
+	"context"
+	"github.com/goccy/go-json"
+	"github.com/mandarine-io/baselib/pkg/taskqueue"
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+const (
+	defaultQueue          = "default"
+	defaultDeadLetterSize = 1000
+	// forwardBatch is the number of due or expired tasks moved to the ready list per dequeue.
+	forwardBatch = 100
+)
+
+var enqueueScript = redis.NewScript(`
+if tonumber(ARGV[5]) > 0 then
+	if not redis.call("SET", KEYS[4], ARGV[2], "NX", "PX", ARGV[5]) then
+		return 0
+	end
+end
+redis.call("HSET", KEYS[1], ARGV[2], ARGV[1])
+if tonumber(ARGV[3]) <= tonumber(ARGV[4]) then
+	redis.call("LPUSH", KEYS[2], ARGV[2])
+else
+	redis.call("ZADD", KEYS[3], ARGV[3], ARGV[2])
+end
+return 1
+`)
+
+// dequeueScript moves due scheduled tasks and tasks with expired visibility timeout
+// to the ready list, then pops the next ready task and hides it until the deadline.
+var dequeueScript = redis.NewScript(`
+local due = redis.call("ZRANGEBYSCORE", KEYS[2], "-inf", ARGV[1], "LIMIT", 0, ARGV[3])
+for _, id in ipairs(due) do
+	redis.call("ZREM", KEYS[2], id)
+	redis.call("LPUSH", KEYS[1], id)
+end
+local expired = redis.call("ZRANGEBYSCORE", KEYS[3], "-inf", ARGV[1], "LIMIT", 0, ARGV[3])
+for _, id in ipairs(expired) do
+	redis.call("ZREM", KEYS[3], id)
+	redis.call("RPUSH", KEYS[1], id)
+end
+while true do
+	local id = redis.call("RPOP", KEYS[1])
+	if not id then
+		return false
+	end
+	local task = redis.call("HGET", KEYS[4], id)
+	if task then
+		redis.call("ZADD", KEYS[3], ARGV[2], id)
+		return task
+	end
+end
+`)
+
+var ackScript = redis.NewScript(`
+redis.call("ZREM", KEYS[1], ARGV[1])
+redis.call("HDEL", KEYS[2], ARGV[1])
+if redis.call("GET", KEYS[3]) == ARGV[1] then
+	redis.call("DEL", KEYS[3])
+end
+return 1
+`)
+
+var retryScript = redis.NewScript(`
+redis.call("ZREM", KEYS[1], ARGV[1])
+redis.call("HSET", KEYS[2], ARGV[1], ARGV[2])
+redis.call("ZADD", KEYS[3], ARGV[3], ARGV[1])
+return 1
+`)
+
+var killScript = redis.NewScript(`
+redis.call("ZREM", KEYS[1], ARGV[1])
+redis.call("HDEL", KEYS[2], ARGV[1])
+redis.call("LPUSH", KEYS[3], ARGV[2])
+redis.call("LTRIM", KEYS[3], 0, tonumber(ARGV[3]) - 1)
+if redis.call("GET", KEYS[4]) == ARGV[1] then
+	redis.call("DEL", KEYS[4])
+end
+return 1
+`)
+
+type Config struct {
+	// Queue is the name of the queue. Defaults to "default".
+	Queue string
+	// DeadLetterSize is the number of last failed tasks kept in the dead letter list. Defaults to 1000.
+	DeadLetterSize int
+}
+
+type broker struct {
+	rdb    redis.UniversalClient
+	prefix string
+	cfg    Config
+}
+
+// NewBroker creates the broker storing tasks in Redis: task payloads in a hash by id, ready tasks
+// in a list, delayed and retried tasks in a sorted set scored by the processing time, dequeued tasks
+// in a sorted set scored by the visibility deadline. Scripts declare all keys they access and the keys
+// of the queue share a hash tag, so it works in Redis Cluster.
+func NewBroker(rdb redis.UniversalClient, cfg Config) taskqueue.Broker {
+	if cfg.Queue == "" {
+		cfg.Queue = defaultQueue
+	}
+	if cfg.DeadLetterSize <= 0 {
+		cfg.DeadLetterSize = defaultDeadLetterSize
+	}
+	return &broker{rdb: rdb, prefix: "{taskqueue:" + cfg.Queue + "}:", cfg: cfg}
+}
+
+func (b *broker) Enqueue(ctx context.Context, task taskqueue.Task, uniqueTTL time.Duration) error {
+	log.Debug().Msgf("enqueue task %s to redis queue %s", task.ID, b.cfg.Queue)
+
+	data, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+
+	ok, err := enqueueScript.Run(ctx, b.rdb,
+		[]string{b.tasksKey(), b.readyKey(), b.scheduledKey(), b.uniqueKey(task.UniqueKey)},
+		data, task.ID, task.ProcessAt.UnixMilli(), time.Now().UnixMilli(), uniqueTTL.Milliseconds(),
+	).Int()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return taskqueue.ErrDuplicateTask
+	}
+	return nil
+}
+
+func (b *broker) Dequeue(ctx context.Context, visibilityTimeout time.Duration) (taskqueue.Task, error) {
+	now := time.Now()
+	data, err := dequeueScript.Run(ctx, b.rdb,
+		[]string{b.readyKey(), b.scheduledKey(), b.processingKey(), b.tasksKey()},
+		now.UnixMilli(), now.Add(visibilityTimeout).UnixMilli(), forwardBatch,
+	).Text()
+	if errors.Is(err, redis.Nil) {
+		return taskqueue.Task{}, taskqueue.ErrNoTask
+	}
+	if err != nil {
+		return taskqueue.Task{}, err
+	}
+
+	var task taskqueue.Task
+	if err := json.Unmarshal([]byte(data), &task); err != nil {
+		return taskqueue.Task{}, err
+	}
+
+	log.Debug().Msgf("dequeue task %s from redis queue %s", task.ID, b.cfg.Queue)
+	return task, nil
+}
+
+func (b *broker) Ack(ctx context.Context, task taskqueue.Task) error {
+	log.Debug().Msgf("ack task %s in redis queue %s", task.ID, b.cfg.Queue)
+	return ackScript.Run(ctx, b.rdb,
+		[]string{b.processingKey(), b.tasksKey(), b.uniqueKey(task.UniqueKey)},
+		task.ID,
+	).Err()
+}
+
+func (b *broker) Retry(ctx context.Context, task taskqueue.Task) error {
+	log.Debug().Msgf("retry task %s in redis queue %s", task.ID, b.cfg.Queue)
+
+	data, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+
+	return retryScript.Run(ctx, b.rdb,
+		[]string{b.processingKey(), b.tasksKey(), b.scheduledKey()},
+		task.ID, data, task.ProcessAt.UnixMilli(),
+	).Err()
+}
+
+func (b *broker) Kill(ctx context.Context, task taskqueue.Task) error {
+	log.Debug().Msgf("kill task %s in redis queue %s", task.ID, b.cfg.Queue)
+
+	data, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+
+	return killScript.Run(ctx, b.rdb,
+		[]string{b.processingKey(), b.tasksKey(), b.deadKey(), b.uniqueKey(task.UniqueKey)},
+		task.ID, data, b.cfg.DeadLetterSize,
+	).Err()
+}
+
+func (b *broker) tasksKey() string {
+	return b.prefix + "tasks"
+}
+
+func (b *broker) readyKey() string {
+	return b.prefix + "ready"
+}
+
+func (b *broker) scheduledKey() string {
+	return b.prefix + "scheduled"
+}
+
+func (b *broker) processingKey() string {
+	return b.prefix + "processing"
+}
+
+func (b *broker) deadKey() string {
+	return b.prefix + "dead"
+}
+
+func (b *broker) uniqueKey(key string) string {
+	return b.prefix + "unique:" + key
+}
@@ -0,0 +1,162 @@
+package redis
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/goccy/go-json"
+	"github.com/mandarine-io/baselib/pkg/taskqueue"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func newBroker(t *testing.T, cfg Config) (taskqueue.Broker, *miniredis.Miniredis) {
+	srv := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	t.Cleanup(func() {
+		_ = rdb.Close()
+	})
+	return NewBroker(rdb, cfg), srv
+}
+
+func TestBroker_DequeuesInOrder(t *testing.T) {
+	ctx := context.Background()
+	b, srv := newBroker(t, Config{Queue: "q"})
+
+	now := time.Now()
+	require.NoError(t, b.Enqueue(ctx, taskqueue.Task{ID: "1", Payload: json.RawMessage(`{"a":1}`), ProcessAt: now}, 0))
+	require.NoError(t, b.Enqueue(ctx, taskqueue.Task{ID: "2", ProcessAt: now}, 0))
+	require.NoError(t, b.Enqueue(ctx, taskqueue.Task{ID: "later", ProcessAt: now.Add(time.Hour)}, 0))
+
+	task, err := b.Dequeue(ctx, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "1", task.ID)
+	assert.JSONEq(t, `{"a":1}`, string(task.Payload))
+
+	task, err = b.Dequeue(ctx, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "2", task.ID)
+
+	_, err = b.Dequeue(ctx, time.Minute)
+	assert.ErrorIs(t, err, taskqueue.ErrNoTask)
+
+	// All keys of the queue share the hash tag
+	for _, key := range srv.Keys() {
+		assert.Contains(t, key, "{taskqueue:q}:")
+	}
+}
+
+func TestBroker_DequeuesDueScheduledTasks(t *testing.T) {
+	ctx := context.Background()
+	b, _ := newBroker(t, Config{})
+
+	require.NoError(t, b.Enqueue(ctx, taskqueue.Task{ID: "1", ProcessAt: time.Now().Add(-time.Second)}, 0))
+	require.NoError(t, b.Enqueue(ctx, taskqueue.Task{ID: "2", ProcessAt: time.Now().Add(time.Hour)}, 0))
+
+	task, err := b.Dequeue(ctx, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "1", task.ID)
+
+	_, err = b.Dequeue(ctx, time.Minute)
+	assert.ErrorIs(t, err, taskqueue.ErrNoTask)
+}
+
+func TestBroker_RedeliversAfterVisibilityTimeout(t *testing.T) {
+	ctx := context.Background()
+	b, _ := newBroker(t, Config{})
+	require.NoError(t, b.Enqueue(ctx, taskqueue.Task{ID: "1", ProcessAt: time.Now()}, 0))
+
+	task, err := b.Dequeue(ctx, time.Hour)
+	require.NoError(t, err)
+	_, err = b.Dequeue(ctx, time.Hour)
+	assert.ErrorIs(t, err, taskqueue.ErrNoTask)
+
+	// Hide the task until now, so the next dequeue finds it expired
+	require.NoError(t, b.Retry(ctx, task))
+	task, err = b.Dequeue(ctx, 0)
+	require.NoError(t, err)
+	redelivered, err := b.Dequeue(ctx, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, task.ID, redelivered.ID)
+
+	require.NoError(t, b.Ack(ctx, redelivered))
+	_, err = b.Dequeue(ctx, 0)
+	assert.ErrorIs(t, err, taskqueue.ErrNoTask)
+}
+
+func TestBroker_Retry(t *testing.T) {
+	ctx := context.Background()
+	b, _ := newBroker(t, Config{})
+	require.NoError(t, b.Enqueue(ctx, taskqueue.Task{ID: "1", ProcessAt: time.Now()}, 0))
+
+	task, err := b.Dequeue(ctx, time.Hour)
+	require.NoError(t, err)
+
+	task.Attempt = 1
+	task.LastError = "failed"
+	task.ProcessAt = time.Now().Add(time.Hour)
+	require.NoError(t, b.Retry(ctx, task))
+	_, err = b.Dequeue(ctx, time.Hour)
+	assert.ErrorIs(t, err, taskqueue.ErrNoTask)
+
+	task.ProcessAt = time.Now()
+	require.NoError(t, b.Retry(ctx, task))
+	retried, err := b.Dequeue(ctx, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 1, retried.Attempt)
+	assert.Equal(t, "failed", retried.LastError)
+}
+
+func TestBroker_UniqueTasks(t *testing.T) {
+	ctx := context.Background()
+	b, srv := newBroker(t, Config{})
+
+	require.NoError(t, b.Enqueue(ctx, taskqueue.Task{ID: "1", UniqueKey: "key", ProcessAt: time.Now()}, time.Hour))
+	assert.ErrorIs(t, b.Enqueue(ctx, taskqueue.Task{ID: "2", UniqueKey: "key"}, time.Hour), taskqueue.ErrDuplicateTask)
+
+	// The key is released when the task is processed
+	task, err := b.Dequeue(ctx, time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, b.Ack(ctx, task))
+	require.NoError(t, b.Enqueue(ctx, taskqueue.Task{ID: "3", UniqueKey: "key", ProcessAt: time.Now()}, time.Hour))
+
+	// The key expires after the ttl even if the task is still in the queue
+	srv.FastForward(time.Hour)
+	require.NoError(t, b.Enqueue(ctx, taskqueue.Task{ID: "4", UniqueKey: "key", ProcessAt: time.Now()}, time.Hour))
+
+	// The task whose key expired doesn't release the key of the newer task
+	task, err = b.Dequeue(ctx, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, "3", task.ID)
+	require.NoError(t, b.Ack(ctx, task))
+	assert.ErrorIs(t, b.Enqueue(ctx, taskqueue.Task{ID: "5", UniqueKey: "key"}, time.Hour), taskqueue.ErrDuplicateTask)
+}
+
+func TestBroker_DeadLetters(t *testing.T) {
+	ctx := context.Background()
+	b, srv := newBroker(t, Config{Queue: "q", DeadLetterSize: 2})
+
+	for _, id := range []string{"1", "2", "3"} {
+		require.NoError(t, b.Enqueue(ctx, taskqueue.Task{ID: id, UniqueKey: id, ProcessAt: time.Now()}, time.Hour))
+		task, err := b.Dequeue(ctx, time.Hour)
+		require.NoError(t, err)
+		task.LastError = "failed"
+		require.NoError(t, b.Kill(ctx, task))
+	}
+
+	dead, err := srv.List("{taskqueue:q}:dead")
+	require.NoError(t, err)
+	require.Len(t, dead, 2)
+
+	var task taskqueue.Task
+	require.NoError(t, json.Unmarshal([]byte(dead[0]), &task))
+	assert.Equal(t, "3", task.ID)
+	assert.Equal(t, "failed", task.LastError)
+
+	_, err = b.Dequeue(ctx, 0)
+	assert.ErrorIs(t, err, taskqueue.ErrNoTask)
+	assert.False(t, srv.Exists("{taskqueue:q}:unique:3"))
+	assert.False(t, srv.Exists("{taskqueue:q}:tasks"))
+}
@@ -0,0 +1,260 @@
+package taskqueue
+
+import (
+	"context"
+	"fmt"
+	"github.com/goccy/go-json"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultConcurrency       = 10
+	defaultVisibilityTimeout = 5 * time.Minute
+	defaultPollInterval      = time.Second
+	defaultRetryBackoff      = 10 * time.Second
+	defaultMaxRetryBackoff   = time.Hour
+	brokerTimeout            = 10 * time.Second
+)
+
+type Config struct {
+	// Concurrency is the number of workers. Defaults to 10.
+	Concurrency int
+	// VisibilityTimeout is the time the dequeued task is hidden from other workers.
+	// It's also the default timeout of the task. Defaults to 5 minutes.
+	VisibilityTimeout time.Duration
+	// PollInterval is the delay of the worker when the queue is empty. Defaults to 1 second.
+	PollInterval time.Duration
+	// RetryBackoff is the delay before the first retry, doubled on every next one. Defaults to 10 seconds.
+	RetryBackoff time.Duration
+	// MaxRetryBackoff limits the delay between retries. Defaults to 1 hour.
+	MaxRetryBackoff time.Duration
+}
+
+func (c Config) withDefaults() Config {
+	if c.Concurrency <= 0 {
+		c.Concurrency = defaultConcurrency
+	}
+	if c.VisibilityTimeout <= 0 {
+		c.VisibilityTimeout = defaultVisibilityTimeout
+	}
+	if c.PollInterval <= 0 {
+		c.PollInterval = defaultPollInterval
+	}
+	if c.RetryBackoff <= 0 {
+		c.RetryBackoff = defaultRetryBackoff
+	}
+	if c.MaxRetryBackoff <= 0 {
+		c.MaxRetryBackoff = defaultMaxRetryBackoff
+	}
+	return c
+}
+
+type HandlerFunc func(ctx context.Context, task Task) error
+
+// Server is the pool of workers processing tasks from the broker.
+type Server struct {
+	broker Broker
+	cfg    Config
+
+	mu       sync.RWMutex
+	handlers map[string]HandlerFunc
+
+	started atomic.Bool
+	closed  atomic.Bool
+	stop    chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+func NewServer(broker Broker, cfg Config) *Server {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Server{
+		broker:   broker,
+		cfg:      cfg.withDefaults(),
+		handlers: make(map[string]HandlerFunc),
+		stop:     make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Handle registers the handler of the task type.
+func (s *Server) Handle(taskType string, h HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[taskType] = h
+}
+
+// Handle registers the handler of the task type receiving the payload decoded from JSON.
+// The task with invalid payload is not retried.
+func Handle[T any](s *Server, taskType string, h func(ctx context.Context, payload T) error) {
+	s.Handle(taskType, func(ctx context.Context, task Task) error {
+		var payload T
+		if err := json.Unmarshal(task.Payload, &payload); err != nil {
+			return errors.Wrap(ErrSkipRetry, err.Error())
+		}
+		return h(ctx, payload)
+	})
+}
+
+// Start starts the workers.
+func (s *Server) Start() {
+	if s.closed.Load() || !s.started.CompareAndSwap(false, true) {
+		return
+	}
+
+	log.Debug().Msgf("start %d task workers", s.cfg.Concurrency)
+	for i := 0; i < s.cfg.Concurrency; i++ {
+		s.wg.Add(1)
+		go s.work()
+	}
+}
+
+// Shutdown stops dequeuing tasks and waits until the running tasks are finished.
+// If ctx is done earlier, the contexts of the running tasks are canceled and ctx error
+// is returned without waiting for them. The canceled tasks are returned to the queue
+// when their handlers return.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if !s.closed.CompareAndSwap(false, true) {
+		return ErrServerClosed
+	}
+	close(s.stop)
+
+	log.Debug().Msg("shutdown task server")
+
+	doneCh := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(doneCh)
+	}()
+
+	select {
+	case <-doneCh:
+		s.cancel()
+		return nil
+	case <-ctx.Done():
+		log.Warn().Msg("task server shutdown deadline exceeded, cancel running tasks")
+		s.cancel()
+		return ctx.Err()
+	}
+}
+
+func (s *Server) work() {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+
+		task, err := s.dequeue()
+		if err != nil {
+			if !errors.Is(err, ErrNoTask) {
+				log.Error().Stack().Err(err).Msg("failed to dequeue task")
+			}
+
+			timer := time.NewTimer(s.cfg.PollInterval)
+			select {
+			case <-s.stop:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			continue
+		}
+
+		s.process(task)
+	}
+}
+
+func (s *Server) dequeue() (Task, error) {
+	ctx, cancel := context.WithTimeout(s.ctx, brokerTimeout)
+	defer cancel()
+	return s.broker.Dequeue(ctx, s.cfg.VisibilityTimeout)
+}
+
+func (s *Server) process(task Task) {
+	log.Debug().Msgf("process task %s %s", task.Type, task.ID)
+
+	taskErr := s.call(task)
+
+	ctx, cancel := context.WithTimeout(context.Background(), brokerTimeout)
+	defer cancel()
+
+	var err error
+	switch {
+	case taskErr == nil:
+		err = s.broker.Ack(ctx, task)
+	case s.ctx.Err() != nil:
+		// Canceled by shutdown, the attempt is not counted
+		log.Info().Msgf("task %s %s is interrupted by shutdown", task.Type, task.ID)
+		task.ProcessAt = time.Now()
+		err = s.broker.Retry(ctx, task)
+	case errors.Is(taskErr, ErrNoHandler):
+		// The handler may be registered by another server, e.g. during the rolling update,
+		// so the task is requeued and the attempt is not counted
+		log.Warn().Err(taskErr).Msgf("task %s %s has no handler, requeue it", task.Type, task.ID)
+		task.LastError = taskErr.Error()
+		task.ProcessAt = time.Now().Add(s.cfg.RetryBackoff)
+		err = s.broker.Retry(ctx, task)
+	case errors.Is(taskErr, ErrSkipRetry), task.Attempt >= task.MaxRetries:
+		log.Error().Err(taskErr).Msgf("task %s %s failed permanently", task.Type, task.ID)
+		task.Attempt++
+		task.LastError = taskErr.Error()
+		err = s.broker.Kill(ctx, task)
+	default:
+		task.Attempt++
+		task.LastError = taskErr.Error()
+		task.ProcessAt = time.Now().Add(s.backoff(task.Attempt))
+		log.Warn().Err(taskErr).Msgf("task %s %s failed, retry %d/%d at %s",
+			task.Type, task.ID, task.Attempt, task.MaxRetries, task.ProcessAt.Format(time.RFC3339))
+		err = s.broker.Retry(ctx, task)
+	}
+
+	if err != nil {
+		log.Error().Stack().Err(err).Msgf("failed to update task %s %s", task.Type, task.ID)
+	}
+}
+
+func (s *Server) call(task Task) (err error) {
+	s.mu.RLock()
+	h, ok := s.handlers[task.Type]
+	s.mu.RUnlock()
+	if !ok {
+		return errors.Wrap(ErrNoHandler, task.Type)
+	}
+
+	timeout := task.Timeout
+	if timeout <= 0 || timeout > s.cfg.VisibilityTimeout {
+		timeout = s.cfg.VisibilityTimeout
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, timeout)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("task panicked: %v\n%s", r, debug.Stack())
+		}
+	}()
+
+	return h(ctx, task)
+}
+
+func (s *Server) backoff(attempt int) time.Duration {
+	delay := s.cfg.RetryBackoff
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= s.cfg.MaxRetryBackoff {
+			return s.cfg.MaxRetryBackoff
+		}
+	}
+	return min(delay, s.cfg.MaxRetryBackoff)
+}
@@ -0,0 +1,256 @@
+package taskqueue_test
+
+import (
+	"context"
+	"github.com/mandarine-io/baselib/pkg/taskqueue"
+	"github.com/mandarine-io/baselib/pkg/taskqueue/memory"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	waitFor = 5 * time.Second
+	tick    = 5 * time.Millisecond
+)
+
+// recordingBroker records the tasks passed to Retry and Kill.
+type recordingBroker struct {
+	taskqueue.Broker
+
+	mu      sync.Mutex
+	retried []taskqueue.Task
+	killed  []taskqueue.Task
+}
+
+func (b *recordingBroker) Retry(ctx context.Context, task taskqueue.Task) error {
+	b.mu.Lock()
+	b.retried = append(b.retried, task)
+	b.mu.Unlock()
+	return b.Broker.Retry(ctx, task)
+}
+
+func (b *recordingBroker) Kill(ctx context.Context, task taskqueue.Task) error {
+	b.mu.Lock()
+	b.killed = append(b.killed, task)
+	b.mu.Unlock()
+	return b.Broker.Kill(ctx, task)
+}
+
+func (b *recordingBroker) Retried() []taskqueue.Task {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]taskqueue.Task(nil), b.retried...)
+}
+
+func (b *recordingBroker) Killed() []taskqueue.Task {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]taskqueue.Task(nil), b.killed...)
+}
+
+type payload struct {
+	Value string `json:"value"`
+}
+
+func newServer(t *testing.T) (*taskqueue.Server, *taskqueue.Client, *recordingBroker) {
+	broker := &recordingBroker{Broker: memory.NewBroker(0)}
+	srv := taskqueue.NewServer(broker, taskqueue.Config{
+		Concurrency:     2,
+		PollInterval:    tick,
+		RetryBackoff:    tick,
+		MaxRetryBackoff: 4 * tick,
+	})
+	t.Cleanup(func() {
+		_ = srv.Shutdown(context.Background())
+	})
+	return srv, taskqueue.NewClient(broker), broker
+}
+
+func TestServer_ProcessesTasks(t *testing.T) {
+	srv, client, _ := newServer(t)
+
+	got := make(chan string, 2)
+	taskqueue.Handle(srv, "echo", func(_ context.Context, p payload) error {
+		got <- p.Value
+		return nil
+	})
+	srv.Start()
+
+	_, err := client.Enqueue(context.Background(), "echo", payload{Value: "first"})
+	require.NoError(t, err)
+	_, err = client.Enqueue(context.Background(), "echo", payload{Value: "delayed"}, taskqueue.WithDelay(50*time.Millisecond))
+	require.NoError(t, err)
+
+	assert.Equal(t, "first", receive(t, got))
+	assert.Equal(t, "delayed", receive(t, got))
+	require.NoError(t, srv.Shutdown(context.Background()))
+	assert.ErrorIs(t, srv.Shutdown(context.Background()), taskqueue.ErrServerClosed)
+}
+
+func TestServer_RetriesFailedTask(t *testing.T) {
+	srv, client, broker := newServer(t)
+
+	var calls atomic.Int32
+	done := make(chan taskqueue.Task, 1)
+	srv.Handle("flaky", func(_ context.Context, task taskqueue.Task) error {
+		if calls.Add(1) < 3 {
+			return errors.New("temporary")
+		}
+		done <- task
+		return nil
+	})
+	srv.Start()
+
+	_, err := client.Enqueue(context.Background(), "flaky", nil, taskqueue.WithMaxRetries(5))
+	require.NoError(t, err)
+
+	task := receive(t, done)
+	assert.Equal(t, 2, task.Attempt)
+	assert.Equal(t, "temporary", task.LastError)
+
+	retried := broker.Retried()
+	require.Len(t, retried, 2)
+	assert.Equal(t, 1, retried[0].Attempt)
+	assert.Equal(t, 2, retried[1].Attempt)
+	assert.Empty(t, broker.Killed())
+}
+
+func TestServer_KillsTaskAfterMaxRetries(t *testing.T) {
+	srv, client, broker := newServer(t)
+
+	srv.Handle("failing", func(context.Context, taskqueue.Task) error {
+		return errors.New("failed")
+	})
+	srv.Start()
+
+	id, err := client.Enqueue(context.Background(), "failing", nil, taskqueue.WithMaxRetries(2))
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return len(broker.Killed()) == 1
+	}, waitFor, tick)
+
+	killed := broker.Killed()[0]
+	assert.Equal(t, id, killed.ID)
+	assert.Equal(t, 3, killed.Attempt)
+	assert.Equal(t, "failed", killed.LastError)
+	assert.Len(t, broker.Retried(), 2)
+}
+
+func TestServer_SkipRetry(t *testing.T) {
+	srv, client, broker := newServer(t)
+
+	taskqueue.Handle(srv, "typed", func(context.Context, payload) error {
+		return nil
+	})
+	srv.Start()
+
+	_, err := client.Enqueue(context.Background(), "typed", "not an object")
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return len(broker.Killed()) == 1
+	}, waitFor, tick)
+	assert.Empty(t, broker.Retried())
+}
+
+func TestServer_RequeuesTaskWithoutHandler(t *testing.T) {
+	srv, client, broker := newServer(t)
+	srv.Start()
+
+	_, err := client.Enqueue(context.Background(), "late", nil, taskqueue.WithMaxRetries(1))
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return len(broker.Retried()) >= 3
+	}, waitFor, tick)
+	for _, task := range broker.Retried() {
+		assert.Zero(t, task.Attempt)
+	}
+
+	done := make(chan struct{})
+	srv.Handle("late", func(context.Context, taskqueue.Task) error {
+		close(done)
+		return nil
+	})
+	receive(t, done)
+	assert.Empty(t, broker.Killed())
+}
+
+func TestServer_ShutdownRequeuesInterruptedTask(t *testing.T) {
+	srv, client, broker := newServer(t)
+
+	started := make(chan struct{})
+	srv.Handle("long", func(ctx context.Context, _ taskqueue.Task) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	srv.Start()
+
+	id, err := client.Enqueue(context.Background(), "long", nil)
+	require.NoError(t, err)
+	receive(t, started)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, srv.Shutdown(ctx), context.DeadlineExceeded)
+
+	require.Eventually(t, func() bool {
+		return len(broker.Retried()) == 1
+	}, waitFor, tick)
+
+	task := broker.Retried()[0]
+	assert.Equal(t, id, task.ID)
+	assert.Zero(t, task.Attempt)
+
+	// The requeued task is processed by another server
+	next, err := broker.Dequeue(context.Background(), time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, id, next.ID)
+}
+
+func TestServer_ShutdownWaitsForRunningTasks(t *testing.T) {
+	srv, client, broker := newServer(t)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var finished atomic.Bool
+	srv.Handle("short", func(context.Context, taskqueue.Task) error {
+		close(started)
+		<-release
+		finished.Store(true)
+		return nil
+	})
+	srv.Start()
+
+	_, err := client.Enqueue(context.Background(), "short", nil)
+	require.NoError(t, err)
+	receive(t, started)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+	}()
+	require.NoError(t, srv.Shutdown(context.Background()))
+	assert.True(t, finished.Load())
+	assert.Empty(t, broker.Retried())
+}
+
+func receive[T any](t *testing.T, ch <-chan T) T {
+	t.Helper()
+
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(waitFor):
+		require.FailNow(t, "timeout")
+		var zero T
+		return zero
+	}
+}
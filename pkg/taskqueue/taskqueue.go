@@ -0,0 +1,49 @@
+package taskqueue
+
+import (
+	"context"
+	"github.com/goccy/go-json"
+	"github.com/pkg/errors"
+	"time"
+)
+
+var (
+	ErrNoTask        = errors.New("no task to process")
+	ErrDuplicateTask = errors.New("task is already enqueued")
+	ErrNoHandler     = errors.New("handler of task type is not registered")
+	ErrServerClosed  = errors.New("server closed")
+	// ErrSkipRetry can be wrapped into the handler error to move the task to the dead letters immediately.
+	ErrSkipRetry = errors.New("skip retry")
+)
+
+// Task is the unit of work stored in the queue.
+type Task struct {
+	ID      string          `json:"id"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+	// Attempt is the number of failed attempts.
+	Attempt    int           `json:"attempt"`
+	MaxRetries int           `json:"maxRetries"`
+	Timeout    time.Duration `json:"timeout,omitempty"`
+	UniqueKey  string        `json:"uniqueKey,omitempty"`
+	LastError  string        `json:"lastError,omitempty"`
+	EnqueuedAt time.Time     `json:"enqueuedAt"`
+	ProcessAt  time.Time     `json:"processAt"`
+}
+
+// Broker stores tasks. Dequeued tasks are invisible to other workers for the visibility
+// timeout: if the task is neither acknowledged nor retried in time (e.g. the worker crashed),
+// it's returned to the queue, so tasks are processed at least once.
+type Broker interface {
+	// Enqueue stores the task to be processed at task.ProcessAt. If the task has the unique key
+	// and another task with the same key is enqueued less than uniqueTTL ago, ErrDuplicateTask is returned.
+	Enqueue(ctx context.Context, task Task, uniqueTTL time.Duration) error
+	// Dequeue returns the next ready task or ErrNoTask.
+	Dequeue(ctx context.Context, visibilityTimeout time.Duration) (Task, error)
+	// Ack removes the processed task.
+	Ack(ctx context.Context, task Task) error
+	// Retry stores the updated task to be processed again at task.ProcessAt.
+	Retry(ctx context.Context, task Task) error
+	// Kill removes the task from the queue and stores it in the dead letters.
+	Kill(ctx context.Context, task Task) error
+}
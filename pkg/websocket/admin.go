@@ -0,0 +1,52 @@
+package websocket
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// SessionInfo describes the connected session for admin tooling.
+type SessionInfo struct {
+	ClientId    string         `json:"clientId"`
+	SessionId   string         `json:"sessionId"`
+	RemoteAddr  string         `json:"remoteAddr"`
+	ConnectedAt time.Time      `json:"connectedAt"`
+	QueueLength int            `json:"queueLength"`
+	Metadata    map[string]any `json:"metadata,omitempty"`
+}
+
+// SessionInfos returns the information about all connected sessions ordered by connect time.
+func (p *Pool) SessionInfos() []SessionInfo {
+	conns := p.allConnections()
+	infos := make([]SessionInfo, 0, len(conns))
+	for _, c := range conns {
+		info := SessionInfo{
+			ClientId:    c.clientId,
+			SessionId:   c.sessionId,
+			RemoteAddr:  c.remoteAddr,
+			ConnectedAt: c.createdAt,
+			QueueLength: len(c.sendCh),
+		}
+		if identity := c.identity.Load(); identity != nil {
+			info.Metadata = identity.Metadata
+		}
+		infos = append(infos, info)
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ConnectedAt.Before(infos[j].ConnectedAt)
+	})
+	return infos
+}
+
+// SessionsHandler returns the gin handler listing connected sessions. It should be
+// registered behind admin authorization, e.g. GET /admin/websocket/sessions.
+func (p *Pool) SessionsHandler() gin.HandlerFunc {
+	log.Debug().Msg("setup websocket sessions handler")
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, p.SessionInfos())
+	}
+}
@@ -0,0 +1,275 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRevalidateTimeout = 10 * time.Second
+	// revalidateConcurrency is the number of connections revalidated at the same time.
+	revalidateConcurrency = 16
+
+	CloseCredentialsExpired = 4001
+)
+
+var (
+	ErrUnauthorized     = fmt.Errorf("unauthorized")
+	ErrTokenNotFound    = fmt.Errorf("token not found")
+	ErrNoAuthenticator  = fmt.Errorf("authenticator is not configured")
+	ErrOriginNotAllowed = fmt.Errorf("origin is not allowed")
+)
+
+// Identity is the result of authentication. Metadata is stored with the connection
+// and available to message handlers.
+type Identity struct {
+	ClientId  string
+	Metadata  map[string]any
+	ExpiresAt time.Time
+}
+
+type Authenticator interface {
+	// Authenticate validates the token and returns the identity of the client.
+	// It's called on upgrade and periodically afterward to revalidate the connection.
+	// If the token is invalid, it must return ErrUnauthorized (or wrap it): on revalidation
+	// other errors are treated as temporary failures and the connection is kept.
+	Authenticate(ctx context.Context, token string) (*Identity, error)
+}
+
+type AuthenticatorFunc func(ctx context.Context, token string) (*Identity, error)
+
+func (f AuthenticatorFunc) Authenticate(ctx context.Context, token string) (*Identity, error) {
+	return f(ctx, token)
+}
+
+// TokenExtractor extracts the token from the upgrade request. If the token was taken
+// from a subprotocol, the selected subprotocol is returned too.
+type TokenExtractor func(r *http.Request) (token string, subprotocol string, err error)
+
+// TokenFromQuery extracts the token from the query parameter.
+func TokenFromQuery(name string) TokenExtractor {
+	return func(r *http.Request) (string, string, error) {
+		token := r.URL.Query().Get(name)
+		if token == "" {
+			return "", "", ErrTokenNotFound
+		}
+		return token, "", nil
+	}
+}
+
+// TokenFromHeader extracts the token from the header. If scheme is not empty
+// (e.g. "Bearer"), the header value must start with it.
+func TokenFromHeader(name string, scheme string) TokenExtractor {
+	return func(r *http.Request) (string, string, error) {
+		value := r.Header.Get(name)
+		if scheme != "" {
+			prefix := scheme + " "
+			if len(value) < len(prefix) || !strings.EqualFold(value[:len(prefix)], prefix) {
+				return "", "", ErrTokenNotFound
+			}
+			value = value[len(prefix):]
+		}
+
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return "", "", ErrTokenNotFound
+		}
+		return value, "", nil
+	}
+}
+
+// TokenFromSubprotocol extracts the token from Sec-WebSocket-Protocol header
+// sent as two subprotocols: name and the token (e.g. "access_token, <token>").
+// The name is selected as subprotocol of the connection.
+func TokenFromSubprotocol(name string) TokenExtractor {
+	return func(r *http.Request) (string, string, error) {
+		protocols := websocket.Subprotocols(r)
+		for i := 0; i < len(protocols)-1; i++ {
+			if protocols[i] == name && protocols[i+1] != "" {
+				return protocols[i+1], name, nil
+			}
+		}
+		return "", "", ErrTokenNotFound
+	}
+}
+
+// TokenFromAny tries the extractors in order and returns the first found token.
+func TokenFromAny(extractors ...TokenExtractor) TokenExtractor {
+	return func(r *http.Request) (string, string, error) {
+		for _, extractor := range extractors {
+			token, subprotocol, err := extractor(r)
+			if err == nil {
+				return token, subprotocol, nil
+			}
+		}
+		return "", "", ErrTokenNotFound
+	}
+}
+
+// RegisterAuthenticated authenticates the upgrade request with the configured authenticator
+// and registers a new session of the authenticated client.
+func (p *Pool) RegisterAuthenticated(r *http.Request, w http.ResponseWriter) (Client, error) {
+	if p.opts.Authenticator == nil {
+		return Client{}, ErrNoAuthenticator
+	}
+
+	extractor := p.opts.TokenExtractor
+	if extractor == nil {
+		extractor = TokenFromHeader("Authorization", "Bearer")
+	}
+
+	token, subprotocol, err := extractor(r)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to extract token")
+		errorHandler(w, r, http.StatusUnauthorized, ErrUnauthorized)
+		return Client{}, ErrUnauthorized
+	}
+
+	identity, err := p.opts.Authenticator.Authenticate(r.Context(), token)
+	if err != nil || identity == nil {
+		log.Debug().Err(err).Msg("failed to authenticate client")
+		errorHandler(w, r, http.StatusUnauthorized, ErrUnauthorized)
+		return Client{}, ErrUnauthorized
+	}
+
+	var respHeader http.Header
+	if subprotocol != "" {
+		respHeader = http.Header{"Sec-Websocket-Protocol": {subprotocol}}
+	}
+
+	c, err := p.register(identity.ClientId, r, w, respHeader, func(c *connection) {
+		c.token = token
+		c.identity.Store(identity)
+	})
+	if err != nil {
+		return Client{}, err
+	}
+
+	return c.client(p), nil
+}
+
+// Metadata returns the metadata of the session stored on authentication.
+func (p *Pool) Metadata(clientId string, sessionId string) (map[string]any, error) {
+	c, err := p.session(clientId, sessionId)
+	if err != nil {
+		return nil, err
+	}
+	identity := c.identity.Load()
+	if identity == nil {
+		return nil, nil
+	}
+	return identity.Metadata, nil
+}
+
+func (p *Pool) revalidateConnections() {
+	log.Debug().Msg("start revalidating connections")
+	ticker := time.NewTicker(p.opts.RevalidatePeriod)
+	defer func() {
+		ticker.Stop()
+		p.wg.Done()
+		log.Debug().Msg("connection revalidation is stopped")
+	}()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+			p.revalidateAll()
+		}
+	}
+}
+
+// revalidateAll revalidates the authenticated connections concurrently, so one slow
+// authenticator call doesn't delay the others, and waits until all checks are finished.
+func (p *Pool) revalidateAll() {
+	sem := make(chan struct{}, revalidateConcurrency)
+	var wg sync.WaitGroup
+
+	for _, c := range p.allConnections() {
+		if c.identity.Load() == nil {
+			continue
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			if err := p.revalidate(c); err != nil {
+				log.Info().Err(err).Msgf("credentials of client %s session %s are expired", c.clientId, c.sessionId)
+				p.closeConnection(c, CloseCredentialsExpired, "credentials expired", DisconnectReasonCredentialsExpired)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+// revalidate returns ErrUnauthorized if the credentials of the connection are expired, rejected
+// by the authenticator or belong to another client. Other authenticator errors are logged and
+// the connection is kept until the next check.
+func (p *Pool) revalidate(c *connection) error {
+	current := c.identity.Load()
+	if !current.ExpiresAt.IsZero() && time.Now().After(current.ExpiresAt) {
+		return ErrUnauthorized
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, defaultRevalidateTimeout)
+	defer cancel()
+
+	identity, err := p.opts.Authenticator.Authenticate(ctx, c.token)
+	if errors.Is(err, ErrUnauthorized) {
+		return ErrUnauthorized
+	}
+	if err != nil {
+		log.Warn().Err(err).Msgf("failed to revalidate client %s session %s, keep connection", c.clientId, c.sessionId)
+		return nil
+	}
+	if identity == nil || identity.ClientId != c.clientId {
+		return ErrUnauthorized
+	}
+
+	c.identity.Store(identity)
+	return nil
+}
+
+func (p *Pool) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	for _, allowed := range p.opts.AllowedOrigins {
+		switch {
+		case allowed == "*":
+			return true
+		case strings.HasPrefix(allowed, "*."):
+			// The suffix starts with the dot, so only subdomains match, the port is ignored
+			if strings.HasSuffix(strings.ToLower(u.Hostname()), strings.ToLower(allowed[1:])) {
+				return true
+			}
+		case strings.EqualFold(allowed, origin), strings.EqualFold(allowed, u.Host):
+			return true
+		}
+	}
+
+	log.Debug().Msgf("origin %s is not allowed", origin)
+	return false
+}
@@ -0,0 +1,55 @@
+package websocket
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestPool_Revalidate(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour)
+	renewed := &Identity{ClientId: "client", ExpiresAt: expiresAt.Add(time.Hour), Metadata: map[string]any{"role": "admin"}}
+
+	tests := []struct {
+		name      string
+		current   *Identity
+		identity  *Identity
+		err       error
+		wantErr   error
+		wantStore *Identity
+	}{
+		{name: "renewed", current: &Identity{ClientId: "client", ExpiresAt: expiresAt}, identity: renewed, wantStore: renewed},
+		{name: "expired", current: &Identity{ClientId: "client", ExpiresAt: time.Now().Add(-time.Second)}, identity: renewed, wantErr: ErrUnauthorized},
+		{name: "rejected", current: &Identity{ClientId: "client"}, err: errors.Wrap(ErrUnauthorized, "token revoked"), wantErr: ErrUnauthorized},
+		{name: "another client", current: &Identity{ClientId: "client"}, identity: &Identity{ClientId: "other"}, wantErr: ErrUnauthorized},
+		{name: "no identity", current: &Identity{ClientId: "client"}, wantErr: ErrUnauthorized},
+		{name: "temporary failure", current: &Identity{ClientId: "client", ExpiresAt: expiresAt}, err: errors.New("authenticator is unavailable")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPoolWithOptions(Options{Authenticator: AuthenticatorFunc(func(_ context.Context, token string) (*Identity, error) {
+				assert.Equal(t, "token", token)
+				return tt.identity, tt.err
+			})})
+
+			c := &connection{clientId: "client", token: "token", ctx: context.Background()}
+			c.identity.Store(tt.current)
+
+			err := p.revalidate(c)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			want := tt.wantStore
+			if want == nil {
+				want = tt.current
+			}
+			assert.Same(t, want, c.identity.Load())
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package websocket
+
+import (
+	"context"
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type connection struct {
+	clientId   string
+	sessionId  string
+	lang       string
+	remoteAddr string
+	createdAt  time.Time
+	token      string
+	// identity is replaced on revalidation, while handlers and admin read it
+	identity atomic.Pointer[Identity]
+	conn     *websocket.Conn
+	sendCh   chan []byte
+	resumeCh chan uint64
+	drain    chan struct{}
+	done     chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	drainOnce sync.Once
+	closeOnce sync.Once
+}
+
+func newConnection(clientId, sessionId, lang string, conn *websocket.Conn, queueSize int) *connection {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &connection{
+		clientId:   clientId,
+		sessionId:  sessionId,
+		lang:       lang,
+		conn:       conn,
+		remoteAddr: conn.RemoteAddr().String(),
+		createdAt:  time.Now(),
+		sendCh:     make(chan []byte, queueSize),
+		resumeCh:   make(chan uint64, 1),
+		drain:      make(chan struct{}),
+		done:       make(chan struct{}),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// enqueue puts the message into the connection write queue without blocking.
+// If the queue is full, ErrSlowConsumer is returned.
+func (c *connection) enqueue(msg []byte) error {
+	select {
+	case <-c.done:
+		return ErrClientNotFound
+	default:
+	}
+
+	select {
+	case c.sendCh <- msg:
+		return nil
+	case <-c.done:
+		return ErrClientNotFound
+	default:
+		return ErrSlowConsumer
+	}
+}
+
+// requestResume makes the writer send the mailbox messages after seq. The pending request
+// is replaced, so only the last requested sequence number is resumed.
+func (c *connection) requestResume(seq uint64) {
+	select {
+	case <-c.resumeCh:
+	default:
+	}
+	select {
+	case c.resumeCh <- seq:
+	default:
+	}
+}
+
+func (c *connection) client(p *Pool) Client {
+	client := Client{Id: c.clientId, SessionId: c.sessionId, pool: p}
+	if identity := c.identity.Load(); identity != nil {
+		client.Metadata = identity.Metadata
+	}
+	return client
+}
+
+// startDrain makes the writer flush the write queue and send the close frame.
+func (c *connection) startDrain() {
+	c.drainOnce.Do(func() {
+		close(c.drain)
+	})
+}
+
+func (c *connection) close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.cancel()
+		err = c.conn.Close()
+	})
+	return err
+}
+
+func (c *connection) writeLoop(p *Pool) {
+	log.Debug().Msgf("start writer of client %s session %s", c.clientId, c.sessionId)
+	ticker := time.NewTicker(p.opts.PingPeriod)
+	defer func() {
+		ticker.Stop()
+		p.wg.Done()
+		log.Debug().Msgf("writer of client %s session %s is stopped", c.clientId, c.sessionId)
+	}()
+
+	for {
+		// Missed messages are sent before the queued ones
+		select {
+		case seq := <-c.resumeCh:
+			if err := p.resume(c, seq); err != nil {
+				return
+			}
+			continue
+		default:
+		}
+
+		select {
+		case <-c.done:
+			return
+		case <-c.drain:
+			c.flush(p)
+			return
+		case msg := <-c.sendCh:
+			if err := c.write(p, msg); err != nil {
+				return
+			}
+		case seq := <-c.resumeCh:
+			if err := p.resume(c, seq); err != nil {
+				return
+			}
+		case <-ticker.C:
+			// Send time is used to measure round trip time on pong
+			sentAt := strconv.FormatInt(time.Now().UnixNano(), 10)
+			err := c.conn.WriteControl(websocket.PingMessage, []byte(sentAt), time.Now().Add(p.opts.WriteWait))
+			if err != nil {
+				log.Error().Stack().Err(err).Msgf("failed to send ping message to client %s session %s", c.clientId, c.sessionId)
+				p.unregisterConnection(c, DisconnectReasonPingError)
+				return
+			}
+		}
+	}
+}
+
+func (c *connection) write(p *Pool, msg []byte) error {
+	_ = c.conn.SetWriteDeadline(time.Now().Add(p.opts.WriteWait))
+	err := c.conn.WriteMessage(websocket.TextMessage, msg)
+	if err != nil {
+		log.Error().Stack().Err(err).Msgf("failed to send message to client %s session %s", c.clientId, c.sessionId)
+		p.opts.Metrics.writeFailed()
+		p.unregisterConnection(c, DisconnectReasonWriteError)
+		return err
+	}
+	p.opts.Metrics.sent(len(msg))
+	return nil
+}
+
+// flush writes the remaining messages of the queue, sends the close frame
+// and waits until the reader receives the close frame from the client.
+func (c *connection) flush(p *Pool) {
+	for len(c.sendCh) > 0 {
+		if err := c.write(p, <-c.sendCh); err != nil {
+			return
+		}
+	}
+
+	err := c.conn.WriteControl(
+		websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, closeReasonShutdown),
+		time.Now().Add(p.opts.WriteWait),
+	)
+	if err != nil {
+		p.unregisterConnection(c, DisconnectReasonShutdown)
+		return
+	}
+
+	<-c.done
+}
+
+func (c *connection) readLoop(p *Pool) {
+	log.Debug().Msgf("start reader of client %s session %s", c.clientId, c.sessionId)
+	defer func() {
+		p.wg.Done()
+		log.Debug().Msgf("reader of client %s session %s is stopped", c.clientId, c.sessionId)
+	}()
+
+	for {
+		_ = c.conn.SetReadDeadline(time.Now().Add(p.opts.ReadWait))
+		_, msg, err := c.conn.ReadMessage()
+		if err != nil {
+			// During normal close connection `conn.ReadMessage` returns error with code `websocket.CloseNormalClosure`
+			// (or `websocket.CloseGoingAway` on shutdown). To don`t print log in this case we check the close code
+			isNormalClose := websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway)
+
+			select {
+			case <-c.done:
+			default:
+				if !isNormalClose {
+					log.Error().Stack().Err(err).Msg("failed to receive client message")
+				}
+			}
+
+			reason := DisconnectReasonReadError
+			switch {
+			case p.closed.Load():
+				reason = DisconnectReasonShutdown
+			case isNormalClose:
+				reason = DisconnectReasonClientClose
+			}
+			p.unregisterConnection(c, reason)
+			return
+		}
+		p.opts.Metrics.received(len(msg))
+
+		clientMsg := NewClientMessage(c.clientId, c.sessionId, msg)
+		for _, h := range p.handlers {
+			h(clientMsg)
+		}
+		if r := p.router.Load(); r != nil {
+			r.dispatch(p, c, msg)
+		} else if seq, ok := resumeSeq(msg); ok && p.opts.Mailbox != nil {
+			// Without the message protocol only resume frames are handled
+			c.requestResume(seq)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package websocket
+
+import (
+	"context"
+	"github.com/goccy/go-json"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// MessageTypeMessage is the type of the envelope wrapping raw messages sent by Pool.Send
+	// when the mailbox is enabled.
+	MessageTypeMessage = "message"
+	// MessageTypeResume is the type of the message sent by the client to receive the messages
+	// missed after the sequence number {"type":"resume","data":{"seq":42}}.
+	MessageTypeResume = "resume"
+
+	lastSeqQueryParam = "lastSeq"
+)
+
+// Mailbox stores messages sent to clients so that they can be redelivered after reconnect.
+//
+// When the mailbox is enabled, every message sent by Pool.Send and Pool.SendJSON is delivered
+// as an envelope with the sequence number. On reconnect the client passes the last seen
+// sequence number in the lastSeq query parameter of the upgrade request (or sends the resume
+// message) and receives the missed messages. Messages may be delivered twice around reconnect,
+// so clients should skip already seen sequence numbers.
+type Mailbox interface {
+	// Append assigns the next sequence number of the client to the message and stores it.
+	Append(ctx context.Context, clientId string, env Envelope) (uint64, error)
+	// Since returns the stored messages of the client with sequence number greater than seq.
+	Since(ctx context.Context, clientId string, seq uint64) ([]Envelope, error)
+}
+
+type resumePayload struct {
+	Seq uint64 `json:"seq"`
+}
+
+func (p *Pool) sendEnvelope(clientId string, env Envelope) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.opts.WriteWait)
+	defer cancel()
+
+	seq, err := p.opts.Mailbox.Append(ctx, clientId, env)
+	if err != nil {
+		return err
+	}
+	env.Seq = seq
+
+	frame, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+
+	err = p.send(clientId, frame)
+	if errors.Is(err, ErrClientNotFound) {
+		// The message is stored in the mailbox and will be delivered after reconnect
+		log.Debug().Msgf("client %s is offline, message %d is stored in mailbox", clientId, seq)
+		return nil
+	}
+	return err
+}
+
+// resume writes to the connection the messages stored in the mailbox after seq. It's called
+// by the writer, so the missed messages bypass the write queue and the slow consumer policy.
+// The write error is returned, the mailbox error is only logged.
+func (p *Pool) resume(c *connection, seq uint64) error {
+	log.Debug().Msgf("resume client %s session %s from %d", c.clientId, c.sessionId, seq)
+
+	ctx, cancel := context.WithTimeout(c.ctx, p.opts.WriteWait)
+	defer cancel()
+
+	envs, err := p.opts.Mailbox.Since(ctx, c.clientId, seq)
+	if err != nil {
+		log.Error().Stack().Err(err).Msgf("failed to get missed messages of client %s", c.clientId)
+		return nil
+	}
+
+	for _, env := range envs {
+		frame, err := json.Marshal(env)
+		if err != nil {
+			log.Error().Stack().Err(err).Msg("failed to encode missed message")
+			continue
+		}
+		if err := c.write(p, frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resumeSeq returns the sequence number of the resume frame.
+func resumeSeq(msg []byte) (uint64, bool) {
+	var env struct {
+		Type string        `json:"type"`
+		Data resumePayload `json:"data"`
+	}
+	if err := json.Unmarshal(msg, &env); err != nil || env.Type != MessageTypeResume {
+		return 0, false
+	}
+	return env.Data.Seq, true
+}
+
+func requestLastSeq(r *http.Request) (uint64, bool) {
+	value := r.URL.Query().Get(lastSeqQueryParam)
+	if value == "" {
+		return 0, false
+	}
+
+	seq, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		log.Debug().Err(err).Msgf("invalid last sequence number %s", value)
+		return 0, false
+	}
+	return seq, true
+}
+
+// rawEnvelopeData converts the raw message to the envelope data: JSON is embedded as is,
+// other payloads are encoded as JSON string.
+func rawEnvelopeData(msg []byte) json.RawMessage {
+	if json.Valid(msg) {
+		return msg
+	}
+	data, _ := json.Marshal(string(msg))
+	return data
+}
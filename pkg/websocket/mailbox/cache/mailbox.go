@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"context"
+	"github.com/mandarine-io/baselib/pkg/storage/cache"
+	"github.com/mandarine-io/baselib/pkg/websocket"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"sync"
+	"time"
+)
+
+const (
+	keyPrefix = "websocket:mailbox:"
+	seqSuffix = ":seq"
+
+	// seqTTL is the expiration of the sequence counter. It's much longer than the expiration
+	// of messages, so the sequence numbers don't restart after the messages expired.
+	seqTTL = 365 * 24 * time.Hour
+)
+
+type mailbox struct {
+	mu      sync.Mutex
+	manager cache.Manager
+	size    int
+	ttl     time.Duration
+}
+
+// NewMailbox creates the mailbox keeping up to size last messages of every client
+// in the cache manager. The messages of a client expire after ttl without new messages,
+// the sequence counter is kept separately for a year.
+//
+// Read-modify-write of the cache entry is synchronized only within the process,
+// so the mailbox must not be shared by several replicas. Use the Redis mailbox instead.
+func NewMailbox(manager cache.Manager, size int, ttl time.Duration) websocket.Mailbox {
+	return &mailbox{manager: manager, size: size, ttl: ttl}
+}
+
+func (m *mailbox) Append(ctx context.Context, clientId string, env websocket.Envelope) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	log.Debug().Msgf("append message to mailbox of client %s", clientId)
+
+	var seq uint64
+	err := m.manager.Get(ctx, keyPrefix+clientId+seqSuffix, &seq)
+	if err != nil && !errors.Is(err, cache.ErrCacheEntryNotFound) {
+		return 0, err
+	}
+	messages, err := m.load(ctx, clientId)
+	if err != nil {
+		return 0, err
+	}
+
+	seq++
+	env.Seq = seq
+	messages = append(messages, env)
+	if len(messages) > m.size {
+		messages = messages[len(messages)-m.size:]
+	}
+
+	if err := m.manager.SetWithExpiration(ctx, keyPrefix+clientId+seqSuffix, seq, seqTTL); err != nil {
+		return 0, err
+	}
+	if err := m.manager.SetWithExpiration(ctx, keyPrefix+clientId, messages, m.ttl); err != nil {
+		return 0, err
+	}
+
+	return seq, nil
+}
+
+func (m *mailbox) Since(ctx context.Context, clientId string, seq uint64) ([]websocket.Envelope, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	log.Debug().Msgf("get messages of client %s since %d", clientId, seq)
+
+	messages, err := m.load(ctx, clientId)
+	if err != nil {
+		return nil, err
+	}
+
+	envs := make([]websocket.Envelope, 0)
+	for _, env := range messages {
+		if env.Seq > seq {
+			envs = append(envs, env)
+		}
+	}
+	return envs, nil
+}
+
+func (m *mailbox) load(ctx context.Context, clientId string) ([]websocket.Envelope, error) {
+	var messages []websocket.Envelope
+	err := m.manager.Get(ctx, keyPrefix+clientId, &messages)
+	if errors.Is(err, cache.ErrCacheEntryNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Copy messages to not modify the value stored in the in-memory cache
+	return append([]websocket.Envelope(nil), messages...), nil
+}
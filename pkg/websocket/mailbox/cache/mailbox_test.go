@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"context"
+	"github.com/mandarine-io/baselib/pkg/storage/cache"
+	"github.com/mandarine-io/baselib/pkg/storage/cache/memory"
+	"github.com/mandarine-io/baselib/pkg/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// expiringManager records the expiration of the keys, so the test expires them without waiting.
+type expiringManager struct {
+	cache.Manager
+	expirations map[string]time.Duration
+}
+
+func (m *expiringManager) SetWithExpiration(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	m.expirations[key] = expiration
+	return m.Manager.SetWithExpiration(ctx, key, value, expiration)
+}
+
+// advance deletes the keys that expire within d.
+func (m *expiringManager) advance(ctx context.Context, d time.Duration) error {
+	for key, expiration := range m.expirations {
+		if expiration <= d {
+			if err := m.Manager.Delete(ctx, key); err != nil {
+				return err
+			}
+			delete(m.expirations, key)
+		}
+	}
+	return nil
+}
+
+func TestMailbox_ResumeAfterExpiry(t *testing.T) {
+	ctx := context.Background()
+	manager := &expiringManager{Manager: memory.NewManager(time.Hour), expirations: make(map[string]time.Duration)}
+	mb := NewMailbox(manager, 10, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		_, err := mb.Append(ctx, "client", websocket.Envelope{Type: websocket.MessageTypeMessage})
+		require.NoError(t, err)
+	}
+
+	// The client disconnects with the last seen sequence number 2 and the messages expire
+	require.NoError(t, manager.advance(ctx, time.Minute))
+	envs, err := mb.Since(ctx, "client", 0)
+	require.NoError(t, err)
+	assert.Empty(t, envs)
+
+	seq, err := mb.Append(ctx, "client", websocket.Envelope{Type: websocket.MessageTypeMessage})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), seq)
+
+	// The client reconnects and receives the new message
+	envs, err = mb.Since(ctx, "client", 2)
+	require.NoError(t, err)
+	require.Len(t, envs, 1)
+	assert.Equal(t, uint64(3), envs[0].Seq)
+}
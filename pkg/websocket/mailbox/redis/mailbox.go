@@ -0,0 +1,85 @@
+package redis
+
+import (
+	"context"
+	"github.com/goccy/go-json"
+	"github.com/mandarine-io/baselib/pkg/websocket"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+	"strconv"
+	"time"
+)
+
+const keyPrefix = "websocket:mailbox:"
+
+type mailbox struct {
+	rdb  redis.UniversalClient
+	size int64
+	ttl  time.Duration
+}
+
+// NewMailbox creates the mailbox keeping up to size last messages of every client
+// in a Redis sorted set scored by sequence number. The messages of a client expire
+// after ttl without new messages. The sequence counter doesn't expire, so the sequence
+// numbers keep growing after the messages expired and clients don't skip new messages.
+func NewMailbox(rdb redis.UniversalClient, size int, ttl time.Duration) websocket.Mailbox {
+	return &mailbox{rdb: rdb, size: int64(size), ttl: ttl}
+}
+
+func (m *mailbox) Append(ctx context.Context, clientId string, env websocket.Envelope) (uint64, error) {
+	log.Debug().Msgf("append message to mailbox of client %s", clientId)
+
+	seq, err := m.rdb.Incr(ctx, seqKey(clientId)).Uint64()
+	if err != nil {
+		return 0, err
+	}
+
+	env.Seq = seq
+	member, err := json.Marshal(env)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = m.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, messagesKey(clientId), redis.Z{Score: float64(seq), Member: member})
+		pipe.ZRemRangeByRank(ctx, messagesKey(clientId), 0, -m.size-1)
+		pipe.Expire(ctx, messagesKey(clientId), m.ttl)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return seq, nil
+}
+
+func (m *mailbox) Since(ctx context.Context, clientId string, seq uint64) ([]websocket.Envelope, error) {
+	log.Debug().Msgf("get messages of client %s since %d", clientId, seq)
+
+	members, err := m.rdb.ZRangeByScore(ctx, messagesKey(clientId), &redis.ZRangeBy{
+		Min: "(" + strconv.FormatUint(seq, 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	envs := make([]websocket.Envelope, 0, len(members))
+	for _, member := range members {
+		var env websocket.Envelope
+		if err := json.Unmarshal([]byte(member), &env); err != nil {
+			return nil, err
+		}
+		envs = append(envs, env)
+	}
+
+	return envs, nil
+}
+
+func messagesKey(clientId string) string {
+	return keyPrefix + clientId
+}
+
+func seqKey(clientId string) string {
+	return keyPrefix + clientId + ":seq"
+}
@@ -0,0 +1,39 @@
+package redis
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/mandarine-io/baselib/pkg/websocket"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestMailbox_ResumeAfterExpiry(t *testing.T) {
+	ctx := context.Background()
+	srv := miniredis.RunT(t)
+	mb := NewMailbox(redis.NewClient(&redis.Options{Addr: srv.Addr()}), 10, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		_, err := mb.Append(ctx, "client", websocket.Envelope{Type: websocket.MessageTypeMessage})
+		require.NoError(t, err)
+	}
+
+	// The client disconnects with the last seen sequence number 2 and the messages expire
+	srv.FastForward(2 * time.Minute)
+	envs, err := mb.Since(ctx, "client", 0)
+	require.NoError(t, err)
+	assert.Empty(t, envs)
+
+	seq, err := mb.Append(ctx, "client", websocket.Envelope{Type: websocket.MessageTypeMessage})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), seq)
+
+	// The client reconnects and receives the new message
+	envs, err = mb.Since(ctx, "client", 2)
+	require.NoError(t, err)
+	require.Len(t, envs, 1)
+	assert.Equal(t, uint64(3), envs[0].Seq)
+}
@@ -1,14 +1,16 @@
 package websocket
 
 type ClientMessage struct {
-	ClientId string `json:"clientId"`
-	Payload  []byte `json:"payload"`
+	ClientId  string `json:"clientId"`
+	SessionId string `json:"sessionId"`
+	Payload   []byte `json:"payload"`
 }
 
-func NewClientMessage(clientId string, payload []byte) ClientMessage {
+func NewClientMessage(clientId string, sessionId string, payload []byte) ClientMessage {
 	return ClientMessage{
-		ClientId: clientId,
-		Payload:  payload,
+		ClientId:  clientId,
+		SessionId: sessionId,
+		Payload:   payload,
 	}
 }
 
@@ -0,0 +1,152 @@
+package websocket
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	DisconnectReasonClientClose        = "client_close"
+	DisconnectReasonReadError          = "read_error"
+	DisconnectReasonWriteError         = "write_error"
+	DisconnectReasonPingError          = "ping_error"
+	DisconnectReasonSlowConsumer       = "slow_consumer"
+	DisconnectReasonUnregister         = "unregister"
+	DisconnectReasonCredentialsExpired = "credentials_expired"
+	DisconnectReasonShutdown           = "shutdown"
+)
+
+// Metrics is the set of Prometheus collectors of the pool. Register it in the Prometheus
+// registry and pass to Options.Metrics. Several pools can be distinguished by namespace.
+type Metrics struct {
+	connections      prometheus.Gauge
+	connectionsTotal prometheus.Counter
+	disconnects      *prometheus.CounterVec
+	messagesIn       prometheus.Counter
+	messagesOut      prometheus.Counter
+	bytesIn          prometheus.Counter
+	bytesOut         prometheus.Counter
+	writeFailures    prometheus.Counter
+	droppedMessages  prometheus.Counter
+	pingRTT          prometheus.Histogram
+}
+
+func NewMetrics(namespace string) *Metrics {
+	const subsystem = "websocket"
+	return &Metrics{
+		connections: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: subsystem,
+			Name: "connections", Help: "Number of open websocket connections.",
+		}),
+		connectionsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: subsystem,
+			Name: "connections_total", Help: "Total number of accepted websocket connections.",
+		}),
+		disconnects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: subsystem,
+			Name: "disconnects_total", Help: "Total number of closed websocket connections by reason.",
+		}, []string{"reason"}),
+		messagesIn: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: subsystem,
+			Name: "messages_received_total", Help: "Total number of messages received from clients.",
+		}),
+		messagesOut: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: subsystem,
+			Name: "messages_sent_total", Help: "Total number of messages sent to clients.",
+		}),
+		bytesIn: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: subsystem,
+			Name: "received_bytes_total", Help: "Total size of messages received from clients.",
+		}),
+		bytesOut: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: subsystem,
+			Name: "sent_bytes_total", Help: "Total size of messages sent to clients.",
+		}),
+		writeFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: subsystem,
+			Name: "write_failures_total", Help: "Total number of failed writes to clients.",
+		}),
+		droppedMessages: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: subsystem,
+			Name: "dropped_messages_total", Help: "Total number of messages dropped because of full send queues.",
+		}),
+		pingRTT: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: subsystem,
+			Name: "ping_rtt_seconds", Help: "Round trip time of ping messages.",
+			Buckets: prometheus.ExponentialBuckets(0.005, 2, 12),
+		}),
+	}
+}
+
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range m.collectors() {
+		c.Describe(ch)
+	}
+}
+
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range m.collectors() {
+		c.Collect(ch)
+	}
+}
+
+func (m *Metrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		m.connections, m.connectionsTotal, m.disconnects, m.messagesIn, m.messagesOut,
+		m.bytesIn, m.bytesOut, m.writeFailures, m.droppedMessages, m.pingRTT,
+	}
+}
+
+// The methods below are nil-safe, so the pool doesn't check whether metrics are enabled.
+
+func (m *Metrics) connected() {
+	if m == nil {
+		return
+	}
+	m.connections.Inc()
+	m.connectionsTotal.Inc()
+}
+
+func (m *Metrics) disconnected(reason string) {
+	if m == nil {
+		return
+	}
+	m.connections.Dec()
+	m.disconnects.WithLabelValues(reason).Inc()
+}
+
+func (m *Metrics) received(size int) {
+	if m == nil {
+		return
+	}
+	m.messagesIn.Inc()
+	m.bytesIn.Add(float64(size))
+}
+
+func (m *Metrics) sent(size int) {
+	if m == nil {
+		return
+	}
+	m.messagesOut.Inc()
+	m.bytesOut.Add(float64(size))
+}
+
+func (m *Metrics) writeFailed() {
+	if m == nil {
+		return
+	}
+	m.writeFailures.Inc()
+}
+
+func (m *Metrics) dropped() {
+	if m == nil {
+		return
+	}
+	m.droppedMessages.Inc()
+}
+
+func (m *Metrics) pong(rttSeconds float64) {
+	if m == nil {
+		return
+	}
+	m.pingRTT.Observe(rttSeconds)
+}
@@ -0,0 +1,95 @@
+package websocket
+
+import (
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"time"
+)
+
+const (
+	defaultPingPeriod      = 30 * time.Second
+	defaultWriteWait       = 1 * time.Minute
+	defaultReadWait        = 1 * time.Minute
+	defaultReadBufferSize  = 1024
+	defaultWriteBufferSize = 1024
+	defaultSendQueueSize   = 256
+
+	closeReasonShutdown = "server shutdown"
+)
+
+// SlowConsumerPolicy defines what the pool does with a client whose send queue is full.
+type SlowConsumerPolicy int
+
+const (
+	// SlowConsumerDisconnect closes the connection of the slow client.
+	SlowConsumerDisconnect SlowConsumerPolicy = iota
+	// SlowConsumerDropMessage drops the message and keeps the connection open.
+	SlowConsumerDropMessage
+)
+
+type Options struct {
+	// Size is the maximum number of registered sessions.
+	Size int
+	// SendQueueSize is the capacity of the per-connection write queue.
+	SendQueueSize int
+	// SlowConsumerPolicy is applied when the write queue of a client is full.
+	SlowConsumerPolicy SlowConsumerPolicy
+	// Mailbox stores messages sent to clients for redelivery after reconnect. If nil, messages
+	// sent to offline clients are lost. It doesn't enable the JSON message protocol,
+	// resume frames are handled on any pool.
+	Mailbox Mailbox
+	// Metrics collects Prometheus metrics of the pool. If nil, metrics are not collected.
+	Metrics *Metrics
+	// Bundle is used to localize error frames of the message protocol. If nil, messages are not localized.
+	Bundle *i18n.Bundle
+
+	// PingPeriod is the period of ping messages sent to clients.
+	PingPeriod time.Duration
+	// WriteWait is the time allowed to write a message to a client.
+	WriteWait time.Duration
+	// ReadWait is the time allowed to read the next message or pong from a client.
+	// It should be greater than PingPeriod.
+	ReadWait time.Duration
+	// ReadBufferSize and WriteBufferSize are the sizes of the connection I/O buffers in bytes.
+	ReadBufferSize  int
+	WriteBufferSize int
+	// DisableCompression disables per-message compression negotiation.
+	DisableCompression bool
+	// ReadLimit is the maximum size of an incoming message in bytes. If zero, there is no limit.
+	ReadLimit int64
+	// MaxMessageSize is the maximum size of an outgoing message in bytes. If zero, there is no limit.
+	MaxMessageSize int64
+
+	// AllowedOrigins is the list of origins allowed to connect: full origins ("https://example.com"),
+	// hosts ("example.com"), subdomain wildcards ("*.example.com") or "*" for any origin.
+	// If empty, only same-origin requests are allowed.
+	AllowedOrigins []string
+	// Authenticator is used by RegisterAuthenticated to authenticate clients.
+	Authenticator Authenticator
+	// TokenExtractor extracts the token from the upgrade request. Defaults to the bearer token
+	// from the Authorization header.
+	TokenExtractor TokenExtractor
+	// RevalidatePeriod is the period of credentials revalidation. If zero, connections are not revalidated.
+	RevalidatePeriod time.Duration
+}
+
+func (o Options) withDefaults() Options {
+	if o.SendQueueSize <= 0 {
+		o.SendQueueSize = defaultSendQueueSize
+	}
+	if o.PingPeriod <= 0 {
+		o.PingPeriod = defaultPingPeriod
+	}
+	if o.WriteWait <= 0 {
+		o.WriteWait = defaultWriteWait
+	}
+	if o.ReadWait <= 0 {
+		o.ReadWait = defaultReadWait
+	}
+	if o.ReadBufferSize <= 0 {
+		o.ReadBufferSize = defaultReadBufferSize
+	}
+	if o.WriteBufferSize <= 0 {
+		o.WriteBufferSize = defaultWriteBufferSize
+	}
+	return o
+}
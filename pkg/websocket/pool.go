@@ -5,24 +5,25 @@ import (
 	syserrors "errors"
 	"fmt"
 	"github.com/goccy/go-json"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	"github.com/mandarine-io/baselib/pkg/transport/http/model"
-	"github.com/pkg/errors"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
 	"github.com/rs/zerolog/log"
 	"net/http"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-const (
-	pingPeriod = 30 * time.Second
-	writeWait  = 1 * time.Minute
-	readWait   = 1 * time.Minute
-)
-
 var (
-	ErrPoolIsFull     = fmt.Errorf("pool is full")
-	ErrClientNotFound = fmt.Errorf("client not found")
+	ErrPoolIsFull      = fmt.Errorf("pool is full")
+	ErrPoolClosed      = fmt.Errorf("pool is closed")
+	ErrClientNotFound  = fmt.Errorf("client not found")
+	ErrSessionNotFound = fmt.Errorf("session not found")
+	ErrSlowConsumer    = fmt.Errorf("client send queue is full")
+	ErrMessageTooLarge = fmt.Errorf("message is too large")
 
 	errorHandler = func(w http.ResponseWriter, r *http.Request, status int, reason error) {
 		log.Error().Stack().Err(reason).Msg("failed to encode error response")
@@ -39,262 +40,476 @@ var (
 
 type Handler func(msg ClientMessage)
 
+// Pool keeps websocket sessions grouped by client id: one logical client (e.g. a user)
+// can have several simultaneous sessions (e.g. browser tabs).
 type Pool struct {
-	upgrader    websocket.Upgrader
-	conns       *sync.Map
-	handlers    []Handler
-	msgCh       chan ClientMessage
-	broadcastCh chan BroadcastMessage
-	size        int
-
-	cancel context.CancelFunc
+	upgrader websocket.Upgrader
+	mu       sync.RWMutex
+	clients  map[string]map[string]*connection
+	count    atomic.Int64
+	handlers []Handler
+	opts     Options
+
+	router atomic.Pointer[router]
+
+	closed atomic.Bool
+	done   chan struct{}
 	wg     sync.WaitGroup
 }
 
 func NewPool(size int) *Pool {
-	ctx, cancel := context.WithCancel(context.Background())
+	return NewPoolWithOptions(Options{Size: size})
+}
+
+func NewPoolWithOptions(opts Options) *Pool {
+	opts = opts.withDefaults()
+
 	pool := &Pool{
 		upgrader: websocket.Upgrader{
-			ReadBufferSize:    1024,
-			WriteBufferSize:   1024,
+			ReadBufferSize:    opts.ReadBufferSize,
+			WriteBufferSize:   opts.WriteBufferSize,
 			Error:             errorHandler,
-			EnableCompression: true,
+			EnableCompression: !opts.DisableCompression,
 		},
-		handlers:    make([]Handler, 0),
-		conns:       &sync.Map{},
-		msgCh:       make(chan ClientMessage),
-		broadcastCh: make(chan BroadcastMessage),
-		size:        size,
-		cancel:      cancel,
+		handlers: make([]Handler, 0),
+		clients:  make(map[string]map[string]*connection),
+		opts:     opts,
+		done:     make(chan struct{}),
+	}
+	if len(opts.AllowedOrigins) > 0 {
+		pool.upgrader.CheckOrigin = pool.checkOrigin
 	}
 
-	pool.wg.Add(3)
-	go pool.sendPingMessages(ctx)
-	go pool.sendClientMessages()
-	go pool.sendBroadcastMessages()
+	if opts.Authenticator != nil && opts.RevalidatePeriod > 0 {
+		pool.wg.Add(1)
+		go pool.revalidateConnections()
+	}
 
 	return pool
 }
 
-func (p *Pool) Register(id string, r *http.Request, w http.ResponseWriter) error {
-	log.Debug().Msgf("register client %s", id)
-
-	if lenSyncMap(p.conns) >= p.size {
-		errorHandler(w, r, http.StatusServiceUnavailable, ErrPoolIsFull)
-		return ErrPoolIsFull
-	}
-
-	conn, err := p.upgrader.Upgrade(w, r, nil)
+// Register upgrades the request to websocket and adds a new session of the client.
+// It returns the generated session id.
+func (p *Pool) Register(clientId string, r *http.Request, w http.ResponseWriter) (string, error) {
+	c, err := p.register(clientId, r, w, nil, nil)
 	if err != nil {
-		return err
+		return "", err
 	}
+	return c.sessionId, nil
+}
 
-	conn.SetPingHandler(func(string) error {
-		log.Debug().Msgf("ping client %s", id)
-		_ = conn.WriteMessage(websocket.PongMessage, []byte("pong"))
-		return nil
-	})
-	conn.SetPongHandler(func(string) error {
-		log.Debug().Msgf("pong client %s", id)
-		_ = conn.SetReadDeadline(time.Now().Add(readWait))
-		return nil
-	})
-	conn.SetCloseHandler(func(int, string) error {
-		log.Debug().Msgf("close client %s", id)
-		_ = p.Unregister(id)
-		return nil
-	})
+// Unregister closes all sessions of the client.
+func (p *Pool) Unregister(clientId string) error {
+	log.Debug().Msgf("unregister client %s", clientId)
 
-	p.conns.Store(id, conn)
+	p.mu.Lock()
+	sessions, ok := p.clients[clientId]
+	if ok {
+		delete(p.clients, clientId)
+		p.count.Add(-int64(len(sessions)))
+	}
+	p.mu.Unlock()
 
-	go p.receiveClientMessages(id)
+	if !ok {
+		return ErrClientNotFound
+	}
 
-	return nil
+	var errs []error
+	for _, c := range sessions {
+		p.opts.Metrics.disconnected(DisconnectReasonUnregister)
+		if err := c.close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return syserrors.Join(errs...)
 }
 
-func (p *Pool) Unregister(id string) error {
-	log.Debug().Msgf("unregister client %s", id)
+// UnregisterSession closes the single session of the client.
+func (p *Pool) UnregisterSession(clientId string, sessionId string) error {
+	log.Debug().Msgf("unregister client %s session %s", clientId, sessionId)
 
-	conn, ok := p.conns.LoadAndDelete(id)
-	if !ok {
-		return ErrClientNotFound
+	c, err := p.session(clientId, sessionId)
+	if err != nil {
+		return err
 	}
+	p.unregisterConnection(c, DisconnectReasonUnregister)
 
-	return conn.(*websocket.Conn).Close()
+	return nil
 }
 
+// Count returns the number of registered sessions.
 func (p *Pool) Count() int {
-	return lenSyncMap(p.conns)
+	return int(p.count.Load())
+}
+
+// ClientCount returns the number of clients with at least one session.
+func (p *Pool) ClientCount() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.clients)
+}
+
+// Sessions returns the session ids of the client.
+func (p *Pool) Sessions(clientId string) []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	sessions := p.clients[clientId]
+	ids := make([]string, 0, len(sessions))
+	for id := range sessions {
+		ids = append(ids, id)
+	}
+	return ids
 }
 
 func (p *Pool) RegisterHandler(h Handler) {
 	p.handlers = append(p.handlers, h)
 }
 
-func (p *Pool) Send(clientId string, msg []byte) {
+// Send puts the message into the write queue of every session of the client.
+// It never blocks on slow clients: if the client is not connected ErrClientNotFound is returned,
+// if the queue of some session is full ErrSlowConsumer is returned and the slow
+// consumer policy is applied to that session.
+//
+// If the mailbox is enabled, the message is stored and delivered as an envelope with
+// the sequence number, and sending to an offline client succeeds.
+func (p *Pool) Send(clientId string, msg []byte) error {
 	log.Debug().Msg("send client message")
-	p.msgCh <- NewClientMessage(clientId, msg)
-}
 
-func (p *Pool) Broadcast(msg []byte) {
-	log.Debug().Msg("send broadcast message")
-	p.broadcastCh <- NewBroadcastMessage(msg)
+	if p.opts.Mailbox != nil {
+		if err := p.checkSend(msg); err != nil {
+			return err
+		}
+		return p.sendEnvelope(clientId, Envelope{Type: MessageTypeMessage, Data: rawEnvelopeData(msg)})
+	}
+
+	return p.send(clientId, msg)
 }
 
-func (p *Pool) Close() error {
-	// Close all connections
-	var errs []error
-	p.conns.Range(func(k, v interface{}) bool {
-		clientId := k.(string)
-		conn := v.(*websocket.Conn)
+func (p *Pool) send(clientId string, msg []byte) error {
+	if err := p.checkSend(msg); err != nil {
+		return err
+	}
+
+	conns := p.clientConnections(clientId)
+	if len(conns) == 0 {
+		return ErrClientNotFound
+	}
 
-		if err := conn.Close(); err != nil {
+	var errs []error
+	for _, c := range conns {
+		if err := p.enqueue(c, msg); err != nil {
 			errs = append(errs, err)
 		}
-		p.conns.Delete(clientId)
-		return true
-	})
-	log.Debug().Msg("all websocket connections are closed")
+	}
 
-	// Close channels
-	close(p.msgCh)
-	close(p.broadcastCh)
+	return syserrors.Join(errs...)
+}
 
-	// Cancel context
-	p.cancel()
+// SendSession puts the message into the write queue of the single session of the client.
+func (p *Pool) SendSession(clientId string, sessionId string, msg []byte) error {
+	log.Debug().Msg("send session message")
 
-	p.wg.Wait()
+	if err := p.checkSend(msg); err != nil {
+		return err
+	}
+
+	c, err := p.session(clientId, sessionId)
+	if err != nil {
+		return err
+	}
+
+	return p.enqueue(c, msg)
+}
+
+// Broadcast puts the message into the write queue of every session. Sessions
+// with a full queue are handled according to the slow consumer policy.
+func (p *Pool) Broadcast(msg []byte) error {
+	log.Debug().Msg("send broadcast message")
+
+	if err := p.checkSend(msg); err != nil {
+		return err
+	}
+
+	for _, c := range p.allConnections() {
+		_ = p.enqueue(c, msg)
+	}
+
+	return nil
+}
 
-	if len(errs) > 0 {
-		return syserrors.Join(errs...)
+// Close closes all connections immediately with the going away close frame.
+// Use Shutdown to drain write queues and wait for in-flight handlers.
+func (p *Pool) Close() error {
+	if !p.markClosed() {
+		return ErrPoolClosed
 	}
+	close(p.done)
+
+	p.closeAll()
+	p.wg.Wait()
 
 	return nil
 }
 
-func (p *Pool) sendPingMessages(ctx context.Context) {
-	log.Debug().Msg("start sending ping messages")
-	ticker := time.NewTicker(pingPeriod)
-	defer func() {
-		ticker.Stop()
-		p.wg.Done()
-		log.Debug().Msg("ping message sender is stopped")
+// Shutdown gracefully stops the pool: it stops accepting registrations and messages,
+// flushes the write queues, sends the going away close frame to every session and waits
+// until clients close connections and in-flight handlers return. If ctx is done
+// earlier, the remaining connections are closed immediately and ctx error is returned.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	if !p.markClosed() {
+		return ErrPoolClosed
+	}
+	close(p.done)
+
+	log.Debug().Msg("shutdown websocket pool")
+	for _, c := range p.allConnections() {
+		c.startDrain()
+	}
+
+	doneCh := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(doneCh)
 	}()
 
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			p.conns.Range(func(k, v interface{}) bool {
-				clientId := k.(string)
-				conn := v.(*websocket.Conn)
-
-				_ = conn.SetWriteDeadline(time.Now().Add(writeWait))
-				err := conn.WriteMessage(websocket.PingMessage, nil)
-				if err != nil {
-					log.Error().Stack().Err(err).Msg("failed to send ping message")
-					_ = p.Unregister(clientId)
-				}
-
-				return true
-			})
-		}
+	select {
+	case <-doneCh:
+		log.Debug().Msg("all websocket connections are closed")
+		return nil
+	case <-ctx.Done():
+		log.Warn().Msg("websocket pool shutdown deadline exceeded, close remaining connections")
+		p.closeAll()
+		return ctx.Err()
 	}
 }
 
-func (p *Pool) sendClientMessages() {
-	log.Debug().Msg("start sending client messages")
-	defer func() {
-		p.wg.Done()
-		log.Debug().Msg("client message sender is stopped")
-	}()
+func (p *Pool) register(
+	clientId string, r *http.Request, w http.ResponseWriter, respHeader http.Header, init func(c *connection),
+) (*connection, error) {
+	log.Debug().Msgf("register client %s", clientId)
 
-	for {
-		clientMsg, ok := <-p.msgCh
-		if !ok {
-			return
-		}
+	if p.closed.Load() {
+		errorHandler(w, r, http.StatusServiceUnavailable, ErrPoolClosed)
+		return nil, ErrPoolClosed
+	}
+	if p.count.Load() >= int64(p.opts.Size) {
+		errorHandler(w, r, http.StatusServiceUnavailable, ErrPoolIsFull)
+		return nil, ErrPoolIsFull
+	}
 
-		connAny, ok := p.conns.Load(clientMsg.ClientId)
-		if !ok {
-			continue
-		}
-		conn := connAny.(*websocket.Conn)
+	conn, err := p.upgrader.Upgrade(w, r, respHeader)
+	if err != nil {
+		return nil, err
+	}
+	if p.opts.ReadLimit > 0 {
+		conn.SetReadLimit(p.opts.ReadLimit)
+	}
 
-		_ = conn.SetWriteDeadline(time.Now().Add(writeWait))
-		err := conn.WriteMessage(websocket.TextMessage, clientMsg.Payload)
-		if err != nil {
-			log.Error().Stack().Err(err).Msg("failed to send client message")
-			_ = p.Unregister(clientMsg.ClientId)
+	sessionId := uuid.NewString()
+	c := newConnection(clientId, sessionId, requestLang(r), conn, p.opts.SendQueueSize)
+	if init != nil {
+		init(c)
+	}
+
+	conn.SetPingHandler(func(string) error {
+		log.Debug().Msgf("ping client %s session %s", clientId, sessionId)
+		_ = conn.WriteControl(websocket.PongMessage, []byte("pong"), time.Now().Add(p.opts.WriteWait))
+		return nil
+	})
+	conn.SetPongHandler(func(appData string) error {
+		log.Debug().Msgf("pong client %s session %s", clientId, sessionId)
+		_ = conn.SetReadDeadline(time.Now().Add(p.opts.ReadWait))
+		if sentAt, err := strconv.ParseInt(appData, 10, 64); err == nil {
+			p.opts.Metrics.pong(time.Since(time.Unix(0, sentAt)).Seconds())
 		}
+		return nil
+	})
+	conn.SetCloseHandler(func(int, string) error {
+		log.Debug().Msgf("close client %s session %s", clientId, sessionId)
+		p.unregisterConnection(c, DisconnectReasonClientClose)
+		return nil
+	})
+
+	// The pool may be closed during the upgrade. The check and the wait group are updated
+	// under the lock, so Close and Shutdown either see the connection or it's rejected here.
+	p.mu.Lock()
+	if p.closed.Load() {
+		p.mu.Unlock()
+		_ = conn.WriteControl(
+			websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, closeReasonShutdown),
+			time.Now().Add(p.opts.WriteWait),
+		)
+		_ = c.close()
+		return nil, ErrPoolClosed
+	}
+	sessions, ok := p.clients[clientId]
+	if !ok {
+		sessions = make(map[string]*connection)
+		p.clients[clientId] = sessions
 	}
+	sessions[sessionId] = c
+	p.count.Add(1)
+	p.wg.Add(2)
+	p.mu.Unlock()
+	p.opts.Metrics.connected()
+
+	if lastSeq, ok := requestLastSeq(r); ok && p.opts.Mailbox != nil {
+		c.requestResume(lastSeq)
+	}
+
+	go c.writeLoop(p)
+	go c.readLoop(p)
+
+	return c, nil
 }
 
-func (p *Pool) sendBroadcastMessages() {
-	log.Debug().Msg("start sending broadcast messages")
-	defer func() {
-		p.wg.Done()
-		log.Debug().Msg("broadcast message sender is stopped")
-	}()
+// markClosed marks the pool closed under the lock, so no connection is registered after it.
+// It returns false if the pool is already closed.
+func (p *Pool) markClosed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closed.CompareAndSwap(false, true)
+}
+
+func (p *Pool) checkSend(msg []byte) error {
+	if p.closed.Load() {
+		return ErrPoolClosed
+	}
+	if p.opts.MaxMessageSize > 0 && int64(len(msg)) > p.opts.MaxMessageSize {
+		return ErrMessageTooLarge
+	}
+	return nil
+}
+
+func (p *Pool) session(clientId string, sessionId string) (*connection, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	sessions, ok := p.clients[clientId]
+	if !ok {
+		return nil, ErrClientNotFound
+	}
+	c, ok := sessions[sessionId]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	return c, nil
+}
 
-	for {
-		broadcastMsg, ok := <-p.broadcastCh
-		if !ok {
-			break
+func (p *Pool) enqueue(c *connection, msg []byte) error {
+	err := c.enqueue(msg)
+	if err == nil {
+		return nil
+	}
+
+	if syserrors.Is(err, ErrSlowConsumer) {
+		log.Warn().Msgf("client %s session %s is a slow consumer", c.clientId, c.sessionId)
+		if p.opts.SlowConsumerPolicy == SlowConsumerDisconnect {
+			p.unregisterConnection(c, DisconnectReasonSlowConsumer)
+		} else {
+			p.opts.Metrics.dropped()
 		}
+	}
 
-		p.conns.Range(func(k, v interface{}) bool {
-			clientId := k.(string)
-			conn := v.(*websocket.Conn)
+	return err
+}
 
-			_ = conn.SetWriteDeadline(time.Now().Add(writeWait))
-			err := conn.WriteMessage(websocket.TextMessage, broadcastMsg.Payload)
-			if err != nil {
-				log.Error().Stack().Err(err).Msg("failed to send broadcast message")
-				_ = p.Unregister(clientId)
-			}
+func (p *Pool) clientConnections(clientId string) []*connection {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
 
-			return true
-		})
+	sessions := p.clients[clientId]
+	conns := make([]*connection, 0, len(sessions))
+	for _, c := range sessions {
+		conns = append(conns, c)
 	}
+	return conns
 }
 
-func (p *Pool) receiveClientMessages(clientId string) {
-	for {
-		connAny, ok := p.conns.Load(clientId)
-		if !ok {
-			break
-		}
-		conn := connAny.(*websocket.Conn)
-
-		_ = conn.SetReadDeadline(time.Now().Add(readWait))
-		_, msg, err := conn.ReadMessage()
-		if err != nil {
-			// During normal close connection `conn.ReadMessage` returns error with code `websocket.CloseNormalClosure`
-			// To don`t print log in this case we check that error is `websocket.CloseNormalClosure`
-			var wsErr *websocket.CloseError
-			if errors.As(err, &wsErr) && wsErr.Code == websocket.CloseNormalClosure {
-				break
-			}
-
-			log.Error().Stack().Err(err).Msg("failed to receive client message")
-			_ = p.Unregister(clientId)
-			break
+func (p *Pool) allConnections() []*connection {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	conns := make([]*connection, 0, p.count.Load())
+	for _, sessions := range p.clients {
+		for _, c := range sessions {
+			conns = append(conns, c)
 		}
+	}
+	return conns
+}
 
-		clientMsg := NewClientMessage(clientId, msg)
-		for _, h := range p.handlers {
-			h(clientMsg)
+// closeConnection removes the connection from the pool and closes it with the close frame.
+func (p *Pool) closeConnection(c *connection, code int, text string, reason string) {
+	p.mu.Lock()
+	p.removeConnectionLocked(c, reason)
+	p.mu.Unlock()
+
+	_ = c.conn.WriteControl(
+		websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(p.opts.WriteWait),
+	)
+	_ = c.close()
+}
+
+func (p *Pool) closeAll() {
+	p.mu.Lock()
+	conns := make([]*connection, 0, p.count.Load())
+	for _, sessions := range p.clients {
+		for _, c := range sessions {
+			conns = append(conns, c)
 		}
 	}
+	p.clients = make(map[string]map[string]*connection)
+	p.count.Store(0)
+	p.mu.Unlock()
+
+	for _, c := range conns {
+		p.opts.Metrics.disconnected(DisconnectReasonShutdown)
+		_ = c.conn.WriteControl(
+			websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, closeReasonShutdown),
+			time.Now().Add(p.opts.WriteWait),
+		)
+		_ = c.close()
+	}
+	log.Debug().Msg("all websocket connections are closed")
 }
 
-func lenSyncMap(m *sync.Map) int {
-	var i int
-	m.Range(func(k, v interface{}) bool {
-		i++
-		return true
-	})
-	return i
+// unregisterConnection removes the connection from the pool (if it is still registered) and closes it.
+func (p *Pool) unregisterConnection(c *connection, reason string) {
+	p.mu.Lock()
+	p.removeConnectionLocked(c, reason)
+	p.mu.Unlock()
+
+	_ = c.close()
+}
+
+func (p *Pool) removeConnectionLocked(c *connection, reason string) {
+	sessions, ok := p.clients[c.clientId]
+	if !ok || sessions[c.sessionId] != c {
+		return
+	}
+
+	log.Debug().Msgf("unregister client %s session %s: %s", c.clientId, c.sessionId, reason)
+	delete(sessions, c.sessionId)
+	if len(sessions) == 0 {
+		delete(p.clients, c.clientId)
+	}
+	p.count.Add(-1)
+	p.opts.Metrics.disconnected(reason)
+}
+
+func (p *Pool) localizer(c *connection) *i18n.Localizer {
+	if p.opts.Bundle == nil {
+		return nil
+	}
+	return i18n.NewLocalizer(p.opts.Bundle, c.lang)
+}
+
+func requestLang(r *http.Request) string {
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		return lang
+	}
+	return r.Header.Get("Accept-Language")
 }
@@ -0,0 +1,316 @@
+package websocket
+
+import (
+	"context"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	waitFor = 5 * time.Second
+	tick    = 5 * time.Millisecond
+)
+
+func newServer(t *testing.T, p *Pool) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = p.Register(r.URL.Query().Get("client"), r, w)
+	}))
+	t.Cleanup(func() {
+		_ = p.Close()
+		srv.Close()
+	})
+	return srv
+}
+
+// dial connects the client and waits until the pool has count sessions.
+func dial(t *testing.T, srv *httptest.Server, p *Pool, clientId string, count int) *websocket.Conn {
+	conn, resp, err := websocket.DefaultDialer.Dial(wsURL(srv)+"?client="+clientId, nil)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	require.Eventually(t, func() bool {
+		return p.Count() == count
+	}, waitFor, tick)
+	return conn
+}
+
+func wsURL(srv *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func readText(t *testing.T, conn *websocket.Conn) string {
+	t.Helper()
+
+	_ = conn.SetReadDeadline(time.Now().Add(waitFor))
+	_, msg, err := conn.ReadMessage()
+	require.NoError(t, err)
+	return string(msg)
+}
+
+// serverConn returns the server side of the websocket connection, so the test controls its writer.
+func serverConn(t *testing.T) (*websocket.Conn, *websocket.Conn) {
+	connCh := make(chan *websocket.Conn, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err == nil {
+			connCh <- conn
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	client, resp, err := websocket.DefaultDialer.Dial(wsURL(srv), nil)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	return <-connCh, client
+}
+
+func TestPool_SendToClientSessions(t *testing.T) {
+	p := NewPool(10)
+	srv := newServer(t, p)
+
+	first := dial(t, srv, p, "a", 1)
+	second := dial(t, srv, p, "a", 2)
+	other := dial(t, srv, p, "b", 3)
+	assert.Equal(t, 2, p.ClientCount())
+	assert.Len(t, p.Sessions("a"), 2)
+
+	require.NoError(t, p.Send("a", []byte("to a")))
+	assert.Equal(t, "to a", readText(t, first))
+	assert.Equal(t, "to a", readText(t, second))
+
+	require.NoError(t, p.Broadcast([]byte("to all")))
+	assert.Equal(t, "to all", readText(t, first))
+	assert.Equal(t, "to all", readText(t, second))
+	assert.Equal(t, "to all", readText(t, other))
+
+	assert.ErrorIs(t, p.Send("missing", []byte("x")), ErrClientNotFound)
+}
+
+func TestPool_SendSession(t *testing.T) {
+	p := NewPool(10)
+	srv := newServer(t, p)
+
+	first := dial(t, srv, p, "a", 1)
+	sessionId := p.Sessions("a")[0]
+	second := dial(t, srv, p, "a", 2)
+
+	require.NoError(t, p.SendSession("a", sessionId, []byte("first only")))
+	require.NoError(t, p.Broadcast([]byte("all")))
+
+	assert.Equal(t, "first only", readText(t, first))
+	assert.Equal(t, "all", readText(t, first))
+	assert.Equal(t, "all", readText(t, second))
+
+	assert.ErrorIs(t, p.SendSession("a", "missing", []byte("x")), ErrSessionNotFound)
+	assert.ErrorIs(t, p.SendSession("missing", sessionId, []byte("x")), ErrClientNotFound)
+}
+
+func TestPool_UnregisterSessions(t *testing.T) {
+	p := NewPool(10)
+	srv := newServer(t, p)
+
+	first := dial(t, srv, p, "a", 1)
+	sessionId := p.Sessions("a")[0]
+	second := dial(t, srv, p, "a", 2)
+
+	require.NoError(t, p.UnregisterSession("a", sessionId))
+	assert.Equal(t, 1, p.Count())
+	_, _, err := first.ReadMessage()
+	assert.Error(t, err)
+
+	require.NoError(t, p.Send("a", []byte("still here")))
+	assert.Equal(t, "still here", readText(t, second))
+
+	require.NoError(t, p.Unregister("a"))
+	assert.Equal(t, 0, p.Count())
+	assert.Equal(t, 0, p.ClientCount())
+	_, _, err = second.ReadMessage()
+	assert.Error(t, err)
+
+	assert.ErrorIs(t, p.Unregister("a"), ErrClientNotFound)
+	assert.ErrorIs(t, p.UnregisterSession("a", sessionId), ErrClientNotFound)
+}
+
+func TestPool_HandlersReceiveClientMessages(t *testing.T) {
+	p := NewPool(10)
+	received := make(chan ClientMessage, 1)
+	p.RegisterHandler(func(msg ClientMessage) {
+		received <- msg
+	})
+	srv := newServer(t, p)
+
+	conn := dial(t, srv, p, "a", 1)
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("hello")))
+
+	select {
+	case msg := <-received:
+		assert.Equal(t, "a", msg.ClientId)
+		assert.Equal(t, p.Sessions("a")[0], msg.SessionId)
+		assert.Equal(t, "hello", string(msg.Payload))
+	case <-time.After(waitFor):
+		require.FailNow(t, "timeout")
+	}
+}
+
+func TestPool_MaxMessageSize(t *testing.T) {
+	p := NewPoolWithOptions(Options{Size: 10, MaxMessageSize: 4})
+	srv := newServer(t, p)
+	dial(t, srv, p, "a", 1)
+
+	assert.ErrorIs(t, p.Send("a", []byte("too long")), ErrMessageTooLarge)
+	assert.ErrorIs(t, p.Broadcast([]byte("too long")), ErrMessageTooLarge)
+	assert.NoError(t, p.Send("a", []byte("ok")))
+}
+
+func TestPool_IsFull(t *testing.T) {
+	p := NewPool(1)
+	srv := newServer(t, p)
+	dial(t, srv, p, "a", 1)
+
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL(srv)+"?client=b", nil)
+	require.Error(t, err)
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
+
+func TestPool_SlowConsumer(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     SlowConsumerPolicy
+		wantClosed bool
+	}{
+		{name: "disconnect", policy: SlowConsumerDisconnect, wantClosed: true},
+		{name: "drop message", policy: SlowConsumerDropMessage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPoolWithOptions(Options{Size: 10, SlowConsumerPolicy: tt.policy})
+			conn, client := serverConn(t)
+
+			// The writer isn't started, so the queue isn't drained
+			c := newConnection("a", "session", "", conn, 1)
+			p.clients["a"] = map[string]*connection{c.sessionId: c}
+			p.count.Add(1)
+
+			require.NoError(t, p.Send("a", []byte("queued")))
+			assert.ErrorIs(t, p.Send("a", []byte("overflow")), ErrSlowConsumer)
+
+			if tt.wantClosed {
+				assert.Equal(t, 0, p.Count())
+				assert.ErrorIs(t, p.Send("a", []byte("x")), ErrClientNotFound)
+				_ = client.SetReadDeadline(time.Now().Add(waitFor))
+				_, _, err := client.ReadMessage()
+				assert.Error(t, err)
+				return
+			}
+
+			assert.Equal(t, 1, p.Count())
+			assert.Len(t, c.sendCh, 1)
+			assert.Equal(t, "queued", string(<-c.sendCh))
+			assert.NoError(t, p.Send("a", []byte("next")))
+			_ = c.close()
+		})
+	}
+}
+
+func TestPool_ShutdownFlushesQueues(t *testing.T) {
+	p := NewPoolWithOptions(Options{Size: 10, SendQueueSize: 100})
+	srv := newServer(t, p)
+	conn := dial(t, srv, p, "a", 1)
+
+	for i := 0; i < 50; i++ {
+		require.NoError(t, p.Send("a", []byte("message")))
+	}
+
+	// The client reads the queued messages and replies to the close frame
+	received := make(chan int, 1)
+	closeCode := make(chan int, 1)
+	go func() {
+		count := 0
+		for {
+			_, _, err := conn.ReadMessage()
+			if err != nil {
+				var closeErr *websocket.CloseError
+				if errors.As(err, &closeErr) {
+					closeCode <- closeErr.Code
+				}
+				received <- count
+				return
+			}
+			count++
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), waitFor)
+	defer cancel()
+	require.NoError(t, p.Shutdown(ctx))
+
+	assert.Equal(t, 50, <-received)
+	assert.Equal(t, websocket.CloseGoingAway, <-closeCode)
+	assert.Equal(t, 0, p.Count())
+
+	assert.ErrorIs(t, p.Send("a", []byte("x")), ErrPoolClosed)
+	assert.ErrorIs(t, p.Shutdown(ctx), ErrPoolClosed)
+	assert.ErrorIs(t, p.Close(), ErrPoolClosed)
+
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL(srv)+"?client=b", nil)
+	require.Error(t, err)
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
+
+func TestPool_ShutdownClosesConnectionsAfterDeadline(t *testing.T) {
+	p := NewPool(10)
+	srv := newServer(t, p)
+
+	// The clients don't read, so they never reply to the close frame
+	dial(t, srv, p, "a", 1)
+	dial(t, srv, p, "b", 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, p.Shutdown(ctx), context.DeadlineExceeded)
+	assert.Equal(t, 0, p.Count())
+	assert.Equal(t, 0, p.ClientCount())
+}
+
+func TestPool_Close(t *testing.T) {
+	p := NewPool(10)
+	srv := newServer(t, p)
+	conn := dial(t, srv, p, "a", 1)
+
+	require.NoError(t, p.Close())
+	assert.Equal(t, 0, p.Count())
+
+	_ = conn.SetReadDeadline(time.Now().Add(waitFor))
+	_, _, err := conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), err)
+}
+
+func TestPool_Sessions(t *testing.T) {
+	p := NewPool(10)
+	srv := newServer(t, p)
+
+	dial(t, srv, p, "a", 1)
+	dial(t, srv, p, "a", 2)
+
+	sessions := p.Sessions("a")
+	sort.Strings(sessions)
+	require.Len(t, sessions, 2)
+	assert.NotEqual(t, sessions[0], sessions[1])
+	assert.Empty(t, p.Sessions("b"))
+}
@@ -0,0 +1,269 @@
+package websocket
+
+import (
+	"context"
+	"github.com/goccy/go-json"
+	"github.com/google/uuid"
+	"github.com/mandarine-io/baselib/pkg/locale"
+	"github.com/mandarine-io/baselib/pkg/transport/http/model"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"sync"
+)
+
+const (
+	MessageTypeAck   = "ack"
+	MessageTypeError = "error"
+)
+
+var (
+	ErrInvalidMessage     = model.NewI18nError("invalid message", "errors.websocket.invalid_message")
+	ErrUnknownMessageType = model.NewI18nError("unknown message type", "errors.websocket.unknown_message_type")
+	ErrInternal           = model.NewI18nError("internal error", "errors.internal_error")
+)
+
+// Envelope is a frame of the JSON message protocol.
+type Envelope struct {
+	Type string          `json:"type"`
+	ID   string          `json:"id,omitempty"`
+	Seq  uint64          `json:"seq,omitempty"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// ErrorData is the payload of an error frame.
+type ErrorData struct {
+	Message string      `json:"message"`
+	Tag     string      `json:"tag,omitempty"`
+	Args    interface{} `json:"args,omitempty"`
+}
+
+func (e *ErrorData) Error() string {
+	return e.Message
+}
+
+// Client identifies the session which sent the message.
+type Client struct {
+	Id        string
+	SessionId string
+	// Metadata is set by the authenticator on registration.
+	Metadata map[string]any
+
+	pool *Pool
+}
+
+// Send sends the typed message to this session.
+func (c Client) Send(msgType string, data any) error {
+	frame, err := encodeEnvelope(msgType, "", data)
+	if err != nil {
+		return err
+	}
+	return c.pool.SendSession(c.Id, c.SessionId, frame)
+}
+
+type TypedHandler[T any] func(ctx context.Context, client Client, payload T) error
+
+type routeHandler func(ctx context.Context, client Client, data json.RawMessage) error
+
+type router struct {
+	mu      sync.RWMutex
+	routes  map[string]routeHandler
+	pending sync.Map
+}
+
+func newRouter() *router {
+	return &router{routes: make(map[string]routeHandler)}
+}
+
+// Handle registers the handler of the message type and enables the JSON message protocol on the pool.
+// The payload of the message is decoded into T. If the message has an id, the client receives
+// an ack frame on success or an error frame on failure.
+func Handle[T any](p *Pool, msgType string, h TypedHandler[T]) {
+	log.Debug().Msgf("register websocket handler for message type %s", msgType)
+
+	r := p.messageRouter()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.routes[msgType] = func(ctx context.Context, client Client, data json.RawMessage) error {
+		var payload T
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &payload); err != nil {
+				log.Debug().Err(err).Msgf("failed to decode payload of message type %s", msgType)
+				return ErrInvalidMessage
+			}
+		}
+		return h(ctx, client, payload)
+	}
+}
+
+// SendJSON sends the typed message to every session of the client.
+func (p *Pool) SendJSON(clientId string, msgType string, data any) error {
+	env, err := newEnvelope(msgType, "", data)
+	if err != nil {
+		return err
+	}
+	if p.opts.Mailbox != nil {
+		return p.sendEnvelope(clientId, env)
+	}
+
+	frame, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	return p.send(clientId, frame)
+}
+
+// BroadcastJSON sends the typed message to every session.
+func (p *Pool) BroadcastJSON(msgType string, data any) error {
+	frame, err := encodeEnvelope(msgType, "", data)
+	if err != nil {
+		return err
+	}
+	return p.Broadcast(frame)
+}
+
+// Request sends the typed message to the session and waits until the client acknowledges it.
+// If the client replies with an error frame, *ErrorData is returned.
+func (p *Pool) Request(ctx context.Context, clientId string, sessionId string, msgType string, data any) error {
+	r := p.messageRouter()
+
+	id := uuid.NewString()
+	frame, err := encodeEnvelope(msgType, id, data)
+	if err != nil {
+		return err
+	}
+
+	replyCh := make(chan error, 1)
+	r.pending.Store(id, replyCh)
+	defer r.pending.Delete(id)
+
+	if err := p.SendSession(clientId, sessionId, frame); err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-replyCh:
+		return err
+	}
+}
+
+func (p *Pool) messageRouter() *router {
+	p.router.CompareAndSwap(nil, newRouter())
+	return p.router.Load()
+}
+
+func (r *router) dispatch(p *Pool, c *connection, msg []byte) {
+	var env Envelope
+	if err := json.Unmarshal(msg, &env); err != nil || env.Type == "" {
+		log.Debug().Msgf("invalid message from client %s session %s", c.clientId, c.sessionId)
+		r.reply(p, c, "", ErrInvalidMessage)
+		return
+	}
+
+	// Replies to requests sent by the server
+	if env.Type == MessageTypeAck || env.Type == MessageTypeError {
+		r.resolve(env)
+		return
+	}
+
+	if env.Type == MessageTypeResume && p.opts.Mailbox != nil {
+		var payload resumePayload
+		if err := json.Unmarshal(env.Data, &payload); err != nil {
+			r.reply(p, c, env.ID, ErrInvalidMessage)
+			return
+		}
+		c.requestResume(payload.Seq)
+		return
+	}
+
+	r.mu.RLock()
+	h, ok := r.routes[env.Type]
+	r.mu.RUnlock()
+	if !ok {
+		r.reply(p, c, env.ID, ErrUnknownMessageType)
+		return
+	}
+
+	err := h(c.ctx, c.client(p), env.Data)
+	if err != nil {
+		log.Error().Stack().Err(err).Msgf("failed to handle message type %s", env.Type)
+	}
+	if err != nil || env.ID != "" {
+		r.reply(p, c, env.ID, err)
+	}
+}
+
+func (r *router) resolve(env Envelope) {
+	replyChAny, ok := r.pending.LoadAndDelete(env.ID)
+	if !ok {
+		log.Debug().Msgf("unexpected reply %s", env.ID)
+		return
+	}
+
+	var err error
+	if env.Type == MessageTypeError {
+		errData := &ErrorData{}
+		if jsonErr := json.Unmarshal(env.Data, errData); jsonErr != nil {
+			errData.Message = ErrInvalidMessage.Error()
+		}
+		err = errData
+	}
+	replyChAny.(chan error) <- err
+}
+
+func (r *router) reply(p *Pool, c *connection, id string, err error) {
+	var frame []byte
+	var encErr error
+	if err == nil {
+		frame, encErr = encodeEnvelope(MessageTypeAck, id, nil)
+	} else {
+		frame, encErr = encodeEnvelope(MessageTypeError, id, newErrorData(err, p.localizer(c)))
+	}
+	if encErr != nil {
+		log.Error().Stack().Err(encErr).Msg("failed to encode reply")
+		return
+	}
+
+	_ = p.enqueue(c, frame)
+}
+
+func newErrorData(err error, localizer *i18n.Localizer) *ErrorData {
+	var i18nErr model.I18nError
+	if !errors.As(err, &i18nErr) {
+		i18nErr = ErrInternal
+	}
+
+	message := i18nErr.Error()
+	if localizer != nil {
+		message = locale.LocalizeWithArgs(localizer, i18nErr.Tag(), i18nErr.Args())
+	}
+
+	return &ErrorData{
+		Message: message,
+		Tag:     i18nErr.Tag(),
+		Args:    i18nErr.Args(),
+	}
+}
+
+func newEnvelope(msgType string, id string, data any) (Envelope, error) {
+	env := Envelope{Type: msgType, ID: id}
+	if data != nil {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return Envelope{}, err
+		}
+		env.Data = raw
+	}
+	return env, nil
+}
+
+func encodeEnvelope(msgType string, id string, data any) ([]byte, error) {
+	env, err := newEnvelope(msgType, id, data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(env)
+}